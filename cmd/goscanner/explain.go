@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+	"github.com/nmasdoufi/goscanner/pkg/logging"
+)
+
+// runExplain scans and fingerprints a single host, given as an address or
+// hostname, and prints the evidence behind its classification. Nothing is
+// pushed to GLPI.
+func runExplain(cfg *config.Config, target string, logger *logging.Logger) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	site, profile, ok := explainRange(ctx, cfg, target)
+	if !ok {
+		logger.Infof("%s is in no configured range: using the default profile and credentials not scoped to sites", target)
+	}
+	exclusions, err := discovery.ParseExclusions(cfg.Blacklist, site.Blacklist)
+	if err != nil {
+		logger.Errorf("blacklist invalid: %v", err)
+		os.Exit(1)
+	}
+	scanner := discovery.NewScanner(profile, logger,
+		discovery.WithExclusions(exclusions),
+		discovery.WithLimiter(discovery.NewLimiter(cfg.RateLimit)))
+	targets, err := scanner.HostTargets(ctx, []string{target})
+	if err != nil {
+		logger.Errorf("explain %s: %v", target, err)
+		os.Exit(1)
+	}
+
+	fp, snmpCache := newFingerprintEngine(cfg, logger)
+	alive := 0
+	for host := range scanner.Stream(ctx, targets) {
+		if !host.Alive {
+			fmt.Printf("%s did not respond\n", host.IP)
+			continue
+		}
+		alive++
+		host.Site = site.Name
+		printExplanation(os.Stdout, fp.FingerprintHost(ctx, host))
+	}
+	if err := snmpCache.Save(); err != nil {
+		logger.Errorf("%v", err)
+	}
+	if stats := scanner.Stats(); stats.Excluded > 0 {
+		fmt.Printf("%s is blacklisted, not probed\n", target)
+	}
+	if alive == 0 {
+		os.Exit(1)
+	}
+}
+
+// explainRange returns the site and profile of the first configured CIDR
+// range containing target or, for a hostname, one of its addresses. It
+// reports false when no range does: the scanner defaults then apply, and
+// only credentials not scoped to sites are tried.
+func explainRange(ctx context.Context, cfg *config.Config, target string) (config.Site, config.Profile, bool) {
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(target); err == nil {
+		addrs = []netip.Addr{addr}
+	} else if resolved, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target); err == nil {
+		addrs = resolved
+	}
+	for _, addr := range addrs {
+		addr = addr.Unmap()
+		for _, site := range cfg.Sites {
+			for _, r := range site.Ranges {
+				prefix, err := netip.ParsePrefix(r.CIDR)
+				if err == nil && prefix.Contains(addr) {
+					return site, cfg.Profiles[r.ProfileName], true
+				}
+			}
+		}
+	}
+	return config.Site{}, config.Profile{}, false
+}
+
+// printExplanation writes each classified field of asset with its
+// confidence, followed by the evidence weighed for it. Evidence for the
+// chosen value is marked "+", evidence for other values "-".
+func printExplanation(w io.Writer, asset inventory.AssetModel) {
+	fmt.Fprintf(w, "%s", asset.IP)
+	if asset.Hostname != "" {
+		fmt.Fprintf(w, " (%s)", asset.Hostname)
+	}
+	fmt.Fprintln(w)
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{inventory.FieldType, asset.Type},
+		{inventory.FieldVendor, asset.Vendor},
+		{inventory.FieldModel, asset.Model},
+		{inventory.FieldOSName, asset.OSName},
+		{inventory.FieldOSVersion, asset.OSVersion},
+	}
+	for _, field := range fields {
+		confidence, ok := asset.Confidence[field.name]
+		if !ok {
+			fmt.Fprintf(w, "  %-10s %s (no evidence)\n", field.name, orNone(field.value))
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s %s (confidence %d%%)\n", field.name, field.value, confidence)
+		for _, ev := range asset.Evidence {
+			if ev.Field != field.name {
+				continue
+			}
+			// Vendors are title-cased after classification.
+			line := fmt.Sprintf("    + %3d %-8s %s", ev.Weight, ev.Source, ev.Rule)
+			if !strings.EqualFold(ev.Value, field.value) {
+				line = fmt.Sprintf("    - %3d %-8s %s → %s", ev.Weight, ev.Source, ev.Rule, ev.Value)
+			}
+			if ev.Detail != "" {
+				line = strings.TrimRight(line, " ") + ": " + ev.Detail
+			}
+			fmt.Fprintln(w, strings.TrimRight(line, " "))
+		}
+	}
+	if len(asset.Probes) > 0 {
+		fmt.Fprintln(w, "  probes")
+		for _, probe := range asset.Probes {
+			status := "ok"
+			if probe.Error != "" {
+				status = probe.Error
+			}
+			fmt.Fprintf(w, "    %-10s %6s  %s\n", probe.Prober, probe.Duration.Round(time.Millisecond), status)
+		}
+	}
+}
+
+func orNone(s string) string {
+	if s == "" || s == "Unknown" {
+		return "-"
+	}
+	return s
+}
@@ -2,28 +2,50 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/nmasdoufi/goscanner/pkg/checkpoint"
 	"github.com/nmasdoufi/goscanner/pkg/config"
 	"github.com/nmasdoufi/goscanner/pkg/discovery"
 	"github.com/nmasdoufi/goscanner/pkg/fingerprint"
 	"github.com/nmasdoufi/goscanner/pkg/glpi"
 	"github.com/nmasdoufi/goscanner/pkg/inventory"
 	"github.com/nmasdoufi/goscanner/pkg/logging"
+	"github.com/nmasdoufi/goscanner/pkg/topology"
 )
 
+// runOptions holds the per-run settings given on the command line.
+type runOptions struct {
+	rangeFilter string
+	timeout     time.Duration
+	statePath   string
+	resume      bool
+	// topologyPath receives the LLDP/CDP topology of the run's assets.
+	topologyPath string
+}
+
 func main() {
 	var configPath string
 	var command string
-	var rangeFilter string
+	var opts runOptions
+	var explainTarget string
 	flag.StringVar(&configPath, "config", "goscanner.yaml", "path to config file")
 	flag.StringVar(&command, "command", "scan", "command to run (scan|list)")
-	flag.StringVar(&rangeFilter, "range", "", "CIDR to scan")
+	flag.StringVar(&opts.rangeFilter, "range", "", "CIDR to scan")
+	flag.DurationVar(&opts.timeout, "timeout", 5*time.Minute, "abort the scan run after this long (0 for no limit)")
+	flag.StringVar(&opts.statePath, "state", "goscanner.state", "checkpoint file recording scan progress (empty to disable)")
+	flag.BoolVar(&opts.resume, "resume", false, "continue the run recorded in the checkpoint file")
+	flag.StringVar(&opts.topologyPath, "topology", "", "write the LLDP/CDP topology to this file (JSON if it ends in .json, Graphviz DOT otherwise)")
+	flag.StringVar(&explainTarget, "explain", "", "fingerprint one host and print the evidence behind its classification")
 	flag.Parse()
 
 	cfg, err := config.Load(configPath)
@@ -35,11 +57,16 @@ func main() {
 		panic(err)
 	}
 
+	if explainTarget != "" {
+		runExplain(cfg, explainTarget, logger)
+		return
+	}
+
 	switch command {
 	case "list":
 		listRanges(cfg)
 	case "scan":
-		runScan(cfg, rangeFilter, logger)
+		runScan(cfg, opts, logger)
 	default:
 		fmt.Println("unknown command", command)
 		os.Exit(1)
@@ -50,81 +77,173 @@ func listRanges(cfg *config.Config) {
 	for _, site := range cfg.Sites {
 		fmt.Printf("Site %s\n", site.Name)
 		for _, r := range site.Ranges {
-			fmt.Printf("  %s (%s)\n", r.CIDR, r.ProfileName)
+			fmt.Printf("  %s (%s)\n", r.Source(), r.ProfileName)
 		}
 	}
 }
 
-func runScan(cfg *config.Config, rangeFilter string, logger *logging.Logger) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+func runScan(cfg *config.Config, opts runOptions, logger *logging.Logger) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
 	logger.Infof("starting scan run")
-	assets := []inventory.AssetModel{}
+	fp, snmpCache := newFingerprintEngine(cfg, logger)
 
-	// Configure fingerprint engine with SNMP from credentials
+	journal, err := openJournal(opts, logger)
+	if err != nil {
+		logger.Errorf("checkpointing disabled: %v", err)
+	}
+
+	pipeline := &scanPipeline{
+		cfg:         cfg,
+		rangeFilter: opts.rangeFilter,
+		logger:      logger,
+		fp:          fp,
+		limiter:     discovery.NewLimiter(cfg.RateLimit),
+		journal:     journal,
+		keepAssets:  opts.topologyPath != "",
+	}
+	if rl := cfg.RateLimit; pipeline.limiter != nil {
+		logger.Infof("global rate limit: %d packets/s, %d connections/s, %d hosts per subnet",
+			rl.PacketsPerSecond, rl.ConnectionsPerSecond, rl.SubnetConcurrency)
+	}
+	if cfg.GLPI.BaseURL != "" {
+		maybePromptGLPIPassword(cfg)
+		logger.Infof("pushing assets to GLPI at %s as they are classified", cfg.GLPI.BaseURL)
+		pipeline.client = glpi.NewClient(cfg.GLPI)
+	} else {
+		logger.Infof("GLPI integration disabled; discovered assets kept local only")
+	}
+
+	pipeline.run(ctx)
+	if err := snmpCache.Save(); err != nil {
+		logger.Errorf("%v", err)
+	}
+	if opts.topologyPath != "" {
+		if err := writeTopology(opts.topologyPath, pipeline.assets); err != nil {
+			logger.Errorf("write topology: %v", err)
+		} else {
+			logger.Infof("wrote network topology to %s", opts.topologyPath)
+		}
+	}
+
+	finished := ctx.Err() == nil && journal.Finished()
+	if err := journal.Close(finished); err != nil {
+		logger.Errorf("close checkpoint: %v", err)
+	}
+	if !finished && journal != nil {
+		logger.Infof("scan run incomplete; progress saved to %s, rerun with -resume to continue", opts.statePath)
+	}
+
+	summary := &pipeline.summary
+	if pipeline.client != nil {
+		logger.Infof("pushed %d assets to GLPI, %d failed", summary.pushed, summary.failed)
+	}
+	logger.Infof("discovered %d assets, skipped %d blacklisted hosts", summary.assets, summary.excluded)
+	logger.Infof("sent %d probe packets (%d connections), %s waiting on rate limits",
+		summary.packets, summary.connections, summary.throttled.Round(time.Millisecond))
+	fmt.Printf("discovered %d assets\n", summary.assets)
+	fmt.Printf("skipped %d blacklisted hosts\n", summary.excluded)
+	fmt.Printf("sent %d probe packets (%d connections), throttled %s\n",
+		summary.packets, summary.connections, summary.throttled.Round(time.Millisecond))
+}
+
+// newFingerprintEngine configures the fingerprint engine with the rule
+// files, SNMP credentials and SNMP cache of cfg. It exits when a rule file
+// is invalid. The cache, if any, must be saved once fingerprinting ends.
+func newFingerprintEngine(cfg *config.Config, logger *logging.Logger) (*fingerprint.Engine, *fingerprint.SNMPCache) {
 	var fpOpts []fingerprint.EngineOption
-	snmpCommunity := findSNMPCommunity(cfg)
-	if snmpCommunity != "" {
-		logger.Infof("SNMP enabled with community: %s", snmpCommunity)
-		fpOpts = append(fpOpts, fingerprint.WithSNMP(snmpCommunity))
+	if len(cfg.Fingerprint.Rules) > 0 {
+		rules, err := fingerprint.LoadRules(cfg.Fingerprint.Rules...)
+		if err != nil {
+			logger.Errorf("load fingerprint rules: %v", err)
+			os.Exit(1)
+		}
+		logger.Infof("loaded fingerprint rules from %s", strings.Join(cfg.Fingerprint.Rules, ", "))
+		fpOpts = append(fpOpts, fingerprint.WithRules(rules))
+	}
+
+	if creds := snmpCredentials(cfg); len(creds) > 0 {
+		names := make([]string, len(creds))
+		for i, cred := range creds {
+			names[i] = cred.Name
+		}
+		logger.Infof("SNMP enabled with credentials %s", strings.Join(names, ", "))
+		fpOpts = append(fpOpts, fingerprint.WithSNMPCredentials(creds...))
 	} else {
 		logger.Infof("SNMP enabled with default community: public")
 	}
-	fp := fingerprint.NewEngine(fpOpts...)
 
-	for _, site := range cfg.Sites {
-		logger.Infof("site %s", site.Name)
-		for _, r := range site.Ranges {
-			if rangeFilter != "" && r.CIDR != rangeFilter {
-				continue
-			}
-			logger.Infof("scanning %s with profile %s", r.CIDR, r.ProfileName)
-			profile, ok := cfg.Profiles[r.ProfileName]
-			if !ok {
-				logger.Errorf("profile %s missing", r.ProfileName)
-				continue
-			}
-			scanner := discovery.NewScanner(profile, logger)
-			hosts, err := scanner.ScanCIDR(ctx, r.CIDR)
-			if err != nil {
-				logger.Errorf("scan error %s: %v", r.CIDR, err)
-				continue
-			}
-			logger.Debugf("%s produced %d host results", r.CIDR, len(hosts))
-			for _, host := range hosts {
-				if !host.Alive {
-					continue
-				}
-				logger.Debugf("fingerprinting %s with %d open ports %v", host.IP, len(host.OpenPorts), portList(host.OpenPorts))
-				if host.MAC != "" {
-					logger.Debugf("  MAC address: %s", host.MAC)
-				}
-				asset := fp.FingerprintHost(ctx, host)
-				logger.Infof("classified %s as %s (vendor: %s, model: %s)", asset.IP, asset.Type, asset.Vendor, asset.Model)
-				if asset.Hostname != "" {
-					logger.Debugf("  hostname: %s", asset.Hostname)
-				}
-				if asset.OSName != "" {
-					logger.Debugf("  OS: %s %s", asset.OSName, asset.OSVersion)
-				}
-				assets = append(assets, asset)
+	if creds := sshCredentials(cfg); len(creds) > 0 {
+		names := make([]string, len(creds))
+		for i, cred := range creds {
+			names[i] = cred.Name
+		}
+		logger.Infof("SSH inventory enabled with credentials %s", strings.Join(names, ", "))
+		for _, cred := range creds {
+			switch {
+			case cred.KnownHosts != "":
+			case cred.InsecureIgnoreHostKey:
+				logger.Errorf("SSH credential %s: host keys are not verified and the password is sent to any server", cred.Name)
+			case cred.PrivateKey != "":
+				logger.Errorf("SSH credential %s: host keys are not verified; only the private key is offered", cred.Name)
+			default:
+				logger.Errorf("SSH credential %s: password not sent without known_hosts or insecure_ignore_host_key", cred.Name)
 			}
 		}
+		fpOpts = append(fpOpts, fingerprint.WithSSHCredentials(creds...))
 	}
-	if cfg.GLPI.BaseURL != "" {
-		maybePromptGLPIPassword(cfg)
-		logger.Infof("pushing %d assets to GLPI at %s", len(assets), cfg.GLPI.BaseURL)
-		client := glpi.NewClient(cfg.GLPI)
-		for _, asset := range assets {
-			if err := client.UpsertAsset(ctx, asset); err != nil {
-				logger.Errorf("glpi upsert failed for %s: %v", asset.IP, err)
-			}
+
+	var cache *fingerprint.SNMPCache
+	if path := cfg.Fingerprint.SNMPCache; path != "" {
+		var err error
+		if cache, err = fingerprint.OpenSNMPCache(path); err != nil {
+			logger.Errorf("SNMP cache disabled: %v", err)
+		} else {
+			fpOpts = append(fpOpts, fingerprint.WithSNMPCache(cache))
 		}
+	}
+	return fingerprint.NewEngine(fpOpts...), cache
+}
+
+// writeTopology writes the topology graph of assets to path, as JSON when
+// the path ends in .json and as Graphviz DOT otherwise. On a resumed run
+// only the assets fingerprinted by this run are included.
+func writeTopology(path string, assets []inventory.AssetModel) error {
+	g := topology.Build(assets)
+	var buf bytes.Buffer
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = g.WriteJSON(&buf)
 	} else {
-		logger.Infof("GLPI integration disabled; discovered assets kept local only")
+		err = g.WriteDOT(&buf)
+	}
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// openJournal opens the checkpoint file for the run, or returns nil when
+// checkpointing is disabled.
+func openJournal(opts runOptions, logger *logging.Logger) (*checkpoint.Journal, error) {
+	if opts.statePath == "" {
+		return nil, nil
+	}
+	if opts.resume {
+		logger.Infof("resuming scan run from %s", opts.statePath)
+	} else if _, err := os.Stat(opts.statePath); err == nil {
+		logger.Infof("discarding unfinished run in %s (use -resume to continue it)", opts.statePath)
 	}
-	logger.Infof("discovered %d assets", len(assets))
-	fmt.Printf("discovered %d assets\n", len(assets))
+	return checkpoint.Open(opts.statePath, opts.resume)
 }
 
 func maybePromptGLPIPassword(cfg *config.Config) {
@@ -143,14 +262,27 @@ func maybePromptGLPIPassword(cfg *config.Config) {
 	cfg.GLPI.OAuth.Password = strings.TrimSpace(line)
 }
 
-// findSNMPCommunity extracts SNMP community string from credentials
-func findSNMPCommunity(cfg *config.Config) string {
+// snmpCredentials returns the usable SNMP credentials in configuration
+// order
+func snmpCredentials(cfg *config.Config) []config.Credential {
+	var creds []config.Credential
+	for _, cred := range cfg.Credentials {
+		if cred.Type == "snmp" && (cred.Community != "" || cred.Username != "") {
+			creds = append(creds, cred)
+		}
+	}
+	return creds
+}
+
+// sshCredentials returns the SSH credentials in configuration order
+func sshCredentials(cfg *config.Config) []config.Credential {
+	var creds []config.Credential
 	for _, cred := range cfg.Credentials {
-		if cred.Type == "snmp" && cred.Community != "" {
-			return cred.Community
+		if cred.Type == "ssh" {
+			creds = append(creds, cred)
 		}
 	}
-	return ""
+	return creds
 }
 
 // portList converts port map to sorted list for logging
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/checkpoint"
+	"github.com/nmasdoufi/goscanner/pkg/config"
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/fingerprint"
+	"github.com/nmasdoufi/goscanner/pkg/glpi"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+	"github.com/nmasdoufi/goscanner/pkg/logging"
+)
+
+const (
+	// pipelineBuffer bounds how many items may wait between two stages.
+	pipelineBuffer = 64
+	// fingerprintWorkers is the number of hosts fingerprinted in parallel.
+	fingerprintWorkers = 4
+)
+
+// scanSummary aggregates counters reported at the end of a run.
+type scanSummary struct {
+	mu          sync.Mutex
+	excluded    int
+	assets      int
+	pushed      int
+	failed      int
+	packets     int
+	connections int
+	throttled   time.Duration
+}
+
+func (s *scanSummary) add(fn func(*scanSummary)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s)
+}
+
+// scannedHost is a live host along with the checkpoint key of its range.
+type scannedHost struct {
+	rangeKey string
+	host     discovery.HostResult
+}
+
+// classifiedAsset is a fingerprinted host along with the checkpoint key of
+// its range.
+type classifiedAsset struct {
+	rangeKey string
+	asset    inventory.AssetModel
+}
+
+// scanPipeline streams hosts from discovery through fingerprinting and on to
+// GLPI, so that each stage starts working as soon as the first host is ready.
+type scanPipeline struct {
+	cfg         *config.Config
+	rangeFilter string
+	logger      *logging.Logger
+	fp          *fingerprint.Engine
+	client      *glpi.Client
+	limiter     *discovery.Limiter
+	journal     *checkpoint.Journal
+	summary     scanSummary
+	// keepAssets retains every classified asset in assets, for the
+	// topology export.
+	keepAssets bool
+	assets     []inventory.AssetModel
+
+	journalOnce sync.Once
+}
+
+// checkpointed reports a failure to record progress, once per run.
+func (p *scanPipeline) checkpointed(err error) {
+	if err == nil {
+		return
+	}
+	p.journalOnce.Do(func() {
+		p.logger.Errorf("checkpoint write failed, progress may not be resumable: %v", err)
+	})
+}
+
+func (p *scanPipeline) run(ctx context.Context) {
+	hosts := make(chan scannedHost, pipelineBuffer)
+	assets := make(chan classifiedAsset, pipelineBuffer)
+
+	go func() {
+		defer close(hosts)
+		p.discover(ctx, hosts)
+	}()
+
+	var fpWG sync.WaitGroup
+	fpWG.Add(fingerprintWorkers)
+	for i := 0; i < fingerprintWorkers; i++ {
+		go func() {
+			defer fpWG.Done()
+			p.fingerprint(ctx, hosts, assets)
+		}()
+	}
+	go func() {
+		fpWG.Wait()
+		close(assets)
+	}()
+
+	p.push(ctx, assets)
+}
+
+// discover scans every configured range and forwards live hosts. Hosts left
+// pending by an interrupted run are forwarded first.
+func (p *scanPipeline) discover(ctx context.Context, out chan<- scannedHost) {
+	if pending := p.journal.Pending(); len(pending) > 0 {
+		p.logger.Infof("resuming %d hosts awaiting fingerprinting or push", len(pending))
+		for _, item := range pending {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- scannedHost{rangeKey: item.Range, host: item.Host}:
+			}
+		}
+	}
+	for _, site := range p.cfg.Sites {
+		p.logger.Infof("site %s", site.Name)
+		exclusions, err := discovery.ParseExclusions(p.cfg.Blacklist, site.Blacklist)
+		if err != nil {
+			p.logger.Errorf("site %s blacklist invalid, skipping site: %v", site.Name, err)
+			continue
+		}
+		if exclusions.Len() > 0 {
+			p.logger.Infof("site %s excludes %d blacklist entries", site.Name, exclusions.Len())
+		}
+		for _, r := range site.Ranges {
+			source := r.Source()
+			if p.rangeFilter != "" && r.CIDR != p.rangeFilter && source != p.rangeFilter {
+				continue
+			}
+			p.logger.Infof("scanning %s with profile %s", source, r.ProfileName)
+			profile, ok := p.cfg.Profiles[r.ProfileName]
+			if !ok {
+				p.logger.Errorf("profile %s missing", r.ProfileName)
+				continue
+			}
+			progress, err := p.journal.Range(site.Name+"/"+source+"/"+r.ProfileName, rand.Uint64()|1)
+			p.checkpointed(err)
+			if progress.Done() {
+				p.logger.Infof("%s already scanned by the interrupted run, skipping", source)
+				continue
+			}
+			scanner := discovery.NewScanner(profile, p.logger,
+				discovery.WithExclusions(exclusions),
+				discovery.WithLimiter(p.limiter),
+				discovery.WithTargetSeed(progress.Seed()))
+			// Name-based ranges are resolved once per run: a resumed run
+			// scans the targets the interrupted run journaled, since DNS
+			// answers and zone contents may have changed since.
+			var targets discovery.Targets
+			if resolved := progress.Resolved(); resolved != nil {
+				p.logger.Infof("%s: resuming with the targets resolved by the interrupted run", source)
+				targets = resolved
+			} else {
+				targets, err = rangeTargets(ctx, scanner, r)
+				if err != nil {
+					p.logger.Errorf("scan error %s: %v", source, err)
+					continue
+				}
+				if named, ok := targets.(discovery.NamedTargets); ok {
+					targets, err = progress.RecordTargets(named)
+					p.checkpointed(err)
+				}
+			}
+			// When checkpointing, blacklisted hosts are filtered before
+			// they reach the scanner, so they are counted here.
+			skipped := 0
+			targets = progress.Targets(targets, func(ip netip.Addr) bool {
+				if exclusions.Contains(ip) {
+					skipped++
+					return true
+				}
+				return false
+			})
+			live := 0
+			for host := range scanner.Stream(ctx, targets) {
+				// The site is journaled with the host so that resumed
+				// hosts keep their site-scoped credentials.
+				host.Site = site.Name
+				p.checkpointed(progress.HostDone(host))
+				if !host.Alive {
+					continue
+				}
+				live++
+				select {
+				case <-ctx.Done():
+				case out <- scannedHost{rangeKey: progress.Key(), host: host}:
+				}
+			}
+			if ctx.Err() == nil {
+				p.checkpointed(progress.Complete())
+			}
+			stats := scanner.Stats()
+			stats.Excluded += skipped
+			p.logger.Debugf("%s probed %d hosts, %d alive, %d packets, %d connections", source, stats.Probed, live, stats.Packets, stats.Connections)
+			if stats.Excluded > 0 {
+				p.logger.Infof("%s: skipped %d blacklisted hosts", source, stats.Excluded)
+			}
+			if stats.Throttled > 0 {
+				p.logger.Infof("%s: probes waited %s on rate limits", source, stats.Throttled.Round(time.Millisecond))
+			}
+			p.summary.add(func(s *scanSummary) {
+				s.excluded += stats.Excluded
+				s.packets += stats.Packets
+				s.connections += stats.Connections
+				s.throttled += stats.Throttled
+			})
+		}
+	}
+}
+
+// rangeTargets builds the target source configured for r.
+func rangeTargets(ctx context.Context, scanner *discovery.Scanner, r config.ScanRange) (discovery.Targets, error) {
+	switch {
+	case len(r.Hosts) > 0:
+		return scanner.HostTargets(ctx, r.Hosts)
+	case r.File != "":
+		return scanner.FileTargets(ctx, r.File)
+	case r.Zone != "":
+		return scanner.ZoneTargets(ctx, r.Zone, r.ZoneServer)
+	default:
+		return scanner.CIDRTargets(r.CIDR)
+	}
+}
+
+// fingerprint classifies live hosts.
+func (p *scanPipeline) fingerprint(ctx context.Context, in <-chan scannedHost, out chan<- classifiedAsset) {
+	for item := range in {
+		host := item.host
+		p.logger.Debugf("fingerprinting %s with %d open ports %v", host.IP, len(host.OpenPorts), portList(host.OpenPorts))
+		if len(host.UDPPorts) > 0 {
+			p.logger.Debugf("  UDP ports answering: %v", portList(host.UDPPorts))
+		}
+		for port, svc := range host.Services {
+			p.logger.Debugf("  tcp/%d: %s %s", port, svc, svc.Banner)
+		}
+		if host.MAC != "" {
+			p.logger.Debugf("  MAC address: %s", host.MAC)
+		}
+		if host.RTT > 0 {
+			p.logger.Debugf("  ICMP rtt %s ttl %d", host.RTT, host.TTL)
+		}
+		asset := p.fp.FingerprintHost(ctx, host)
+		p.logger.Infof("classified %s as %s (vendor: %s, model: %s)", asset.IP, asset.Type, asset.Vendor, asset.Model)
+		if asset.Hostname != "" {
+			p.logger.Debugf("  hostname: %s", asset.Hostname)
+		}
+		if asset.OSName != "" {
+			p.logger.Debugf("  OS: %s %s", asset.OSName, asset.OSVersion)
+		}
+		for _, probe := range asset.Probes {
+			if probe.Error != "" {
+				p.logger.Debugf("  probe %s failed after %s: %s", probe.Prober, probe.Duration.Round(time.Millisecond), probe.Error)
+			} else {
+				p.logger.Debugf("  probe %s took %s", probe.Prober, probe.Duration.Round(time.Millisecond))
+			}
+		}
+		p.summary.add(func(s *scanSummary) { s.assets++ })
+		select {
+		case <-ctx.Done():
+		case out <- classifiedAsset{rangeKey: item.rangeKey, asset: asset}:
+		}
+	}
+}
+
+// push sends classified assets to GLPI when the integration is enabled.
+// Assets whose push failed stay pending in the checkpoint. Every asset is
+// held back until fingerprinting ends, so that the ARP and forwarding
+// tables of routers and switches fingerprinted after it can supply its MAC
+// address and switch port.
+func (p *scanPipeline) push(ctx context.Context, in <-chan classifiedAsset) {
+	var held []classifiedAsset
+	for item := range in {
+		held = append(held, item)
+	}
+	for _, item := range held {
+		p.fp.Locate(&item.asset)
+		p.pushAsset(ctx, item)
+	}
+}
+
+func (p *scanPipeline) pushAsset(ctx context.Context, item classifiedAsset) {
+	asset := item.asset
+	if p.keepAssets {
+		p.assets = append(p.assets, asset)
+	}
+	if p.client != nil {
+		if err := p.client.UpsertAsset(ctx, asset); err != nil {
+			p.logger.Errorf("glpi upsert failed for %s: %v", asset.IP, err)
+			p.summary.add(func(s *scanSummary) { s.failed++ })
+			return
+		}
+		p.summary.add(func(s *scanSummary) { s.pushed++ })
+	}
+	// A fingerprint cut short by cancellation must be redone.
+	if ctx.Err() == nil {
+		p.checkpointed(p.journal.AssetDone(item.rangeKey, asset.IP))
+	}
+}
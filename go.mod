@@ -2,4 +2,9 @@ module github.com/nmasdoufi/goscanner
 
 go 1.22
 
-require github.com/gosnmp/gosnmp v1.37.0
+require (
+	github.com/gosnmp/gosnmp v1.37.0
+	golang.org/x/crypto v0.33.0
+)
+
+require golang.org/x/sys v0.30.0 // indirect
@@ -0,0 +1,321 @@
+// Package checkpoint journals scan progress to a local state file so that
+// an interrupted run can resume without re-probing finished hosts.
+//
+// The state file is a JSON-lines journal appended after every host. It
+// records, per range, the order seed, the resolved targets of name-based
+// ranges and the address of every finished host, and live hosts that still
+// wait for fingerprinting or their GLPI push.
+package checkpoint
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+)
+
+const (
+	kindRange     = "range"
+	kindTargets   = "targets"
+	kindHost      = "host"
+	kindRangeDone = "range_done"
+	kindAssetDone = "asset_done"
+)
+
+// record is one journal line.
+type record struct {
+	Kind    string                `json:"kind"`
+	Range   string                `json:"range"`
+	Seed    uint64                `json:"seed,omitempty"`
+	Targets []target              `json:"targets,omitempty"`
+	IP      string                `json:"ip,omitempty"`
+	Host    *discovery.HostResult `json:"host,omitempty"`
+}
+
+// Pending is a live host whose fingerprint or push has not completed.
+type Pending struct {
+	Range string
+	Host  discovery.HostResult
+}
+
+type pendingKey struct {
+	rangeKey string
+	ip       netip.Addr
+}
+
+// Journal tracks the progress of a scan run. A nil *Journal records
+// nothing, which disables checkpointing.
+type Journal struct {
+	path string
+
+	mu      sync.Mutex
+	f       *os.File
+	ranges  map[string]*Range
+	pending map[pendingKey]discovery.HostResult
+}
+
+// Open starts a journal at path. With resume set, the progress recorded by
+// a previous run is loaded first; a missing file starts a fresh run. The
+// file is rewritten compactly before new progress is appended.
+func Open(path string, resume bool) (*Journal, error) {
+	j := &Journal{
+		path:    path,
+		ranges:  map[string]*Range{},
+		pending: map[pendingKey]discovery.HostResult{},
+	}
+	if resume {
+		if err := j.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("load checkpoint %s: %w", path, err)
+		}
+	}
+	if err := j.compact(); err != nil {
+		return nil, fmt.Errorf("write checkpoint %s: %w", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open checkpoint %s: %w", path, err)
+	}
+	j.f = f
+	return j, nil
+}
+
+func (j *Journal) load() error {
+	f, err := os.Open(j.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// A crash can leave the last line half written; only
+			// that line may be dropped.
+			if !scanner.Scan() {
+				break
+			}
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := j.replay(rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (j *Journal) replay(rec record) error {
+	if rec.Kind == kindRange {
+		r := j.rangeFor(rec.Range)
+		r.seed = rec.Seed
+		return nil
+	}
+	r, ok := j.ranges[rec.Range]
+	if !ok {
+		return fmt.Errorf("%s record for unknown range %q", rec.Kind, rec.Range)
+	}
+	switch rec.Kind {
+	case kindTargets:
+		r.resolved = rec.Targets
+		if r.resolved == nil {
+			r.resolved = []target{}
+		}
+	case kindHost:
+		ip, err := netip.ParseAddr(rec.IP)
+		if err != nil {
+			return err
+		}
+		r.completed[ip] = true
+		if rec.Host != nil {
+			j.pending[pendingKey{rec.Range, ip}] = *rec.Host
+		}
+	case kindRangeDone:
+		r.done = true
+	case kindAssetDone:
+		ip, err := netip.ParseAddr(rec.IP)
+		if err != nil {
+			return err
+		}
+		delete(j.pending, pendingKey{rec.Range, ip})
+	default:
+		return fmt.Errorf("unknown record kind %q", rec.Kind)
+	}
+	return nil
+}
+
+// compact replaces the state file with the minimal journal describing the
+// loaded progress.
+func (j *Journal) compact() error {
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	keys := make([]string, 0, len(j.ranges))
+	for key := range j.ranges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r := j.ranges[key]
+		if err := enc.Encode(record{Kind: kindRange, Range: key, Seed: r.seed}); err != nil {
+			f.Close()
+			return err
+		}
+		if r.resolved != nil {
+			if err := enc.Encode(record{Kind: kindTargets, Range: key, Targets: r.resolved}); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		for ip := range r.completed {
+			rec := record{Kind: kindHost, Range: key, IP: ip.String()}
+			if host, ok := j.pending[pendingKey{key, ip}]; ok {
+				rec.Host = &host
+			}
+			if err := enc.Encode(rec); err != nil {
+				f.Close()
+				return err
+			}
+		}
+		if r.done {
+			if err := enc.Encode(record{Kind: kindRangeDone, Range: key}); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// append writes rec to the journal. Callers hold j.mu.
+func (j *Journal) append(rec record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = j.f.Write(append(line, '\n'))
+	return err
+}
+
+func (j *Journal) rangeFor(key string) *Range {
+	r, ok := j.ranges[key]
+	if !ok {
+		r = &Range{
+			j:         j,
+			key:       key,
+			completed: map[netip.Addr]bool{},
+		}
+		j.ranges[key] = r
+	}
+	return r
+}
+
+// Range returns the progress of the range identified by key, registering
+// it with seed when the journal has not seen it before. A resumed range
+// keeps the seed it was started with.
+func (j *Journal) Range(key string, seed uint64) (*Range, error) {
+	if j == nil {
+		return nil, nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if r, ok := j.ranges[key]; ok {
+		return r, nil
+	}
+	r := j.rangeFor(key)
+	r.seed = seed
+	return r, j.append(record{Kind: kindRange, Range: key, Seed: seed})
+}
+
+// Pending returns the live hosts recorded by a previous run whose
+// fingerprint or push did not complete.
+func (j *Journal) Pending() []Pending {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]Pending, 0, len(j.pending))
+	for key, host := range j.pending {
+		out = append(out, Pending{Range: key.rangeKey, Host: host})
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].Range != out[b].Range {
+			return out[a].Range < out[b].Range
+		}
+		return out[a].Host.IP.Less(out[b].Host.IP)
+	})
+	return out
+}
+
+// AssetDone records that the host found in rangeKey at ip has been
+// fingerprinted and pushed.
+func (j *Journal) AssetDone(rangeKey string, ip netip.Addr) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.pending, pendingKey{rangeKey, ip})
+	return j.append(record{Kind: kindAssetDone, Range: rangeKey, IP: ip.String()})
+}
+
+// Finished reports whether every range that was started has completed and
+// no host is waiting to be fingerprinted or pushed.
+func (j *Journal) Finished() bool {
+	if j == nil {
+		return true
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.pending) > 0 {
+		return false
+	}
+	for _, r := range j.ranges {
+		if !r.done {
+			return false
+		}
+	}
+	return true
+}
+
+// Close flushes the journal to disk. With remove set, the state file is
+// deleted instead, which is how a completed run discards its checkpoint.
+func (j *Journal) Close(remove bool) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.f.Sync(); err != nil {
+		j.f.Close()
+		return err
+	}
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+	if remove {
+		return os.Remove(j.path)
+	}
+	return nil
+}
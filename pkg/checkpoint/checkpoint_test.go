@@ -0,0 +1,220 @@
+package checkpoint
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+)
+
+// sliceTargets yields a fixed list of addresses.
+type sliceTargets struct {
+	addrs []netip.Addr
+}
+
+func (t *sliceTargets) Next() (netip.Addr, bool) {
+	if len(t.addrs) == 0 {
+		return netip.Addr{}, false
+	}
+	addr := t.addrs[0]
+	t.addrs = t.addrs[1:]
+	return addr, true
+}
+
+func hosts(n int) []netip.Addr {
+	out := make([]netip.Addr, n)
+	addr := netip.MustParseAddr("10.0.0.1")
+	for i := range out {
+		out[i] = addr
+		addr = addr.Next()
+	}
+	return out
+}
+
+func drain(t discovery.Targets) []netip.Addr {
+	var out []netip.Addr
+	for {
+		addr, ok := t.Next()
+		if !ok {
+			return out
+		}
+		out = append(out, addr)
+	}
+}
+
+func TestJournalResume(t *testing.T) {
+	const key = "site/10.0.0.0/29/default"
+	path := filepath.Join(t.TempDir(), "scan.state")
+	all := hosts(6)
+	blacklisted := func(ip netip.Addr) bool { return ip == all[1] }
+
+	j, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	r, err := j.Range(key, 7)
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	targets := r.Targets(&sliceTargets{addrs: all}, blacklisted)
+	var issued []netip.Addr
+	for i := 0; i < 3; i++ {
+		ip, _ := targets.Next()
+		issued = append(issued, ip)
+	}
+	if issued[0] != all[0] || issued[1] != all[2] || issued[2] != all[3] {
+		t.Fatalf("issued %v", issued)
+	}
+	// Hosts 0 and 3 finish, host 2 is still being probed when the run
+	// dies; host 3 is alive and never reaches GLPI.
+	live := discovery.HostResult{IP: all[3], Alive: true, OpenPorts: map[int]time.Duration{22: time.Millisecond}}
+	for _, host := range []discovery.HostResult{{IP: all[0]}, live} {
+		if err := r.HostDone(host); err != nil {
+			t.Fatalf("HostDone: %v", err)
+		}
+	}
+	if err := j.Close(false); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	j, err = Open(path, true)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	pending := j.Pending()
+	if len(pending) != 1 || pending[0].Range != key || pending[0].Host.IP != all[3] || pending[0].Host.OpenPorts[22] != time.Millisecond {
+		t.Fatalf("pending %+v", pending)
+	}
+	r, err = j.Range(key, 99)
+	if err != nil {
+		t.Fatalf("Range: %v", err)
+	}
+	if r.Seed() != 7 || r.Done() {
+		t.Fatalf("resumed range seed %d done %v", r.Seed(), r.Done())
+	}
+	// The resumed source yields the range in another order, as a reshuffled
+	// host list or zone would.
+	reversed := make([]netip.Addr, len(all))
+	for i, ip := range all {
+		reversed[len(all)-1-i] = ip
+	}
+	rest := drain(r.Targets(&sliceTargets{addrs: reversed}, blacklisted))
+	want := []netip.Addr{all[5], all[4], all[2]}
+	if len(rest) != len(want) {
+		t.Fatalf("resumed targets %v, want %v", rest, want)
+	}
+	for i := range want {
+		if rest[i] != want[i] {
+			t.Fatalf("resumed targets %v, want %v", rest, want)
+		}
+	}
+	for _, ip := range rest {
+		r.HostDone(discovery.HostResult{IP: ip})
+	}
+	if len(r.completed) != len(all)-1 {
+		t.Fatalf("%d hosts finished, want every host but the blacklisted one", len(r.completed))
+	}
+	if err := r.Complete(); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if j.Finished() {
+		t.Fatalf("journal finished with a pending asset")
+	}
+	if err := j.AssetDone(key, all[3]); err != nil {
+		t.Fatalf("AssetDone: %v", err)
+	}
+	if !j.Finished() {
+		t.Fatalf("journal not finished")
+	}
+	if err := j.Close(true); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state file kept after a finished run: %v", err)
+	}
+}
+
+func TestJournalToleratesTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan.state")
+	data := `{"kind":"range","range":"a","seed":3}
+{"kind":"host","range":"a","ip":"10.0.0.1"}
+{"kind":"host","range":"a","ip":"10.0`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	j, err := Open(path, true)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer j.Close(false)
+	r, _ := j.Range("a", 0)
+	if r.Seed() != 3 || len(r.completed) != 1 || !r.completed[netip.MustParseAddr("10.0.0.1")] {
+		t.Fatalf("seed %d completed %v", r.Seed(), r.completed)
+	}
+}
+
+// namedTargets yields a fixed list of addresses resolved from names.
+type namedTargets struct {
+	sliceTargets
+	names map[netip.Addr]string
+}
+
+func (t *namedTargets) Name(addr netip.Addr) string {
+	return t.names[addr]
+}
+
+func TestJournalResolvedTargets(t *testing.T) {
+	const key = "site/hosts/default"
+	path := filepath.Join(t.TempDir(), "scan.state")
+	all := hosts(3)
+	names := map[netip.Addr]string{all[0]: "printer.example.com", all[2]: "nas.example.com"}
+
+	j, err := Open(path, false)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	r, _ := j.Range(key, 1)
+	if r.Resolved() != nil {
+		t.Fatalf("new range has resolved targets")
+	}
+	recorded, err := r.RecordTargets(&namedTargets{sliceTargets{addrs: []netip.Addr{all[2], all[0], all[1]}}, names})
+	if err != nil {
+		t.Fatalf("RecordTargets: %v", err)
+	}
+	first, _ := r.Targets(recorded, nil).Next()
+	if first != all[2] || recorded.Name(first) != "nas.example.com" {
+		t.Fatalf("first target %s %q", first, recorded.Name(first))
+	}
+	r.HostDone(discovery.HostResult{IP: first})
+	empty, _ := j.Range("site/zone/default", 1)
+	if _, err := empty.RecordTargets(&namedTargets{}); err != nil {
+		t.Fatalf("RecordTargets: %v", err)
+	}
+	j.Close(false)
+
+	// The resumed run scans the journaled list, whatever DNS answers now.
+	j, err = Open(path, true)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	defer j.Close(false)
+	r, _ = j.Range(key, 1)
+	resolved := r.Resolved()
+	if resolved == nil {
+		t.Fatalf("resolved targets not journaled")
+	}
+	rest := drain(r.Targets(resolved, nil))
+	if len(rest) != 2 || rest[0] != all[0] || rest[1] != all[1] {
+		t.Fatalf("resumed targets %v", rest)
+	}
+	if resolved.Name(all[0]) != "printer.example.com" || resolved.Name(all[1]) != "" {
+		t.Fatalf("names %q %q", resolved.Name(all[0]), resolved.Name(all[1]))
+	}
+	empty, _ = j.Range("site/zone/default", 1)
+	if resolved := empty.Resolved(); resolved == nil || len(drain(resolved)) != 0 {
+		t.Fatalf("empty zone not journaled")
+	}
+}
@@ -0,0 +1,190 @@
+package checkpoint
+
+import (
+	"net/netip"
+
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+)
+
+// Range tracks which targets of one scan range are finished. Finished
+// targets are identified by address, so a resumed run may yield the range's
+// targets in any order: re-resolved names and reordered sources cannot make
+// it skip a host that was never probed.
+type Range struct {
+	j    *Journal
+	key  string
+	seed uint64
+	done bool
+
+	// completed holds the hosts that are finished.
+	completed map[netip.Addr]bool
+	// resolved holds the targets of a host list, file or zone range as the
+	// run that started the range resolved them.
+	resolved []target
+}
+
+// target is a resolved target of a name-based range.
+type target struct {
+	IP   string `json:"ip"`
+	Name string `json:"name,omitempty"`
+}
+
+// Key returns the range's journal key.
+func (r *Range) Key() string {
+	if r == nil {
+		return ""
+	}
+	return r.key
+}
+
+// Seed returns the seed that fixes the range's target order.
+func (r *Range) Seed() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.seed
+}
+
+// Done reports whether a previous run scanned the whole range.
+func (r *Range) Done() bool {
+	if r == nil {
+		return false
+	}
+	r.j.mu.Lock()
+	defer r.j.mu.Unlock()
+	return r.done
+}
+
+// Resolved returns the targets journaled by RecordTargets, or nil when the
+// range has none. A resumed run scans these instead of resolving names or
+// transferring the zone again, whose answers may have changed.
+func (r *Range) Resolved() discovery.NamedTargets {
+	if r == nil {
+		return nil
+	}
+	r.j.mu.Lock()
+	defer r.j.mu.Unlock()
+	if r.resolved == nil {
+		return nil
+	}
+	t := &journaledTargets{names: map[netip.Addr]string{}}
+	for _, rt := range r.resolved {
+		ip, err := netip.ParseAddr(rt.IP)
+		if err != nil {
+			continue
+		}
+		t.addrs = append(t.addrs, ip)
+		t.names[ip] = rt.Name
+	}
+	return t
+}
+
+// RecordTargets journals every target src yields, with the name it was
+// resolved from, and returns them in the same order.
+func (r *Range) RecordTargets(src discovery.NamedTargets) (discovery.NamedTargets, error) {
+	if r == nil {
+		return src, nil
+	}
+	t := &journaledTargets{names: map[netip.Addr]string{}}
+	resolved := []target{}
+	for {
+		ip, ok := src.Next()
+		if !ok {
+			break
+		}
+		t.addrs = append(t.addrs, ip)
+		t.names[ip] = src.Name(ip)
+		resolved = append(resolved, target{IP: ip.String(), Name: t.names[ip]})
+	}
+	r.j.mu.Lock()
+	defer r.j.mu.Unlock()
+	r.resolved = resolved
+	return t, r.j.append(record{Kind: kindTargets, Range: r.key, Targets: resolved})
+}
+
+// journaledTargets yields a fixed list of resolved targets.
+type journaledTargets struct {
+	addrs []netip.Addr
+	names map[netip.Addr]string
+}
+
+func (t *journaledTargets) Next() (netip.Addr, bool) {
+	if len(t.addrs) == 0 {
+		return netip.Addr{}, false
+	}
+	ip := t.addrs[0]
+	t.addrs = t.addrs[1:]
+	return ip, true
+}
+
+func (t *journaledTargets) Name(addr netip.Addr) string {
+	return t.names[addr]
+}
+
+// Targets wraps src so that hosts already finished are not yielded again.
+// Targets for which skip returns true are not yielded either.
+func (r *Range) Targets(src discovery.Targets, skip func(netip.Addr) bool) discovery.Targets {
+	if r == nil {
+		return src
+	}
+	return &rangeTargets{r: r, src: src, skip: skip}
+}
+
+type rangeTargets struct {
+	r    *Range
+	src  discovery.Targets
+	skip func(netip.Addr) bool
+}
+
+func (t *rangeTargets) Next() (netip.Addr, bool) {
+	r := t.r
+	for {
+		ip, ok := t.src.Next()
+		if !ok {
+			return ip, false
+		}
+		r.j.mu.Lock()
+		finished := r.completed[ip]
+		r.j.mu.Unlock()
+		if finished || (t.skip != nil && t.skip(ip)) {
+			continue
+		}
+		return ip, true
+	}
+}
+
+// Name forwards to the wrapped source when it resolves names.
+func (t *rangeTargets) Name(addr netip.Addr) string {
+	if named, ok := t.src.(discovery.NamedTargets); ok {
+		return named.Name(addr)
+	}
+	return ""
+}
+
+// HostDone records that host has been probed. Live hosts stay pending
+// until Journal.AssetDone is called for them.
+func (r *Range) HostDone(host discovery.HostResult) error {
+	if r == nil {
+		return nil
+	}
+	r.j.mu.Lock()
+	defer r.j.mu.Unlock()
+	r.completed[host.IP] = true
+	rec := record{Kind: kindHost, Range: r.key, IP: host.IP.String()}
+	if host.Alive {
+		rec.Host = &host
+		r.j.pending[pendingKey{r.key, host.IP}] = host
+	}
+	return r.j.append(rec)
+}
+
+// Complete records that every target of the range has been probed.
+func (r *Range) Complete() error {
+	if r == nil {
+		return nil
+	}
+	r.j.mu.Lock()
+	defer r.j.mu.Unlock()
+	r.done = true
+	return r.j.append(record{Kind: kindRangeDone, Range: r.key})
+}
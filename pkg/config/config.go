@@ -2,8 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // Config represents scanner configuration file.
@@ -14,29 +16,139 @@ type Config struct {
 	Scheduler   SchedulerConfig    `json:"scheduler"`
 	GLPI        GLPIConfig         `json:"glpi"`
 	Logging     LoggingConfig      `json:"logging"`
+	// Blacklist lists addresses excluded from every site.
+	Blacklist []string `json:"blacklist"`
+	// RateLimit applies to the whole run, on top of each profile's limits.
+	RateLimit RateLimit `json:"rate_limit"`
+	// Fingerprint configures device classification.
+	Fingerprint FingerprintConfig `json:"fingerprint"`
 }
 
 // Site describes a scanning location.
 type Site struct {
-	Name      string      `json:"name"`
-	Ranges    []ScanRange `json:"ranges"`
-	Blacklist []string    `json:"blacklist"`
+	Name   string      `json:"name"`
+	Ranges []ScanRange `json:"ranges"`
+	// Blacklist accepts single IPs, CIDRs and dash ranges
+	// ("10.0.0.10-10.0.0.20") that must never be probed.
+	Blacklist []string `json:"blacklist"`
 }
 
-// ScanRange defines the CIDR and profile to use.
+// ScanRange defines the targets and profile to use. Targets come from
+// exactly one source: a CIDR, a list of hosts, a host file or a DNS zone.
 type ScanRange struct {
-	CIDR        string `json:"cidr"`
+	CIDR string `json:"cidr"`
+	// Hosts lists IP addresses and hostnames.
+	Hosts []string `json:"hosts"`
+	// File names a file with one IP address or hostname per line.
+	File string `json:"file"`
+	// Zone is transferred with AXFR from ZoneServer ("host" or
+	// "host:port") and every A and AAAA record in it is scanned.
+	Zone        string `json:"zone"`
+	ZoneServer  string `json:"zone_server"`
 	ProfileName string `json:"profile"`
 	Frequency   string `json:"frequency"`
 }
 
+// Source describes the range's targets for logs and filters.
+func (r ScanRange) Source() string {
+	switch {
+	case r.CIDR != "":
+		return r.CIDR
+	case len(r.Hosts) > 0:
+		return "hosts:" + strings.Join(r.Hosts, ",")
+	case r.File != "":
+		return "file:" + r.File
+	case r.Zone != "":
+		return "zone:" + r.Zone
+	}
+	return ""
+}
+
+// validate checks that the range has exactly one target source.
+func (r ScanRange) validate() error {
+	sources := 0
+	for _, set := range []bool{r.CIDR != "", len(r.Hosts) > 0, r.File != "", r.Zone != ""} {
+		if set {
+			sources++
+		}
+	}
+	switch {
+	case sources == 0:
+		return errors.New("range needs one of cidr, hosts, file or zone")
+	case sources > 1:
+		return fmt.Errorf("range %s sets more than one of cidr, hosts, file and zone", r.Source())
+	case r.Zone != "" && r.ZoneServer == "":
+		return fmt.Errorf("range %s needs zone_server for the zone transfer", r.Source())
+	}
+	return nil
+}
+
 // Profile defines discovery behavior.
 type Profile struct {
-	Description string   `json:"description"`
-	Ports       []int    `json:"ports"`
-	Protocols   []string `json:"protocols"`
-	MaxWorkers  int      `json:"max_workers"`
-	TimeoutMS   int      `json:"timeout_ms"`
+	Description string `json:"description"`
+	// Ports lists TCP ports. The configuration file also accepts ranges
+	// ("1-1024"), service and group names ("ssh", "windows", "top100") and
+	// "tcp/" or "udp/" prefixes; see ExpandPorts.
+	Ports []int `json:"ports"`
+	// Extends names a profile whose ports, UDP ports and, when unset here,
+	// protocols are merged into this profile's.
+	Extends string `json:"extends"`
+	// Protocols lists the transports to probe: "tcp" (default) and/or
+	// "udp". UDP probes UDPPorts, or when empty every port in Ports that
+	// has a built-in protocol payload (53, 123, 137, 161, 623, 1900, 5353).
+	// Listing UDP ports in the configuration file enables UDP.
+	Protocols  []string `json:"protocols"`
+	UDPPorts   []int    `json:"udp_ports"`
+	MaxWorkers int      `json:"max_workers"`
+	TimeoutMS  int      `json:"timeout_ms"`
+	// AdaptiveTimeout derives probe timeouts from the RTT measured per
+	// subnet, bounded by MinTimeoutMS and MaxTimeoutMS. TimeoutMS is used
+	// until enough answers have been seen.
+	AdaptiveTimeout bool `json:"adaptive_timeout"`
+	MinTimeoutMS    int  `json:"min_timeout_ms"`
+	MaxTimeoutMS    int  `json:"max_timeout_ms"`
+	// Retries re-sends probes that got no answer. With AdaptiveTimeout
+	// each retry doubles the timeout up to MaxTimeoutMS.
+	Retries int `json:"retries"`
+	// MaxInflight caps the port probes outstanding at once across all
+	// hosts being scanned. Defaults to four times MaxWorkers.
+	MaxInflight int `json:"max_inflight"`
+	// StopOnAlive ends a host's probing as soon as it is known to be up,
+	// for profiles that only need an up/down answer.
+	StopOnAlive bool `json:"stop_on_alive"`
+	// Randomize probes targets in a pseudo-random order instead of a
+	// sequential sweep.
+	Randomize bool `json:"randomize"`
+	// IPv6Seed selects how IPv6 prefixes larger than a /112 are scanned.
+	// Only "lowbyte" (host IDs ::1 up to IPv6SeedCount) is supported.
+	IPv6Seed      string `json:"ipv6_seed"`
+	IPv6SeedCount int    `json:"ipv6_seed_count"`
+	// Liveness selects what marks a host alive: "tcp" (an open port,
+	// default), "icmp" (an echo reply) or "any".
+	Liveness string `json:"liveness"`
+	// ICMPTimestamp retries unanswered echo requests with an ICMP
+	// timestamp request, which some firewalls let through.
+	ICMPTimestamp bool `json:"icmp_timestamp"`
+	// ARPSweep sends ARP requests to targets on directly attached subnets,
+	// from ARPInterface when set or from the interface owning the subnet.
+	ARPSweep     bool   `json:"arp_sweep"`
+	ARPInterface string `json:"arp_interface"`
+	// RateLimit throttles scans that use this profile.
+	RateLimit RateLimit `json:"rate_limit"`
+	// ServiceDetection reads banners and sends protocol probes to open
+	// TCP ports to identify their service, waiting up to ServiceTimeoutMS
+	// (default 2000) for each answer.
+	ServiceDetection bool `json:"service_detection"`
+	ServiceTimeoutMS int  `json:"service_timeout_ms"`
+}
+
+// RateLimit throttles discovery traffic. Zero values disable a limit.
+type RateLimit struct {
+	PacketsPerSecond     int `json:"packets_per_second"`
+	ConnectionsPerSecond int `json:"connections_per_second"`
+	// SubnetConcurrency caps the hosts probed at once within each /24
+	// (/64 for IPv6).
+	SubnetConcurrency int `json:"subnet_concurrency"`
 }
 
 // Credential stores auth info for different modules.
@@ -46,6 +158,105 @@ type Credential struct {
 	Username  string `json:"username"`
 	Password  string `json:"password"`
 	Community string `json:"community"`
+	// SNMPVersion selects "2c" (Community) or "3" (Username with the
+	// auth and priv settings). Empty tries SNMPv3 when Username is set,
+	// then SNMPv2c when Community is set.
+	SNMPVersion string `json:"snmp_version"`
+	// AuthProtocol is MD5, SHA, SHA224, SHA256, SHA384 or SHA512; empty
+	// means no authentication.
+	AuthProtocol string `json:"auth_protocol"`
+	AuthPassword string `json:"auth_password"`
+	// PrivProtocol is DES, AES, AES192, AES256, AES192C or AES256C; empty
+	// means no privacy. Privacy requires authentication.
+	PrivProtocol string `json:"priv_protocol"`
+	PrivPassword string `json:"priv_password"`
+	// ContextName selects the SNMPv3 context, if the agent needs one.
+	ContextName string `json:"context_name"`
+	// PrivateKey names a PEM private key file for SSH credentials, used
+	// instead of or along with Password; Passphrase decrypts it.
+	PrivateKey string `json:"private_key"`
+	Passphrase string `json:"passphrase"`
+	// KnownHosts names an OpenSSH known_hosts file. When set, SSH logins
+	// are only attempted on hosts whose key it lists.
+	KnownHosts string `json:"known_hosts"`
+	// InsecureIgnoreHostKey sends Password to SSH servers whose host key
+	// cannot be checked because KnownHosts is empty. Without it, such
+	// servers are only offered PrivateKey.
+	InsecureIgnoreHostKey bool `json:"insecure_ignore_host_key"`
+	// Sites limits the credential to hosts of the named sites. Empty
+	// means every site.
+	Sites []string `json:"sites"`
+}
+
+// AppliesToSite reports whether the credential may be used on hosts of
+// site.
+func (c Credential) AppliesToSite(site string) bool {
+	if len(c.Sites) == 0 {
+		return true
+	}
+	for _, s := range c.Sites {
+		if s == site {
+			return true
+		}
+	}
+	return false
+}
+
+// SNMP security protocols accepted in credentials.
+var (
+	SNMPAuthProtocols = []string{"MD5", "SHA", "SHA224", "SHA256", "SHA384", "SHA512"}
+	SNMPPrivProtocols = []string{"DES", "AES", "AES192", "AES256", "AES192C", "AES256C"}
+)
+
+func (c Credential) validate() error {
+	if c.Type == "ssh" {
+		if c.Username == "" {
+			return fmt.Errorf("credential %s: SSH needs a username", c.Name)
+		}
+		if c.Password == "" && c.PrivateKey == "" {
+			return fmt.Errorf("credential %s: SSH needs a password or a private_key", c.Name)
+		}
+		return nil
+	}
+	if c.Type != "snmp" {
+		return nil
+	}
+	switch c.SNMPVersion {
+	case "", "2c", "3":
+	default:
+		return fmt.Errorf("credential %s: snmp_version %q must be 2c or 3", c.Name, c.SNMPVersion)
+	}
+	if c.SNMPVersion == "3" && c.Username == "" {
+		return fmt.Errorf("credential %s: SNMPv3 needs a username", c.Name)
+	}
+	if c.SNMPVersion == "2c" && c.Community == "" {
+		return fmt.Errorf("credential %s: SNMPv2c needs a community", c.Name)
+	}
+	if c.AuthProtocol != "" && !containsFold(SNMPAuthProtocols, c.AuthProtocol) {
+		return fmt.Errorf("credential %s: unknown auth_protocol %q", c.Name, c.AuthProtocol)
+	}
+	if c.PrivProtocol != "" && !containsFold(SNMPPrivProtocols, c.PrivProtocol) {
+		return fmt.Errorf("credential %s: unknown priv_protocol %q", c.Name, c.PrivProtocol)
+	}
+	if c.PrivProtocol != "" && c.AuthProtocol == "" {
+		return fmt.Errorf("credential %s: priv_protocol requires auth_protocol", c.Name)
+	}
+	if c.AuthProtocol != "" && c.AuthPassword == "" {
+		return fmt.Errorf("credential %s: auth_protocol requires auth_password", c.Name)
+	}
+	if c.PrivProtocol != "" && c.PrivPassword == "" {
+		return fmt.Errorf("credential %s: priv_protocol requires priv_password", c.Name)
+	}
+	return nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
 }
 
 // SchedulerConfig configures the background scheduler.
@@ -79,6 +290,36 @@ type LoggingConfig struct {
 	Format string `json:"format"`
 }
 
+// FingerprintConfig configures device classification.
+type FingerprintConfig struct {
+	// Rules lists rule files, in YAML or JSON, loaded after the built-in
+	// rules. A rule named like a built-in one replaces it.
+	Rules []string `json:"rules"`
+	// SNMPCache names a file remembering, per host, the SNMP credential
+	// and version that worked, so later scans try them first. Empty
+	// disables the cache.
+	SNMPCache string `json:"snmp_cache"`
+}
+
+// Unmarshal decodes data written in JSON or in the YAML subset understood
+// by Load into v.
+func Unmarshal(data []byte, v interface{}) error {
+	if json.Valid(data) {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("parse config json: %w", err)
+		}
+		return nil
+	}
+	converted, err := yamlToJSON(data)
+	if err != nil {
+		return fmt.Errorf("parse config: %w", err)
+	}
+	if err := json.Unmarshal(converted, v); err != nil {
+		return fmt.Errorf("parse config json: %w", err)
+	}
+	return nil
+}
+
 // Load reads YAML/JSON configuration.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -86,15 +327,49 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
 	cfg := &Config{}
-	if err := json.Unmarshal(data, cfg); err == nil {
-		return cfg, nil
+	if err := Unmarshal(data, cfg); err != nil {
+		return nil, err
 	}
-	converted, err := yamlToJSON(data)
-	if err != nil {
+	if err := cfg.resolveExtends(); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
-	if err := json.Unmarshal(converted, cfg); err != nil {
-		return nil, fmt.Errorf("parse config json: %w", err)
+	sites := make(map[string]bool, len(cfg.Sites))
+	for _, site := range cfg.Sites {
+		sites[site.Name] = true
+	}
+	names := make(map[string]bool, len(cfg.Credentials))
+	for i, cred := range cfg.Credentials {
+		// The name identifies the credential in the SNMP cache and in
+		// asset attributes.
+		if cred.Name == "" {
+			return nil, fmt.Errorf("credential %d: name is required", i+1)
+		}
+		if names[cred.Name] {
+			return nil, fmt.Errorf("credential %s: duplicate name", cred.Name)
+		}
+		names[cred.Name] = true
+		if err := cred.validate(); err != nil {
+			return nil, err
+		}
+		for _, site := range cred.Sites {
+			if !sites[site] {
+				return nil, fmt.Errorf("credential %s: unknown site %q", cred.Name, site)
+			}
+		}
+	}
+	for _, site := range cfg.Sites {
+		for _, r := range site.Ranges {
+			if err := r.validate(); err != nil {
+				return nil, fmt.Errorf("site %s: %w", site.Name, err)
+			}
+		}
+	}
+	for name, profile := range cfg.Profiles {
+		switch profile.Liveness {
+		case "", "tcp", "icmp", "any":
+		default:
+			return nil, fmt.Errorf("profile %s: liveness %q must be tcp, icmp or any", name, profile.Liveness)
+		}
 	}
 	return cfg, nil
 }
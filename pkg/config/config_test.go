@@ -0,0 +1,148 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSNMPv3Credential(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `credentials:
+  - name: "core"
+    type: snmp
+    snmp_version: "3"
+    username: monitor
+    auth_protocol: sha256
+    auth_password: authpass123
+    priv_protocol: AES
+    priv_password: privpass123
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cred := cfg.Credentials[0]
+	if cred.SNMPVersion != "3" || cred.Username != "monitor" || cred.AuthProtocol != "sha256" || cred.PrivPassword != "privpass123" {
+		t.Fatalf("credential %+v", cred)
+	}
+
+	bad := map[string]string{
+		"snmp_version":           `snmp_version: "1"`,
+		"needs a username":       `snmp_version: "3"`,
+		"auth_protocol":          "username: u\n    auth_protocol: sha1024",
+		"requires auth":          "username: u\n    priv_protocol: aes",
+		"requires auth_password": "username: u\n    auth_protocol: sha",
+		"requires priv_password": "username: u\n    auth_protocol: sha\n    auth_password: authpass123\n    priv_protocol: aes",
+	}
+	for want, fields := range bad {
+		data := "credentials:\n  - name: bad\n    type: snmp\n    " + fields + "\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected error containing %q, got %v", fields, want, err)
+		}
+	}
+}
+
+func TestLoadCredentialNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	bad := map[string]string{
+		"credential 2: name is required": "  - name: a\n    community: public\n  - type: snmp\n    community: private\n",
+		"credential a: duplicate name":   "  - name: a\n    community: public\n  - name: a\n    community: private\n",
+	}
+	for want, creds := range bad {
+		if err := os.WriteFile(path, []byte("credentials:\n"+creds), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestLoadProfileLiveness(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	for liveness, want := range map[string]string{
+		"icmp": "",
+		"any":  "",
+		"ping": `profile p: liveness "ping" must be tcp, icmp or any`,
+	} {
+		data := "profiles:\n  p:\n    liveness: " + liveness + "\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := Load(path)
+		if (want == "" && err != nil) || (want != "" && (err == nil || !strings.Contains(err.Error(), want))) {
+			t.Fatalf("liveness %s: got %v, want %q", liveness, err, want)
+		}
+	}
+}
+
+func TestLoadCredentialSites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `sites:
+  - name: hq
+    ranges:
+      - cidr: "10.0.0.0/24"
+        profile: fast
+credentials:
+  - name: "hq"
+    type: snmp
+    community: secret
+    sites: ["hq"]
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cred := cfg.Credentials[0]
+	if !cred.AppliesToSite("hq") || cred.AppliesToSite("branch") || cred.AppliesToSite("") {
+		t.Fatalf("credential sites %v", cred.Sites)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Replace(data, `["hq"]`, `["branch"]`, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), `unknown site "branch"`) {
+		t.Fatalf("expected unknown site error, got %v", err)
+	}
+}
+
+func TestLoadSSHCredential(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `credentials:
+  - name: "linux"
+    type: ssh
+    username: inventory
+    private_key: /etc/goscanner/id_ed25519
+    known_hosts: /etc/goscanner/known_hosts
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cred := cfg.Credentials[0]
+	if cred.Username != "inventory" || cred.PrivateKey != "/etc/goscanner/id_ed25519" || cred.KnownHosts != "/etc/goscanner/known_hosts" {
+		t.Fatalf("credential %+v", cred)
+	}
+
+	data = strings.Replace(data, "    private_key: /etc/goscanner/id_ed25519\n", "", 1)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "password or a private_key") {
+		t.Fatalf("expected missing secret error, got %v", err)
+	}
+}
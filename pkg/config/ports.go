@@ -0,0 +1,300 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nmasdoufi/goscanner/pkg/service"
+)
+
+// portServices maps service names accepted in port lists to their port.
+var portServices = map[string]int{
+	"ftp":         21,
+	"ssh":         22,
+	"telnet":      23,
+	"smtp":        25,
+	"dns":         53,
+	"http":        80,
+	"kerberos":    88,
+	"pop3":        110,
+	"ntp":         123,
+	"msrpc":       135,
+	"netbios-ns":  137,
+	"netbios-ssn": 139,
+	"imap":        143,
+	"snmp":        161,
+	"ldap":        389,
+	"https":       443,
+	"smb":         445,
+	"lpd":         515,
+	"ipmi":        623,
+	"ipp":         631,
+	"ldaps":       636,
+	"mssql":       1433,
+	"ssdp":        1900,
+	"mysql":       3306,
+	"rdp":         3389,
+	"mdns":        5353,
+	"postgres":    5432,
+	"vnc":         5900,
+	"winrm":       5985,
+	"http-alt":    8080,
+	"https-alt":   8443,
+	"jetdirect":   9100,
+}
+
+// portGroups maps group names accepted in port lists to their ports.
+var portGroups = map[string][]int{
+	"windows":  {88, 135, 139, 389, 445, 3389, 5985},
+	"printers": {80, 161, 443, 515, 631, 9100},
+	"web":      {80, 443, 8000, 8080, 8443},
+	"network":  {22, 23, 80, 161, 443},
+	"database": {1433, 1521, 3306, 5432, 27017},
+	// top100 is nmap's list of the 100 most common TCP ports.
+	"top100": {
+		7, 9, 13, 21, 22, 23, 25, 26, 37, 53, 79, 80, 81, 88, 106, 110, 111,
+		113, 119, 135, 139, 143, 144, 179, 199, 389, 427, 443, 444, 445, 465,
+		513, 514, 515, 543, 544, 548, 554, 587, 631, 646, 873, 990, 993, 995,
+		1025, 1026, 1027, 1028, 1029, 1110, 1433, 1720, 1723, 1755, 1900, 2000,
+		2001, 2049, 2121, 2717, 3000, 3128, 3306, 3389, 3986, 4899, 5000, 5009,
+		5051, 5060, 5101, 5190, 5357, 5432, 5631, 5666, 5800, 5900, 6000, 6001,
+		6646, 7070, 8000, 8008, 8009, 8080, 8081, 8443, 8888, 9100, 9999, 10000,
+		32768, 49152, 49153, 49154, 49155, 49156, 49157,
+	},
+}
+
+// checkUDPProbes rejects the UDP ports of spec the scanner has no probe
+// payload for: they would never answer, so profiles may not list them.
+func checkUDPProbes(spec string, ports []int) error {
+	var missing []int
+	for _, port := range ports {
+		if _, ok := service.UDPPayload(port); !ok {
+			missing = append(missing, port)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	what := fmt.Sprintf("port %d", missing[0])
+	if len(ports) > 1 {
+		what = fmt.Sprintf("%d of %d ports", len(missing), len(ports))
+	}
+	var supported []string
+	for _, port := range service.UDPPorts() {
+		supported = append(supported, strconv.Itoa(port))
+	}
+	return fmt.Errorf("port %q: no UDP probe for %s (UDP probes exist for %s)", spec, what, strings.Join(supported, ", "))
+}
+
+// PortGroups returns the names of the built-in port groups.
+func PortGroups() []string {
+	names := make([]string, 0, len(portGroups))
+	for name := range portGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandPorts resolves one port list entry: a number, a range ("1-1024"),
+// a service name ("ssh") or a group name ("windows"). A "tcp/" or "udp/"
+// prefix restricts the entry to that transport; unprefixed entries are TCP.
+func ExpandPorts(spec string) (tcp, udp []int, err error) {
+	return expandPorts(spec, "tcp")
+}
+
+func expandPorts(spec, proto string) (tcp, udp []int, err error) {
+	rest := strings.ToLower(strings.TrimSpace(spec))
+	if p, r, ok := strings.Cut(rest, "/"); ok {
+		if p != "tcp" && p != "udp" {
+			return nil, nil, fmt.Errorf("port %q: unknown protocol %q", spec, p)
+		}
+		proto, rest = p, r
+	}
+	ports, err := expandPortSpec(rest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("port %q: %w", spec, err)
+	}
+	if proto == "udp" {
+		return nil, ports, nil
+	}
+	return ports, nil, nil
+}
+
+func expandPortSpec(spec string) ([]int, error) {
+	if port, ok := portServices[spec]; ok {
+		return []int{port}, nil
+	}
+	if group, ok := portGroups[spec]; ok {
+		return group, nil
+	}
+	lo, hi, isRange := strings.Cut(spec, "-")
+	first, err := parsePort(lo)
+	if err != nil {
+		return nil, err
+	}
+	if !isRange {
+		return []int{first}, nil
+	}
+	last, err := parsePort(hi)
+	if err != nil {
+		return nil, err
+	}
+	if last < first {
+		return nil, fmt.Errorf("range end %d is below start %d", last, first)
+	}
+	ports := make([]int, 0, last-first+1)
+	for port := first; port <= last; port++ {
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("unknown port, service or group %q", s)
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("port %d out of range", port)
+	}
+	return port, nil
+}
+
+// portList decodes a JSON port list whose entries are numbers or strings
+// understood by ExpandPorts. Unprefixed entries use proto; UDP entries must
+// name ports the scanner can probe.
+func portList(data json.RawMessage, proto string) (tcp, udp []int, err error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil, nil
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, nil, fmt.Errorf("port list must be an array: %w", err)
+	}
+	for _, entry := range entries {
+		var spec string
+		var number int
+		if err := json.Unmarshal(entry, &number); err == nil {
+			spec = strconv.Itoa(number)
+		} else if err := json.Unmarshal(entry, &spec); err != nil {
+			return nil, nil, fmt.Errorf("invalid port entry %s", entry)
+		}
+		t, u, err := expandPorts(spec, proto)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := checkUDPProbes(spec, u); err != nil {
+			return nil, nil, err
+		}
+		tcp = append(tcp, t...)
+		udp = append(udp, u...)
+	}
+	return dedupePorts(tcp), dedupePorts(udp), nil
+}
+
+// dedupePorts drops repeated ports, keeping the first occurrence.
+func dedupePorts(ports []int) []int {
+	if len(ports) == 0 {
+		return nil
+	}
+	seen := make(map[int]bool, len(ports))
+	out := make([]int, 0, len(ports))
+	for _, port := range ports {
+		if !seen[port] {
+			seen[port] = true
+			out = append(out, port)
+		}
+	}
+	return out
+}
+
+// UnmarshalJSON accepts port ranges, service and group names and
+// transport prefixes in "ports" and "udp_ports". UDP entries, whether
+// listed under "ports" or "udp_ports", also enable UDP probing.
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	type plain Profile
+	aux := struct {
+		*plain
+		Ports    json.RawMessage `json:"ports"`
+		UDPPorts json.RawMessage `json:"udp_ports"`
+	}{plain: (*plain)(p)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	tcp, udp, err := portList(aux.Ports, "tcp")
+	if err != nil {
+		return fmt.Errorf("ports: %w", err)
+	}
+	explicitTCP, explicitUDP, err := portList(aux.UDPPorts, "udp")
+	if err != nil {
+		return fmt.Errorf("udp_ports: %w", err)
+	}
+	if len(explicitTCP) > 0 {
+		return fmt.Errorf("udp_ports: tcp ports %v listed", explicitTCP)
+	}
+	p.Ports = tcp
+	p.UDPPorts = dedupePorts(append(udp, explicitUDP...))
+	if len(p.UDPPorts) > 0 {
+		p.enableUDP()
+	}
+	return nil
+}
+
+// enableUDP adds UDP to the profile's protocols, keeping TCP on when the
+// protocols were left to their default.
+func (p *Profile) enableUDP() {
+	if len(p.Protocols) == 0 {
+		p.Protocols = []string{"tcp"}
+	}
+	for _, proto := range p.Protocols {
+		if strings.EqualFold(proto, "udp") {
+			return
+		}
+	}
+	p.Protocols = append(p.Protocols, "udp")
+}
+
+// resolveExtends merges into every profile the port sets of the profiles
+// it extends.
+func (c *Config) resolveExtends() error {
+	resolved := map[string]bool{}
+	var resolve func(name string, chain []string) error
+	resolve = func(name string, chain []string) error {
+		if resolved[name] {
+			return nil
+		}
+		for _, seen := range chain {
+			if seen == name {
+				return fmt.Errorf("profile %q: extends cycle %s", chain[0], strings.Join(append(chain, name), " -> "))
+			}
+		}
+		profile := c.Profiles[name]
+		if profile.Extends != "" {
+			if _, ok := c.Profiles[profile.Extends]; !ok {
+				return fmt.Errorf("profile %q extends unknown profile %q", name, profile.Extends)
+			}
+			if err := resolve(profile.Extends, append(chain, name)); err != nil {
+				return err
+			}
+			parent := c.Profiles[profile.Extends]
+			profile.Ports = dedupePorts(append(append([]int{}, parent.Ports...), profile.Ports...))
+			profile.UDPPorts = dedupePorts(append(append([]int{}, parent.UDPPorts...), profile.UDPPorts...))
+			if len(profile.Protocols) == 0 {
+				profile.Protocols = parent.Protocols
+			}
+			c.Profiles[name] = profile
+		}
+		resolved[name] = true
+		return nil
+	}
+	for name := range c.Profiles {
+		if err := resolve(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
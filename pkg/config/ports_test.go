@@ -0,0 +1,127 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandPorts(t *testing.T) {
+	cases := []struct {
+		spec     string
+		tcp, udp []int
+	}{
+		{"22", []int{22}, nil},
+		{"8000-8003", []int{8000, 8001, 8002, 8003}, nil},
+		{"SSH", []int{22}, nil},
+		{"windows", portGroups["windows"], nil},
+		{"udp/snmp", nil, []int{161}},
+		{"tcp/1-2", []int{1, 2}, nil},
+	}
+	for _, tc := range cases {
+		tcp, udp, err := ExpandPorts(tc.spec)
+		if err != nil {
+			t.Fatalf("ExpandPorts(%q): %v", tc.spec, err)
+		}
+		if !reflect.DeepEqual(tcp, tc.tcp) || !reflect.DeepEqual(udp, tc.udp) {
+			t.Fatalf("ExpandPorts(%q) = %v %v, want %v %v", tc.spec, tcp, udp, tc.tcp, tc.udp)
+		}
+	}
+	if len(portGroups["top100"]) != 100 {
+		t.Fatalf("top100 has %d ports", len(portGroups["top100"]))
+	}
+	for _, bad := range []string{"0", "70000", "20-10", "sctp/22", "nosuchgroup"} {
+		if _, _, err := ExpandPorts(bad); err == nil {
+			t.Fatalf("ExpandPorts(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestLoadPortSyntaxAndExtends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `profiles:
+  base:
+    ports: ["ssh", "8080-8081", "udp/161"]
+  windows:
+    extends: base
+    ports: ["windows", 22]
+    udp_ports: [137]
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	base := cfg.Profiles["base"]
+	if !reflect.DeepEqual(base.Ports, []int{22, 8080, 8081}) || !reflect.DeepEqual(base.UDPPorts, []int{161}) {
+		t.Fatalf("base ports %v udp %v", base.Ports, base.UDPPorts)
+	}
+	if !reflect.DeepEqual(base.Protocols, []string{"tcp", "udp"}) {
+		t.Fatalf("base protocols %v", base.Protocols)
+	}
+	win := cfg.Profiles["windows"]
+	wantTCP := append([]int{22, 8080, 8081}, 88, 135, 139, 389, 445, 3389, 5985)
+	if !reflect.DeepEqual(win.Ports, wantTCP) || !reflect.DeepEqual(win.UDPPorts, []int{161, 137}) {
+		t.Fatalf("windows ports %v udp %v", win.Ports, win.UDPPorts)
+	}
+	if !reflect.DeepEqual(win.Protocols, base.Protocols) {
+		t.Fatalf("windows protocols %v", win.Protocols)
+	}
+
+	cycle := "profiles:\n  a:\n    extends: b\n  b:\n    extends: a\n"
+	if err := os.WriteFile(path, []byte(cycle), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected extends cycle error, got %v", err)
+	}
+}
+
+func TestLoadRejectsUDPPortsWithoutProbe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	for _, tc := range []struct{ ports, want string }{
+		{`udp_ports: [69]`, `"69": no UDP probe for port 69`},
+		{`ports: ["udp/1-1024"]`, `"udp/1-1024": no UDP probe for 1019 of 1024 ports`},
+		{`ports: ["udp/windows"]`, `no UDP probe for 7 of 7 ports`},
+	} {
+		data := "profiles:\n  p:\n    " + tc.ports + "\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("%s: got %v, want %q", tc.ports, err, tc.want)
+		}
+	}
+	// Rules may still match any UDP port.
+	if _, udp, err := ExpandPorts("udp/69"); err != nil || len(udp) != 1 {
+		t.Fatalf("ExpandPorts(udp/69) = %v, %v", udp, err)
+	}
+}
+
+func TestLoadUDPPortsEnableUDP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `profiles:
+  snmp:
+    udp_ports: ["snmp"]
+  udponly:
+    protocols: ["udp"]
+    udp_ports: [161]
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Profiles["snmp"].Protocols; !reflect.DeepEqual(got, []string{"tcp", "udp"}) {
+		t.Fatalf("snmp protocols %v", got)
+	}
+	if got := cfg.Profiles["udponly"].Protocols; !reflect.DeepEqual(got, []string{"udp"}) {
+		t.Fatalf("udponly protocols %v", got)
+	}
+}
@@ -37,14 +37,34 @@ func preprocessYAML(data []byte) []string {
 		if strings.HasPrefix(trimmed, "#") {
 			continue
 		}
-		if idx := strings.Index(raw, "#"); idx >= 0 {
-			raw = raw[:idx]
-		}
-		lines = append(lines, strings.TrimRight(raw, " "))
+		lines = append(lines, strings.TrimRight(stripComment(raw), " \t"))
 	}
 	return lines
 }
 
+// stripComment removes a trailing comment from line. A "#" starts a comment
+// only outside quotes and after whitespace, so "'#ff0000'" and "a#b" keep
+// theirs.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
 func (p *yamlParser) parseBlock(indent int) (interface{}, error) {
 	line, lineIndent, ok := p.peek()
 	if !ok {
@@ -202,7 +222,15 @@ func parseScalar(value string) interface{} {
 			}
 		}
 		if value[0] == '"' && value[len(value)-1] == '"' {
-			return strings.Trim(value, "\"")
+			if unquoted, ok := unescapeDoubleQuoted(value[1 : len(value)-1]); ok {
+				return unquoted
+			}
+			// Patterns such as "\d+" are not valid escapes; keep them
+			// verbatim.
+			return value[1 : len(value)-1]
+		}
+		if value[0] == '\'' && value[len(value)-1] == '\'' {
+			return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
 		}
 	}
 	switch strings.ToLower(value) {
@@ -216,3 +244,45 @@ func parseScalar(value string) interface{} {
 	}
 	return value
 }
+
+// yamlEscapes maps the single-character escapes of double-quoted YAML
+// scalars to what they stand for.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// unescapeDoubleQuoted resolves the escape sequences of the body of a
+// double-quoted YAML scalar. It reports false on an invalid escape.
+func unescapeDoubleQuoted(s string) (string, bool) {
+	if !strings.Contains(s, "\\") {
+		return s, true
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", false
+		}
+		if esc, ok := yamlEscapes[s[i]]; ok {
+			b.WriteString(esc)
+			continue
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+		if digits == 0 || i+1+digits > len(s) {
+			return "", false
+		}
+		r, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+		if err != nil {
+			return "", false
+		}
+		b.WriteRune(rune(r))
+		i += digits
+	}
+	return b.String(), true
+}
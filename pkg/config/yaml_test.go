@@ -11,3 +11,21 @@ func TestLoadSampleConfig(t *testing.T) {
 		t.Fatalf("failed to load sample config: %v", err)
 	}
 }
+
+func TestYAMLQuotedScalars(t *testing.T) {
+	data := `# comment
+plain: a#b   # trailing comment
+single: 'it''s #1'   # comment
+double: "tab\there \"#2\" back\\slash \u00e9"
+pattern: "^\d+ #"
+list: ["#x", "y"]
+`
+	got, err := yamlToJSON([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"double":"tab\there \"#2\" back\\slash é","list":["#x","y"],"pattern":"^\\d+ #","plain":"a#b","single":"it's #1"}`
+	if string(got) != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
@@ -0,0 +1,243 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	etherTypeARP  = 0x0806
+	etherTypeIPv4 = 0x0800
+	arpRequest    = 1
+	arpReply      = 2
+	// arpFrameLen is an Ethernet header plus an IPv4-over-Ethernet ARP
+	// payload, padded to the minimum Ethernet frame size.
+	arpFrameLen = 60
+)
+
+var broadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// PacketLink sends and receives raw Ethernet frames on a single interface.
+// Implementations must unblock ReadFrame when Close is called.
+type PacketLink interface {
+	HardwareAddr() net.HardwareAddr
+	ReadFrame(buf []byte) (int, error)
+	WriteFrame(frame []byte) error
+	Close() error
+}
+
+// arpPacket is the IPv4-over-Ethernet subset of an ARP message.
+type arpPacket struct {
+	Op        uint16
+	SenderMAC net.HardwareAddr
+	SenderIP  netip.Addr
+	TargetMAC net.HardwareAddr
+	TargetIP  netip.Addr
+}
+
+func marshalARPFrame(dst net.HardwareAddr, p arpPacket) []byte {
+	frame := make([]byte, arpFrameLen)
+	copy(frame[0:6], dst)
+	copy(frame[6:12], p.SenderMAC)
+	binary.BigEndian.PutUint16(frame[12:], etherTypeARP)
+	arp := frame[14:]
+	binary.BigEndian.PutUint16(arp[0:], 1) // Ethernet
+	binary.BigEndian.PutUint16(arp[2:], etherTypeIPv4)
+	arp[4] = 6
+	arp[5] = 4
+	binary.BigEndian.PutUint16(arp[6:], p.Op)
+	copy(arp[8:14], p.SenderMAC)
+	sip := p.SenderIP.As4()
+	copy(arp[14:18], sip[:])
+	copy(arp[18:24], p.TargetMAC)
+	tip := p.TargetIP.As4()
+	copy(arp[24:28], tip[:])
+	return frame
+}
+
+func parseARPFrame(frame []byte) (arpPacket, bool) {
+	if len(frame) < 14+28 || binary.BigEndian.Uint16(frame[12:]) != etherTypeARP {
+		return arpPacket{}, false
+	}
+	arp := frame[14:]
+	if binary.BigEndian.Uint16(arp[0:]) != 1 || binary.BigEndian.Uint16(arp[2:]) != etherTypeIPv4 || arp[4] != 6 || arp[5] != 4 {
+		return arpPacket{}, false
+	}
+	p := arpPacket{
+		Op:        binary.BigEndian.Uint16(arp[6:]),
+		SenderMAC: net.HardwareAddr(bytes.Clone(arp[8:14])),
+		SenderIP:  netip.AddrFrom4([4]byte(arp[14:18])),
+		TargetMAC: net.HardwareAddr(bytes.Clone(arp[18:24])),
+		TargetIP:  netip.AddrFrom4([4]byte(arp[24:28])),
+	}
+	return p, true
+}
+
+// arpResolver sweeps a directly attached IPv4 subnet with ARP requests and
+// matches replies read from the link by a background loop.
+type arpResolver struct {
+	link  PacketLink
+	local netip.Prefix
+
+	mu      sync.Mutex
+	seen    map[netip.Addr]net.HardwareAddr
+	waiters map[netip.Addr][]chan net.HardwareAddr
+}
+
+// newARPResolver starts reading replies from link. local is the scanner's
+// own address on the link together with the attached subnet's length.
+func newARPResolver(link PacketLink, local netip.Prefix) *arpResolver {
+	r := &arpResolver{
+		link:    link,
+		local:   local,
+		seen:    map[netip.Addr]net.HardwareAddr{},
+		waiters: map[netip.Addr][]chan net.HardwareAddr{},
+	}
+	go r.readLoop()
+	return r
+}
+
+// covers reports whether ip can be resolved on this link.
+func (r *arpResolver) covers(ip netip.Addr) bool {
+	return ip.Is4() && ip != r.local.Addr() && r.local.Masked().Contains(ip)
+}
+
+// resolve sends an ARP request for ip and waits for the reply.
+func (r *arpResolver) resolve(ctx context.Context, ip netip.Addr, timeout time.Duration) (net.HardwareAddr, error) {
+	ch := make(chan net.HardwareAddr, 1)
+	r.mu.Lock()
+	if mac, ok := r.seen[ip]; ok {
+		r.mu.Unlock()
+		return mac, nil
+	}
+	r.waiters[ip] = append(r.waiters[ip], ch)
+	r.mu.Unlock()
+	defer r.forget(ip, ch)
+
+	frame := marshalARPFrame(broadcastMAC, arpPacket{
+		Op:        arpRequest,
+		SenderMAC: r.link.HardwareAddr(),
+		SenderIP:  r.local.Addr(),
+		TargetMAC: make(net.HardwareAddr, 6),
+		TargetIP:  ip,
+	})
+	if err := r.link.WriteFrame(frame); err != nil {
+		return nil, fmt.Errorf("send arp request for %s: %w", ip, err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case mac := <-ch:
+		return mac, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("arp %s: no reply", ip)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *arpResolver) forget(ip netip.Addr, ch chan net.HardwareAddr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := r.waiters[ip]
+	for i, c := range list {
+		if c == ch {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(r.waiters, ip)
+	} else {
+		r.waiters[ip] = list
+	}
+}
+
+func (r *arpResolver) readLoop() {
+	buf := make([]byte, 1514)
+	for {
+		n, err := r.link.ReadFrame(buf)
+		if err != nil {
+			return
+		}
+		p, ok := parseARPFrame(buf[:n])
+		if !ok || !r.covers(p.SenderIP) {
+			continue
+		}
+		// Requests announce the sender's mapping as well as replies do.
+		if p.Op != arpReply && p.Op != arpRequest {
+			continue
+		}
+		r.mu.Lock()
+		r.seen[p.SenderIP] = p.SenderMAC
+		for _, ch := range r.waiters[p.SenderIP] {
+			select {
+			case ch <- p.SenderMAC:
+			default:
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *arpResolver) close() error {
+	return r.link.Close()
+}
+
+// ipv4Prefixes returns the IPv4 addresses configured on iface along with
+// the length of their attached subnets.
+func ipv4Prefixes(iface *net.Interface) []netip.Prefix {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+	var out []netip.Prefix
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip, ok := netip.AddrFromSlice(ipNet.IP)
+		if !ok || !ip.Unmap().Is4() {
+			continue
+		}
+		ones, _ := ipNet.Mask.Size()
+		out = append(out, netip.PrefixFrom(ip.Unmap(), ones))
+	}
+	return out
+}
+
+// attachedPrefix returns the local prefix on iface whose subnet contains ip.
+func attachedPrefix(iface *net.Interface, ip netip.Addr) (netip.Prefix, bool) {
+	for _, local := range ipv4Prefixes(iface) {
+		if local.Masked().Contains(ip) {
+			return local, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// attachedInterface finds the interface whose IPv4 subnet contains ip.
+func attachedInterface(ip netip.Addr) (*net.Interface, netip.Prefix, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, netip.Prefix{}, err
+	}
+	for i := range ifaces {
+		iface := &ifaces[i]
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		if local, ok := attachedPrefix(iface, ip); ok {
+			return iface, local, nil
+		}
+	}
+	return nil, netip.Prefix{}, fmt.Errorf("%s is not on a directly attached subnet", ip)
+}
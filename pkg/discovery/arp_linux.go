@@ -0,0 +1,52 @@
+//go:build linux
+
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// packetLink is an AF_PACKET socket bound to one interface and to the ARP
+// ethertype.
+type packetLink struct {
+	f   *os.File
+	mac net.HardwareAddr
+}
+
+func openPacketLink(iface *net.Interface) (PacketLink, error) {
+	if len(iface.HardwareAddr) != 6 {
+		return nil, fmt.Errorf("interface %s is not ethernet", iface.Name)
+	}
+	proto := htons(etherTypeARP)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, int(proto))
+	if err != nil {
+		return nil, fmt.Errorf("open packet socket on %s: %w", iface.Name, err)
+	}
+	err = syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: proto, Ifindex: iface.Index})
+	if err == nil {
+		err = syscall.SetNonblock(fd, true)
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("bind packet socket to %s: %w", iface.Name, err)
+	}
+	return &packetLink{f: os.NewFile(uintptr(fd), "arp-"+iface.Name), mac: iface.HardwareAddr}, nil
+}
+
+func (l *packetLink) HardwareAddr() net.HardwareAddr { return l.mac }
+
+func (l *packetLink) ReadFrame(buf []byte) (int, error) { return l.f.Read(buf) }
+
+func (l *packetLink) WriteFrame(frame []byte) error {
+	_, err := l.f.Write(frame)
+	return err
+}
+
+func (l *packetLink) Close() error { return l.f.Close() }
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
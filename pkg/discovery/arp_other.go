@@ -0,0 +1,12 @@
+//go:build !linux
+
+package discovery
+
+import (
+	"fmt"
+	"net"
+)
+
+func openPacketLink(iface *net.Interface) (PacketLink, error) {
+	return nil, fmt.Errorf("arp sweep is only supported on linux")
+}
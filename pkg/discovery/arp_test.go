@@ -0,0 +1,171 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+// fakeLink answers ARP requests for a fixed set of neighbors.
+type fakeLink struct {
+	mac       net.HardwareAddr
+	neighbors map[netip.Addr]net.HardwareAddr
+	frames    chan []byte
+	closed    chan struct{}
+}
+
+func newFakeLink(neighbors map[string]string) *fakeLink {
+	l := &fakeLink{
+		mac:       net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01},
+		neighbors: map[netip.Addr]net.HardwareAddr{},
+		frames:    make(chan []byte, 16),
+		closed:    make(chan struct{}),
+	}
+	for ip, mac := range neighbors {
+		hw, _ := net.ParseMAC(mac)
+		l.neighbors[netip.MustParseAddr(ip)] = hw
+	}
+	return l
+}
+
+func (l *fakeLink) HardwareAddr() net.HardwareAddr { return l.mac }
+
+func (l *fakeLink) ReadFrame(buf []byte) (int, error) {
+	select {
+	case frame := <-l.frames:
+		return copy(buf, frame), nil
+	case <-l.closed:
+		return 0, errors.New("link closed")
+	}
+}
+
+func (l *fakeLink) WriteFrame(frame []byte) error {
+	req, ok := parseARPFrame(frame)
+	if !ok || req.Op != arpRequest {
+		return nil
+	}
+	mac, ok := l.neighbors[req.TargetIP]
+	if !ok {
+		return nil
+	}
+	l.frames <- marshalARPFrame(req.SenderMAC, arpPacket{
+		Op:        arpReply,
+		SenderMAC: mac,
+		SenderIP:  req.TargetIP,
+		TargetMAC: req.SenderMAC,
+		TargetIP:  req.SenderIP,
+	})
+	return nil
+}
+
+func (l *fakeLink) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func TestARPFrameRoundTrip(t *testing.T) {
+	want := arpPacket{
+		Op:        arpRequest,
+		SenderMAC: net.HardwareAddr{1, 2, 3, 4, 5, 6},
+		SenderIP:  netip.MustParseAddr("10.0.0.1"),
+		TargetMAC: net.HardwareAddr{0, 0, 0, 0, 0, 0},
+		TargetIP:  netip.MustParseAddr("10.0.0.2"),
+	}
+	frame := marshalARPFrame(broadcastMAC, want)
+	if len(frame) != arpFrameLen {
+		t.Fatalf("frame length %d want %d", len(frame), arpFrameLen)
+	}
+	got, ok := parseARPFrame(frame)
+	if !ok {
+		t.Fatalf("parseARPFrame rejected its own frame")
+	}
+	if got.Op != want.Op || got.SenderIP != want.SenderIP || got.TargetIP != want.TargetIP || got.SenderMAC.String() != want.SenderMAC.String() {
+		t.Fatalf("round trip mismatch: got %+v want %+v", got, want)
+	}
+}
+
+func TestARPResolver(t *testing.T) {
+	link := newFakeLink(map[string]string{"192.168.50.7": "aa:bb:cc:dd:ee:07"})
+	r := newARPResolver(link, netip.MustParsePrefix("192.168.50.2/24"))
+	defer r.close()
+
+	if r.covers(netip.MustParseAddr("192.168.51.7")) || r.covers(netip.MustParseAddr("192.168.50.2")) {
+		t.Fatalf("resolver must not cover foreign subnets or its own address")
+	}
+	mac, err := r.resolve(context.Background(), netip.MustParseAddr("192.168.50.7"), time.Second)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if mac.String() != "aa:bb:cc:dd:ee:07" {
+		t.Fatalf("resolved %s", mac)
+	}
+	if _, err := r.resolve(context.Background(), netip.MustParseAddr("192.168.50.8"), 50*time.Millisecond); err == nil {
+		t.Fatalf("expected timeout for silent host")
+	}
+}
+
+func TestScannerARPMarksFilteredHostAlive(t *testing.T) {
+	link := newFakeLink(map[string]string{"192.0.2.10": "aa:bb:cc:00:00:10"})
+	profile := config.Profile{Ports: []int{9}, TimeoutMS: 50, ARPSweep: true}
+	scanner := NewScanner(profile, nil, WithPacketLink(link, netip.MustParsePrefix("192.0.2.1/24")))
+	defer link.Close()
+
+	targets, err := scanner.CIDRTargets("192.0.2.8/30")
+	if err != nil {
+		t.Fatalf("CIDRTargets: %v", err)
+	}
+	alive := map[netip.Addr]HostResult{}
+	for res := range scanner.Stream(context.Background(), targets) {
+		if res.Alive {
+			alive[res.IP] = res
+		}
+	}
+	res, ok := alive[netip.MustParseAddr("192.0.2.10")]
+	if !ok || len(alive) != 1 {
+		t.Fatalf("expected only 192.0.2.10 alive, got %v", alive)
+	}
+	if res.MAC != "AA:BB:CC:00:00:10" {
+		t.Fatalf("MAC = %q", res.MAC)
+	}
+}
+
+func TestScannerARPSkipsOwnAddress(t *testing.T) {
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+	local, ok := attachedPrefix(lo, netip.MustParseAddr("127.0.0.1"))
+	if !ok {
+		t.Skip("loopback has no IPv4 address")
+	}
+	s := NewScanner(config.Profile{ARPInterface: lo.Name}, nil)
+	opened := 0
+	s.openLink = func(*net.Interface) (PacketLink, error) {
+		opened++
+		return newFakeLink(nil), nil
+	}
+	defer s.closeARP()
+
+	// Neither before nor after the subnet's link is open does the
+	// scanner's own address get a resolver of its own.
+	if r := s.arpFor(local.Addr()); r != nil || opened != 0 {
+		t.Fatalf("resolver %v for own address, %d links opened", r, opened)
+	}
+	peer := local.Addr().Next()
+	if s.arpFor(peer) == nil || opened != 1 {
+		t.Fatalf("no resolver for %s, %d links opened", peer, opened)
+	}
+	for i := 0; i < 3; i++ {
+		if r := s.arpFor(local.Addr()); r != nil {
+			t.Fatalf("resolver %v for own address", r)
+		}
+	}
+	if opened != 1 || len(s.arp) != 1 {
+		t.Fatalf("%d links opened, %d resolvers", opened, len(s.arp))
+	}
+}
@@ -0,0 +1,214 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+const (
+	dnsTypeA    = 1
+	dnsTypeSOA  = 6
+	dnsTypeAAAA = 28
+	dnsTypeAXFR = 252
+	dnsClassIN  = 1
+
+	// axfrTimeout bounds a whole zone transfer.
+	axfrTimeout = 2 * time.Minute
+)
+
+// addressRecord is an A or AAAA record taken from a zone.
+type addressRecord struct {
+	name string
+	addr netip.Addr
+}
+
+// transferZone requests zone from server over TCP and returns its address
+// records. server defaults to port 53.
+func transferZone(ctx context.Context, zone, server string) ([]addressRecord, error) {
+	zone = strings.TrimSuffix(zone, ".")
+	if zone == "" || server == "" {
+		return nil, errors.New("zone transfer needs a zone and a server")
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	ctx, cancel := context.WithTimeout(ctx, axfrTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, fmt.Errorf("axfr %s: %w", zone, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	query := marshalAXFRQuery(zone)
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, fmt.Errorf("axfr %s: %w", zone, err)
+	}
+
+	var records []addressRecord
+	soas := 0
+	for soas < 2 {
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, fmt.Errorf("axfr %s: %w", zone, err)
+		}
+		resp := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return nil, fmt.Errorf("axfr %s: %w", zone, err)
+		}
+		answers, err := parseDNSAnswers(resp)
+		if err != nil {
+			return nil, fmt.Errorf("axfr %s: %w", zone, err)
+		}
+		if len(answers) == 0 {
+			return nil, fmt.Errorf("axfr %s: empty response", zone)
+		}
+		for _, rr := range answers {
+			switch rr.rtype {
+			case dnsTypeSOA:
+				soas++
+			case dnsTypeA, dnsTypeAAAA:
+				if addr, ok := netip.AddrFromSlice(rr.data); ok && rr.class == dnsClassIN {
+					records = append(records, addressRecord{name: rr.name, addr: addr})
+				}
+			}
+		}
+	}
+	return records, nil
+}
+
+func marshalAXFRQuery(zone string) []byte {
+	msg := []byte{
+		0x67, 0x73, // ID
+		0x00, 0x00, // standard query
+		0x00, 0x01, // QDCOUNT
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	msg = append(msg, dnsName(strings.Split(zone, ".")...)...)
+	return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(msg, dnsTypeAXFR), dnsClassIN)
+}
+
+// dnsName encodes labels as an uncompressed DNS name.
+func dnsName(labels ...string) []byte {
+	var out []byte
+	for _, l := range labels {
+		out = append(out, byte(len(l)))
+		out = append(out, l...)
+	}
+	return append(out, 0)
+}
+
+// dnsRecord is a raw resource record from a DNS answer section.
+type dnsRecord struct {
+	name  string
+	rtype uint16
+	class uint16
+	data  []byte
+}
+
+var dnsRcodes = map[byte]string{
+	1: "format error",
+	2: "server failure",
+	3: "no such zone",
+	4: "not implemented",
+	5: "refused",
+	9: "server not authoritative",
+}
+
+// parseDNSAnswers returns the answer section of a DNS response.
+func parseDNSAnswers(msg []byte) ([]dnsRecord, error) {
+	if len(msg) < 12 {
+		return nil, errors.New("short dns message")
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		if text, ok := dnsRcodes[rcode]; ok {
+			return nil, fmt.Errorf("transfer %s", text)
+		}
+		return nil, fmt.Errorf("transfer failed with rcode %d", rcode)
+	}
+	qdcount := binary.BigEndian.Uint16(msg[4:])
+	ancount := binary.BigEndian.Uint16(msg[6:])
+	off := 12
+	for i := 0; i < int(qdcount); i++ {
+		_, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next + 4
+	}
+	records := make([]dnsRecord, 0, ancount)
+	for i := 0; i < int(ancount); i++ {
+		name, next, err := readDNSName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, errors.New("truncated resource record")
+		}
+		rr := dnsRecord{
+			name:  name,
+			rtype: binary.BigEndian.Uint16(msg[next:]),
+			class: binary.BigEndian.Uint16(msg[next+2:]),
+		}
+		rdlen := int(binary.BigEndian.Uint16(msg[next+8:]))
+		start := next + 10
+		if start+rdlen > len(msg) {
+			return nil, errors.New("truncated resource record")
+		}
+		rr.data = msg[start : start+rdlen]
+		records = append(records, rr)
+		off = start + rdlen
+	}
+	return records, nil
+}
+
+// readDNSName decodes the possibly compressed name at off and returns it
+// with the offset just past it.
+func readDNSName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errors.New("truncated name")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errors.New("truncated name pointer")
+			}
+			if jumps++; jumps > 32 {
+				return "", 0, errors.New("name compression loop")
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errors.New("truncated label")
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
@@ -2,35 +2,155 @@ package discovery
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net"
 	"net/netip"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nmasdoufi/goscanner/pkg/config"
 	"github.com/nmasdoufi/goscanner/pkg/logging"
+	"github.com/nmasdoufi/goscanner/pkg/service"
 )
 
 // HostResult describes liveness outcome.
 type HostResult struct {
-	IP        netip.Addr
+	IP netip.Addr
+	// Hostname is the name the address was resolved from when the target
+	// source was a hostname, a host file or a DNS zone.
+	Hostname string
+	// Site names the configured site the host was scanned for. The scanner
+	// leaves it empty; callers scanning a site's ranges set it.
+	Site      string
 	Alive     bool
 	OpenPorts map[int]time.Duration
+	// UDPPorts lists UDP ports that answered their protocol probe. They
+	// are kept apart from OpenPorts, which holds TCP ports only.
+	UDPPorts map[int]time.Duration
+	// UDPSilent lists UDP ports that neither answered their probe nor were
+	// reported closed: open or filtered. Agents drop requests they cannot
+	// authenticate, so such a port may still serve a client with the right
+	// credentials.
+	UDPSilent map[int]bool `json:",omitempty"`
 	MAC       string
-	LastError error
+	// Services identifies the service on open TCP ports when the profile
+	// enables service detection.
+	Services map[int]service.Info `json:",omitempty"`
+	// RTT and TTL are recorded from the ICMP reply when ICMP liveness
+	// probing is enabled and the host answered.
+	RTT       time.Duration
+	TTL       int
+	LastError error `json:"-"`
+}
+
+// Stats summarizes the work performed by a scanner.
+type Stats struct {
+	Probed      int
+	Excluded    int
+	Packets     int
+	Connections int
+	// Throttled is the cumulative time probes spent waiting on rate and
+	// subnet concurrency limits.
+	Throttled time.Duration
 }
 
 // Scanner performs network discovery.
 type Scanner struct {
-	profile config.Profile
-	logger  *logging.Logger
+	profile  config.Profile
+	logger   *logging.Logger
+	excluded *ExclusionList
+	tcp      bool
+	udpPorts []int
+	inflight chan struct{}
+	limiters []*Limiter
+	timeouts *adaptiveTimeouts
+	seed     uint64
+
+	// udpPayload returns the request sent to a UDP port.
+	udpPayload func(port int) ([]byte, bool)
+
+	probed    atomic.Int64
+	skipped   atomic.Int64
+	packets   atomic.Int64
+	conns     atomic.Int64
+	throttled atomic.Int64
+
+	// ping sends one ICMP request; tests replace it.
+	ping         func(ctx context.Context, ip netip.Addr, timeout time.Duration, timestamp bool) (icmpReply, error)
+	icmpDisabled atomic.Bool
+	icmpOnce     sync.Once
+	// timestampDisabled is set when timestamp requests cannot be sent
+	// while echo requests still can.
+	timestampDisabled atomic.Bool
+	timestampOnce     sync.Once
+
+	// openLink opens a packet link on an interface; tests replace it.
+	openLink    func(iface *net.Interface) (PacketLink, error)
+	arpMu       sync.Mutex
+	arp         []*arpResolver
+	arpInjected bool
+	arpFailed   map[string]bool
+}
+
+// ScannerOption configures the scanner.
+type ScannerOption func(*Scanner)
+
+// WithExclusions prevents the scanner from sending any packet to addresses
+// matched by the list.
+func WithExclusions(list *ExclusionList) ScannerOption {
+	return func(s *Scanner) {
+		s.excluded = list
+	}
+}
+
+// WithLimiter enforces limiter in addition to the profile's own limits.
+// Share one limiter across scanners to apply a global budget.
+func WithLimiter(limiter *Limiter) ScannerOption {
+	return func(s *Scanner) {
+		if limiter != nil {
+			s.limiters = append(s.limiters, limiter)
+		}
+	}
+}
+
+// WithTargetSeed fixes the order in which a randomized profile visits
+// targets, so that an interrupted scan can be replayed in the same order.
+// Zero keeps the order random.
+func WithTargetSeed(seed uint64) ScannerOption {
+	return func(s *Scanner) {
+		s.seed = seed
+	}
+}
+
+// WithUDPPayloads sends payloads[port] to the listed UDP ports instead of
+// the built-in requests, for services listening off their usual port.
+func WithUDPPayloads(payloads map[int][]byte) ScannerOption {
+	return func(s *Scanner) {
+		builtin := s.udpPayload
+		s.udpPayload = func(port int) ([]byte, bool) {
+			if payload, ok := payloads[port]; ok {
+				return payload, true
+			}
+			return builtin(port)
+		}
+	}
+}
+
+// WithPacketLink sends ARP requests through link instead of opening a
+// packet socket. local is the scanner's address on the link along with the
+// attached subnet length, e.g. 192.168.1.10/24. The caller owns link.
+func WithPacketLink(link PacketLink, local netip.Prefix) ScannerOption {
+	return func(s *Scanner) {
+		s.arp = append(s.arp, newARPResolver(link, local))
+		s.arpInjected = true
+	}
 }
 
 // NewScanner constructs scanner for profile.
-func NewScanner(profile config.Profile, logger *logging.Logger) *Scanner {
+func NewScanner(profile config.Profile, logger *logging.Logger, opts ...ScannerOption) *Scanner {
 	if profile.MaxWorkers == 0 {
 		profile.MaxWorkers = 64
 	}
@@ -40,21 +160,103 @@ func NewScanner(profile config.Profile, logger *logging.Logger) *Scanner {
 	if len(profile.Ports) == 0 {
 		profile.Ports = []int{22, 80, 443, 135, 139, 445, 3389, 161}
 	}
-	return &Scanner{profile: profile, logger: logger}
+	switch profile.Liveness {
+	case "":
+		profile.Liveness = LivenessTCP
+	case LivenessTCP, LivenessICMP, LivenessAny:
+	default:
+		logger.Errorf("profile liveness %q not supported, using %s", profile.Liveness, LivenessTCP)
+		profile.Liveness = LivenessTCP
+	}
+	if profile.MaxInflight <= 0 {
+		profile.MaxInflight = 4 * profile.MaxWorkers
+	}
+	if profile.ServiceTimeoutMS <= 0 {
+		profile.ServiceTimeoutMS = 2000
+	}
+	s := &Scanner{
+		profile:  profile,
+		logger:   logger,
+		inflight: make(chan struct{}, profile.MaxInflight),
+		timeouts: newAdaptiveTimeouts(profile.AdaptiveTimeout,
+			time.Duration(profile.TimeoutMS)*time.Millisecond,
+			time.Duration(profile.MinTimeoutMS)*time.Millisecond,
+			time.Duration(profile.MaxTimeoutMS)*time.Millisecond),
+		udpPayload: service.UDPPayload,
+		ping:       ping,
+		openLink:   openPacketLink,
+		arpFailed:  map[string]bool{},
+	}
+	if limiter := NewLimiter(profile.RateLimit); limiter != nil {
+		s.limiters = append(s.limiters, limiter)
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	protocols := profile.Protocols
+	if len(protocols) == 0 {
+		protocols = []string{"tcp"}
+	}
+	for _, proto := range protocols {
+		switch strings.ToLower(proto) {
+		case "tcp":
+			s.tcp = true
+		case "udp":
+			s.udpPorts = udpPorts(profile.Ports, profile.UDPPorts)
+		default:
+			logger.Errorf("profile protocol %q not supported, ignoring", proto)
+		}
+	}
+	return s
+}
+
+// Stats returns counters accumulated across all scans run by s.
+func (s *Scanner) Stats() Stats {
+	return Stats{
+		Probed:      int(s.probed.Load()),
+		Excluded:    int(s.skipped.Load()),
+		Packets:     int(s.packets.Load()),
+		Connections: int(s.conns.Load()),
+		Throttled:   time.Duration(s.throttled.Load()),
+	}
+}
+
+// Targets yields the addresses a scan should probe.
+type Targets interface {
+	Next() (netip.Addr, bool)
+}
+
+// CIDRTargets returns a lazy iterator over the hosts of cidr, honoring the
+// profile's ordering and IPv6 seeding settings.
+func (s *Scanner) CIDRTargets(cidr string) (Targets, error) {
+	return newCIDRIterator(cidr, s.profile, s.seed)
 }
 
 // ScanCIDR enumerates a CIDR range and tests hosts.
 func (s *Scanner) ScanCIDR(ctx context.Context, cidr string) ([]HostResult, error) {
-	ips, err := expandCIDR(cidr)
+	targets, err := s.CIDRTargets(cidr)
 	if err != nil {
 		return nil, err
 	}
-	timeout := time.Duration(s.profile.TimeoutMS) * time.Millisecond
+	results := []HostResult{}
+	for res := range s.Stream(ctx, targets) {
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// Stream probes targets with the worker pool and emits each host as soon as
+// its probes complete. The channel is closed once every target has been
+// handled or ctx is done.
+func (s *Scanner) Stream(ctx context.Context, targets Targets) <-chan HostResult {
 	workerCount := s.profile.MaxWorkers
+	if workerCount <= 0 {
+		workerCount = 64
+	}
 	jobs := make(chan netip.Addr)
-	results := make([]HostResult, 0, len(ips))
-	var mu sync.Mutex
+	results := make(chan HostResult, workerCount)
 	var wg sync.WaitGroup
+	named, _ := targets.(NamedTargets)
 
 	worker := func() {
 		defer wg.Done()
@@ -64,63 +266,223 @@ func (s *Scanner) ScanCIDR(ctx context.Context, cidr string) ([]HostResult, erro
 				return
 			default:
 			}
-			res := HostResult{IP: ip, OpenPorts: map[int]time.Duration{}}
-			for _, port := range s.profile.Ports {
-				start := time.Now()
-				addr := net.JoinHostPort(ip.String(), fmt.Sprintf("%d", port))
-				conn, err := net.DialTimeout("tcp", addr, timeout)
-				if err == nil {
-					res.Alive = true
-					res.OpenPorts[port] = time.Since(start)
-					conn.Close()
-				}
+			release, err := s.enterSubnet(ctx, ip)
+			if err != nil {
+				return
 			}
-
-			// Attempt to get MAC address if host is alive
-			if res.Alive {
-				res.MAC = getMACAddress(ip.String())
+			res := s.probeHost(ctx, ip)
+			release()
+			if named != nil {
+				res.Hostname = named.Name(ip)
+			}
+			// Probes cut short by cancellation say nothing about the host.
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case results <- res:
 			}
-
-			mu.Lock()
-			results = append(results, res)
-			mu.Unlock()
 		}
 	}
 
-	if workerCount <= 0 {
-		workerCount = 64
-	}
 	wg.Add(workerCount)
 	for i := 0; i < workerCount; i++ {
 		go worker()
 	}
 	go func() {
 		defer close(jobs)
-		for _, ip := range ips {
+		for {
+			ip, ok := targets.Next()
+			if !ok {
+				return
+			}
+			if s.excluded.Contains(ip) {
+				s.skipped.Add(1)
+				s.logger.Debugf("skipping blacklisted host %s", ip)
+				continue
+			}
 			select {
 			case <-ctx.Done():
 				return
 			case jobs <- ip:
+				s.probed.Add(1)
 			}
 		}
 	}()
-	wg.Wait()
-	return results, nil
+	go func() {
+		wg.Wait()
+		s.closeARP()
+		s.logTimeouts()
+		close(results)
+	}()
+	return results
+}
+
+// probeHost runs the profile's probes against a single address.
+func (s *Scanner) probeHost(ctx context.Context, ip netip.Addr) HostResult {
+	res := HostResult{IP: ip, OpenPorts: map[int]time.Duration{}, UDPPorts: map[int]time.Duration{}}
+
+	// An ARP reply proves the host is up even when every port is filtered.
+	if s.profile.ARPSweep {
+		if r := s.arpFor(ip); r != nil && s.throttle(ctx, false) == nil {
+			if mac, err := r.resolve(ctx, ip, s.timeouts.timeout(ip)); err == nil {
+				res.Alive = true
+				res.MAC = normalizeMAC(mac.String())
+			}
+		}
+	}
+
+	liveness := s.profile.Liveness
+	if liveness != LivenessTCP && !s.icmpDisabled.Load() {
+		reply, err := s.probeICMP(ctx, ip)
+		if err == nil {
+			res.Alive = true
+			res.RTT = reply.RTT
+			res.TTL = reply.TTL
+		} else {
+			res.LastError = err
+		}
+	}
+	// Without a usable ICMP socket the TCP criterion is the only one left.
+	if s.icmpDisabled.Load() {
+		liveness = LivenessTCP
+	}
+	if liveness == LivenessICMP && !res.Alive {
+		return res
+	}
+
+	if !(res.Alive && s.profile.StopOnAlive) {
+		s.probePorts(ctx, ip, &res)
+	}
+
+	// Attempt to get MAC address if host is alive
+	if res.Alive && res.MAC == "" {
+		res.MAC = getMACAddress(ip.String())
+	}
+	return res
 }
 
-func expandCIDR(cidr string) ([]netip.Addr, error) {
-	prefix, err := netip.ParsePrefix(cidr)
+// arpFor returns the resolver for the attached subnet containing ip, opening
+// a packet link on first use. It returns nil for routed destinations and for
+// the scanner's own addresses.
+func (s *Scanner) arpFor(ip netip.Addr) *arpResolver {
+	s.arpMu.Lock()
+	defer s.arpMu.Unlock()
+	for _, r := range s.arp {
+		if r.local.Addr() == ip {
+			return nil
+		}
+		if r.covers(ip) {
+			return r
+		}
+	}
+	if s.arpInjected || !ip.Is4() {
+		return nil
+	}
+
+	var iface *net.Interface
+	var local netip.Prefix
+	if s.profile.ARPInterface != "" {
+		var err error
+		iface, err = net.InterfaceByName(s.profile.ARPInterface)
+		if err != nil {
+			if !s.arpFailed[s.profile.ARPInterface] {
+				s.arpFailed[s.profile.ARPInterface] = true
+				s.logger.Errorf("arp interface %s unavailable: %v", s.profile.ARPInterface, err)
+			}
+			return nil
+		}
+		var ok bool
+		if local, ok = attachedPrefix(iface, ip); !ok {
+			return nil
+		}
+	} else {
+		var err error
+		if iface, local, err = attachedInterface(ip); err != nil {
+			return nil
+		}
+	}
+	if s.arpFailed[iface.Name] || local.Addr() == ip {
+		return nil
+	}
+	link, err := s.openLink(iface)
 	if err != nil {
-		return nil, fmt.Errorf("parse cidr %s: %w", cidr, err)
+		s.arpFailed[iface.Name] = true
+		s.logger.Errorf("arp sweep disabled on %s: %v", iface.Name, err)
+		return nil
 	}
-	var ips []netip.Addr
-	for addr := prefix.Masked().Addr(); prefix.Contains(addr); addr = addr.Next() {
-		ips = append(ips, addr)
-		if !addr.Next().IsValid() {
-			break
+	s.logger.Debugf("arp sweeping %s from %s", local.Masked(), iface.Name)
+	r := newARPResolver(link, local)
+	s.arp = append(s.arp, r)
+	return r
+}
+
+// closeARP releases packet links opened by the scanner itself.
+func (s *Scanner) closeARP() {
+	s.arpMu.Lock()
+	defer s.arpMu.Unlock()
+	if s.arpInjected {
+		return
+	}
+	for _, r := range s.arp {
+		r.close()
+	}
+	s.arp = nil
+}
+
+// probeICMP sends echo requests and, if configured and every echo went
+// unanswered, timestamp requests. Socket errors disable ICMP probing for
+// the rest of the scan, or only timestamp probing when they come from a
+// timestamp request.
+func (s *Scanner) probeICMP(ctx context.Context, ip netip.Addr) (icmpReply, error) {
+	var reply icmpReply
+	send := func(timestamp bool) (probeOutcome, error) {
+		var lastErr error
+		_, outcome := s.attempt(ctx, ip, false, func(timeout time.Duration) (time.Duration, probeOutcome) {
+			r, err := s.ping(ctx, ip, timeout, timestamp)
+			lastErr = err
+			switch {
+			case err == nil:
+				reply = r
+				return r.RTT, outcomeOpen
+			case errors.Is(err, errNoReply):
+				return 0, outcomeSilent
+			default:
+				return 0, outcomeFailed
+			}
+		})
+		return outcome, lastErr
+	}
+
+	outcome, err := send(false)
+	if outcome == outcomeOpen {
+		return reply, nil
+	}
+	var opErr *icmpSocketError
+	if outcome == outcomeSilent && s.profile.ICMPTimestamp && ip.Is4() && !s.timestampDisabled.Load() {
+		tsOutcome, tsErr := send(true)
+		if tsOutcome == outcomeOpen {
+			return reply, nil
+		}
+		if errors.As(tsErr, &opErr) {
+			s.timestampOnce.Do(func() {
+				s.logger.Errorf("icmp timestamp probing disabled, sending echo requests only: %v", tsErr)
+			})
+			s.timestampDisabled.Store(true)
 		}
 	}
-	return ips, nil
+	if errors.As(err, &opErr) {
+		s.icmpOnce.Do(func() {
+			s.logger.Errorf("icmp probing disabled, falling back to tcp liveness: %v", err)
+		})
+		s.icmpDisabled.Store(true)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return icmpReply{}, err
 }
 
 // getMACAddress attempts to retrieve MAC address for an IP via ARP
@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+func TestStreamReportsOpenPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	scanner := NewScanner(config.Profile{Ports: []int{port}, TimeoutMS: 500}, nil)
+	targets, err := scanner.CIDRTargets("127.0.0.1/32")
+	if err != nil {
+		t.Fatalf("CIDRTargets: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var results []HostResult
+	for res := range scanner.Stream(ctx, targets) {
+		results = append(results, res)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results want 1", len(results))
+	}
+	if !results[0].Alive {
+		t.Fatalf("expected 127.0.0.1 to be alive")
+	}
+	if _, ok := results[0].OpenPorts[port]; !ok {
+		t.Fatalf("expected port %d to be open, got %v", port, results[0].OpenPorts)
+	}
+	if stats := scanner.Stats(); stats.Probed != 1 || stats.Excluded != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestStreamSkipsExcludedHosts(t *testing.T) {
+	exclusions, err := ParseExclusions([]string{"127.0.0.0/30"})
+	if err != nil {
+		t.Fatalf("ParseExclusions: %v", err)
+	}
+	scanner := NewScanner(config.Profile{Ports: []int{1}, TimeoutMS: 100}, nil, WithExclusions(exclusions))
+	targets, err := scanner.CIDRTargets("127.0.0.0/29")
+	if err != nil {
+		t.Fatalf("CIDRTargets: %v", err)
+	}
+	for res := range scanner.Stream(context.Background(), targets) {
+		if exclusions.Contains(res.IP) {
+			t.Fatalf("excluded host %s was probed", res.IP)
+		}
+	}
+	if stats := scanner.Stats(); stats.Excluded != 3 || stats.Probed != 3 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestStreamDetectsServices(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	scanner := NewScanner(config.Profile{Ports: []int{port}, TimeoutMS: 500, ServiceDetection: true}, nil)
+	targets, _ := scanner.CIDRTargets("127.0.0.1/32")
+	for res := range scanner.Stream(context.Background(), targets) {
+		svc := res.Services[port]
+		if svc.Name != "ssh" || svc.Product != "OpenSSH" || svc.Version != "9.6" {
+			t.Fatalf("service on %d: %+v", port, svc)
+		}
+	}
+}
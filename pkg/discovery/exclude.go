@@ -0,0 +1,115 @@
+package discovery
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ExclusionList holds addresses that must never be probed.
+type ExclusionList struct {
+	prefixes []netip.Prefix
+	ranges   []addrRange
+}
+
+type addrRange struct {
+	from netip.Addr
+	to   netip.Addr
+}
+
+// ParseExclusions builds an exclusion list from blacklist entries.
+// Each entry is a single IP ("10.0.0.1"), a CIDR ("10.0.0.0/28") or an
+// inclusive dash range ("10.0.0.10-10.0.0.20").
+func ParseExclusions(entries ...[]string) (*ExclusionList, error) {
+	list := &ExclusionList{}
+	for _, group := range entries {
+		for _, raw := range group {
+			if err := list.add(raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+func (l *ExclusionList) add(raw string) error {
+	entry := strings.TrimSpace(raw)
+	if entry == "" {
+		return nil
+	}
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return fmt.Errorf("parse blacklist cidr %s: %w", entry, err)
+		}
+		l.prefixes = append(l.prefixes, unmapPrefix(prefix).Masked())
+		return nil
+	}
+	if idx := strings.Index(entry, "-"); idx >= 0 {
+		// Contains unmaps addresses, so IPv4-mapped entries are stored
+		// as IPv4 to match them.
+		from, err := netip.ParseAddr(strings.TrimSpace(entry[:idx]))
+		if err != nil {
+			return fmt.Errorf("parse blacklist range %s: %w", entry, err)
+		}
+		to, err := netip.ParseAddr(strings.TrimSpace(entry[idx+1:]))
+		if err != nil {
+			return fmt.Errorf("parse blacklist range %s: %w", entry, err)
+		}
+		from, to = from.Unmap(), to.Unmap()
+		if from.BitLen() != to.BitLen() {
+			return fmt.Errorf("blacklist range %s mixes address families", entry)
+		}
+		if to.Less(from) {
+			from, to = to, from
+		}
+		l.ranges = append(l.ranges, addrRange{from: from, to: to})
+		return nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return fmt.Errorf("parse blacklist address %s: %w", entry, err)
+	}
+	addr = addr.Unmap()
+	l.prefixes = append(l.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	return nil
+}
+
+// unmapPrefix turns an IPv4-mapped IPv6 prefix ("::ffff:10.0.0.0/104") into
+// the IPv4 prefix it covers.
+func unmapPrefix(prefix netip.Prefix) netip.Prefix {
+	if !prefix.Addr().Is4In6() || prefix.Bits() < 96 {
+		return prefix
+	}
+	return netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+}
+
+// Contains reports whether addr is excluded.
+func (l *ExclusionList) Contains(addr netip.Addr) bool {
+	if l == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range l.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, r := range l.ranges {
+		if r.from.BitLen() != addr.BitLen() {
+			continue
+		}
+		if !addr.Less(r.from) && !r.to.Less(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of entries in the list.
+func (l *ExclusionList) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.prefixes) + len(l.ranges)
+}
@@ -0,0 +1,70 @@
+package discovery
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestExclusionListContains(t *testing.T) {
+	list, err := ParseExclusions(
+		[]string{"192.168.1.1", "10.0.0.0/30"},
+		[]string{"172.16.0.10-172.16.0.20", " ", "2001:db8::1"},
+	)
+	if err != nil {
+		t.Fatalf("ParseExclusions: %v", err)
+	}
+	cases := map[string]bool{
+		"192.168.1.1":     true,
+		"192.168.1.2":     false,
+		"10.0.0.3":        true,
+		"10.0.0.4":        false,
+		"172.16.0.10":     true,
+		"172.16.0.15":     true,
+		"172.16.0.20":     true,
+		"172.16.0.21":     false,
+		"2001:db8::1":     true,
+		"2001:db8::2":     false,
+		"::ffff:10.0.0.1": true,
+	}
+	for raw, want := range cases {
+		if got := list.Contains(netip.MustParseAddr(raw)); got != want {
+			t.Fatalf("Contains(%s)=%v want %v", raw, got, want)
+		}
+	}
+}
+
+func TestExclusionListMappedEntries(t *testing.T) {
+	list, err := ParseExclusions([]string{"::ffff:10.0.0.1", "::ffff:192.168.0.0/120", "::ffff:172.16.0.10-::ffff:172.16.0.20"})
+	if err != nil {
+		t.Fatalf("ParseExclusions: %v", err)
+	}
+	cases := map[string]bool{
+		"10.0.0.1":          true,
+		"::ffff:10.0.0.1":   true,
+		"10.0.0.2":          false,
+		"192.168.0.255":     true,
+		"192.168.1.0":       false,
+		"172.16.0.15":       true,
+		"::ffff:172.16.0.9": false,
+	}
+	for raw, want := range cases {
+		if got := list.Contains(netip.MustParseAddr(raw)); got != want {
+			t.Fatalf("Contains(%s)=%v want %v", raw, got, want)
+		}
+	}
+}
+
+func TestParseExclusionsInvalid(t *testing.T) {
+	for _, entry := range []string{"10.0.0.300", "10.0.0.0/33", "10.0.0.1-2001:db8::1", "host-name"} {
+		if _, err := ParseExclusions([]string{entry}); err == nil {
+			t.Fatalf("expected error for %q", entry)
+		}
+	}
+}
+
+func TestNilExclusionList(t *testing.T) {
+	var list *ExclusionList
+	if list.Contains(netip.MustParseAddr("10.0.0.1")) {
+		t.Fatalf("nil list must not exclude anything")
+	}
+}
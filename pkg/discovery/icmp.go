@@ -0,0 +1,233 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+// ICMP message types used for liveness probing.
+const (
+	icmpEchoReply        = 0
+	icmpEchoRequest      = 8
+	icmpTimestampRequest = 13
+	icmpTimestampReply   = 14
+	icmpv6EchoRequest    = 128
+	icmpv6EchoReply      = 129
+)
+
+// Liveness criteria accepted by Profile.Liveness.
+const (
+	LivenessTCP  = "tcp"
+	LivenessICMP = "icmp"
+	LivenessAny  = "any"
+)
+
+var icmpSeq atomic.Uint32
+
+// errNoReply reports an ICMP request that went unanswered.
+var errNoReply = errors.New("no reply")
+
+// icmpReply describes a successful ICMP exchange.
+type icmpReply struct {
+	RTT time.Duration
+	TTL int
+}
+
+// icmpSocketError reports that no ICMP socket could be opened, typically
+// for lack of privileges.
+type icmpSocketError struct {
+	err error
+}
+
+func (e *icmpSocketError) Error() string { return e.err.Error() }
+func (e *icmpSocketError) Unwrap() error { return e.err }
+
+// icmpConn wraps an ICMP socket. Datagram sockets let the kernel own the
+// echo identifier; raw sockets see every ICMP packet received by the host.
+type icmpConn struct {
+	pc    net.PacketConn
+	v6    bool
+	dgram bool
+}
+
+func (c *icmpConn) Close() error {
+	return c.pc.Close()
+}
+
+func (c *icmpConn) writeTo(b []byte, ip netip.Addr) error {
+	var dst net.Addr
+	if c.dgram {
+		dst = &net.UDPAddr{IP: ip.AsSlice(), Zone: ip.Zone()}
+	} else {
+		dst = &net.IPAddr{IP: ip.AsSlice(), Zone: ip.Zone()}
+	}
+	_, err := c.pc.WriteTo(b, dst)
+	return err
+}
+
+// read returns the next ICMP message, its sender and, when the platform
+// reports it, the TTL or hop limit it arrived with.
+func (c *icmpConn) read(buf []byte) ([]byte, netip.Addr, int, error) {
+	oob := make([]byte, 128)
+	var n, oobn int
+	var from netip.Addr
+	switch pc := c.pc.(type) {
+	case *net.UDPConn:
+		var addr *net.UDPAddr
+		var err error
+		n, oobn, _, addr, err = pc.ReadMsgUDP(buf, oob)
+		if err != nil {
+			return nil, netip.Addr{}, 0, err
+		}
+		from = addr.AddrPort().Addr()
+	case *net.IPConn:
+		var addr *net.IPAddr
+		var err error
+		n, oobn, _, addr, err = pc.ReadMsgIP(buf, oob)
+		if err != nil {
+			return nil, netip.Addr{}, 0, err
+		}
+		from, _ = netip.AddrFromSlice(addr.IP)
+	default:
+		var addr net.Addr
+		var err error
+		n, addr, err = c.pc.ReadFrom(buf)
+		if err != nil {
+			return nil, netip.Addr{}, 0, err
+		}
+		if ipAddr, ok := addr.(*net.IPAddr); ok {
+			from, _ = netip.AddrFromSlice(ipAddr.IP)
+		}
+	}
+	msg := buf[:n]
+	ttl := controlTTL(oob[:oobn])
+	// Raw IPv4 sockets deliver the IP header along with the message.
+	if !c.v6 && !c.dgram && len(msg) >= 20 && msg[0]>>4 == 4 {
+		ihl := int(msg[0]&0x0f) * 4
+		if len(msg) < ihl {
+			return nil, netip.Addr{}, 0, fmt.Errorf("short ipv4 header")
+		}
+		ttl = int(msg[8])
+		msg = msg[ihl:]
+	}
+	return msg, from.Unmap(), ttl, nil
+}
+
+// ping sends an ICMP echo request, or a timestamp request when timestamp is
+// set, and waits for the matching reply until the deadline.
+func ping(ctx context.Context, ip netip.Addr, timeout time.Duration, timestamp bool) (icmpReply, error) {
+	v6 := ip.Is6() && !ip.Is4In6()
+	if timestamp && v6 {
+		return icmpReply{}, fmt.Errorf("icmp timestamp not available over ipv6")
+	}
+	conn, err := listenICMP(v6, timestamp)
+	if err != nil {
+		return icmpReply{}, &icmpSocketError{err: err}
+	}
+	defer conn.Close()
+
+	id := uint16(rand.Uint32())
+	seq := uint16(icmpSeq.Add(1))
+	var msg []byte
+	switch {
+	case timestamp:
+		msg = marshalICMPTimestamp(id, seq, time.Now())
+	case v6:
+		msg = marshalICMPEcho(icmpv6EchoRequest, id, seq)
+	default:
+		msg = marshalICMPEcho(icmpEchoRequest, id, seq)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.pc.SetReadDeadline(deadline); err != nil {
+		return icmpReply{}, err
+	}
+	start := time.Now()
+	if err := conn.writeTo(msg, ip.Unmap()); err != nil {
+		return icmpReply{}, fmt.Errorf("send icmp to %s: %w", ip, err)
+	}
+
+	want := byte(icmpEchoReply)
+	if timestamp {
+		want = icmpTimestampReply
+	} else if v6 {
+		want = icmpv6EchoReply
+	}
+	buf := make([]byte, 1500)
+	for {
+		reply, from, ttl, err := conn.read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return icmpReply{}, fmt.Errorf("icmp to %s: %w", ip, errNoReply)
+			}
+			return icmpReply{}, err
+		}
+		if from != ip.Unmap() {
+			continue
+		}
+		typ, replyID, replySeq, ok := parseICMPHeader(reply)
+		if !ok || typ != want || replySeq != seq {
+			continue
+		}
+		// The kernel rewrites the identifier of datagram sockets.
+		if !conn.dgram && replyID != id {
+			continue
+		}
+		return icmpReply{RTT: time.Since(start), TTL: ttl}, nil
+	}
+}
+
+func marshalICMPEcho(typ byte, id, seq uint16) []byte {
+	b := make([]byte, 8+16)
+	b[0] = typ
+	binary.BigEndian.PutUint16(b[4:], id)
+	binary.BigEndian.PutUint16(b[6:], seq)
+	copy(b[8:], "goscanner-probe!")
+	binary.BigEndian.PutUint16(b[2:], icmpChecksum(b))
+	return b
+}
+
+func marshalICMPTimestamp(id, seq uint16, now time.Time) []byte {
+	b := make([]byte, 20)
+	b[0] = icmpTimestampRequest
+	binary.BigEndian.PutUint16(b[4:], id)
+	binary.BigEndian.PutUint16(b[6:], seq)
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	binary.BigEndian.PutUint32(b[8:], uint32(now.Sub(midnight).Milliseconds()))
+	binary.BigEndian.PutUint16(b[2:], icmpChecksum(b))
+	return b
+}
+
+func parseICMPHeader(b []byte) (typ byte, id, seq uint16, ok bool) {
+	if len(b) < 8 {
+		return 0, 0, 0, false
+	}
+	return b[0], binary.BigEndian.Uint16(b[4:]), binary.BigEndian.Uint16(b[6:]), true
+}
+
+// icmpChecksum computes the RFC 1071 internet checksum.
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
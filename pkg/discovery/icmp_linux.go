@@ -0,0 +1,104 @@
+//go:build linux
+
+package discovery
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenICMP opens an unprivileged ICMP datagram socket when the kernel
+// allows it (net.ipv4.ping_group_range) and falls back to a raw socket.
+// Ping sockets only carry echo requests, so rawOnly forces a raw socket.
+func listenICMP(v6, rawOnly bool) (*icmpConn, error) {
+	if !rawOnly {
+		if conn, err := listenICMPDatagram(v6); err == nil {
+			return conn, nil
+		}
+	}
+	network, addr := "ip4:icmp", "0.0.0.0"
+	if v6 {
+		network, addr = "ip6:ipv6-icmp", "::"
+	}
+	pc, err := net.ListenPacket(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("open icmp socket (unprivileged and raw): %w", err)
+	}
+	if v6 {
+		if raw, ok := pc.(*net.IPConn); ok {
+			if err := setRecvHopLimit(raw); err != nil {
+				pc.Close()
+				return nil, err
+			}
+		}
+	}
+	return &icmpConn{pc: pc, v6: v6}, nil
+}
+
+func listenICMPDatagram(v6 bool) (*icmpConn, error) {
+	family, proto := syscall.AF_INET, syscall.IPPROTO_ICMP
+	var sa syscall.Sockaddr = &syscall.SockaddrInet4{}
+	if v6 {
+		family, proto = syscall.AF_INET6, syscall.IPPROTO_ICMPV6
+		sa = &syscall.SockaddrInet6{}
+	}
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, err
+	}
+	if v6 {
+		err = syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_RECVHOPLIMIT, 1)
+	} else {
+		err = syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_RECVTTL, 1)
+	}
+	if err == nil {
+		err = syscall.Bind(fd, sa)
+	}
+	if err == nil {
+		err = syscall.SetNonblock(fd, true)
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "icmp")
+	pc, err := net.FilePacketConn(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &icmpConn{pc: pc, v6: v6, dgram: true}, nil
+}
+
+func setRecvHopLimit(conn *net.IPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_RECVHOPLIMIT, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// controlTTL extracts the IP_TTL or IPV6_HOPLIMIT ancillary value.
+func controlTTL(oob []byte) int {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, m := range msgs {
+		isTTL := m.Header.Level == syscall.IPPROTO_IP && m.Header.Type == syscall.IP_TTL
+		isHops := m.Header.Level == syscall.IPPROTO_IPV6 && m.Header.Type == syscall.IPV6_HOPLIMIT
+		if (isTTL || isHops) && len(m.Data) >= 4 {
+			return int(int32(binary.NativeEndian.Uint32(m.Data)))
+		}
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+//go:build !linux
+
+package discovery
+
+import (
+	"fmt"
+	"net"
+)
+
+// listenICMP opens a raw ICMP socket. Unprivileged datagram sockets are
+// only used on Linux.
+func listenICMP(v6, rawOnly bool) (*icmpConn, error) {
+	network, addr := "ip4:icmp", "0.0.0.0"
+	if v6 {
+		network, addr = "ip6:ipv6-icmp", "::"
+	}
+	pc, err := net.ListenPacket(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("open raw icmp socket: %w", err)
+	}
+	return &icmpConn{pc: pc, v6: v6}, nil
+}
+
+// controlTTL is not implemented on this platform; raw IPv4 replies still
+// carry the TTL in their IP header.
+func controlTTL(oob []byte) int {
+	return 0
+}
@@ -0,0 +1,32 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarshalICMPEcho(t *testing.T) {
+	msg := marshalICMPEcho(icmpEchoRequest, 0x1234, 7)
+	if icmpChecksum(msg) != 0 {
+		t.Fatalf("echo request checksum does not verify")
+	}
+	typ, id, seq, ok := parseICMPHeader(msg)
+	if !ok || typ != icmpEchoRequest || id != 0x1234 || seq != 7 {
+		t.Fatalf("parseICMPHeader = %d %#x %d %v", typ, id, seq, ok)
+	}
+}
+
+func TestMarshalICMPTimestamp(t *testing.T) {
+	now := time.Date(2024, 3, 1, 1, 0, 0, 500*int(time.Millisecond), time.UTC)
+	msg := marshalICMPTimestamp(1, 2, now)
+	if len(msg) != 20 || msg[0] != icmpTimestampRequest {
+		t.Fatalf("unexpected timestamp request % x", msg)
+	}
+	if icmpChecksum(msg) != 0 {
+		t.Fatalf("timestamp request checksum does not verify")
+	}
+	originate := uint32(msg[8])<<24 | uint32(msg[9])<<16 | uint32(msg[10])<<8 | uint32(msg[11])
+	if originate != 3600500 {
+		t.Fatalf("originate timestamp = %d want 3600500", originate)
+	}
+}
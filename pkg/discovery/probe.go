@@ -0,0 +1,169 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/service"
+)
+
+// probePorts fans the host's TCP and UDP port probes out concurrently. Each
+// probe holds a slot of the scanner-wide in-flight budget while its packet
+// is outstanding; the slot is taken before the probe's goroutine starts,
+// so a host with many ports does not park a goroutine per port. With
+// StopOnAlive, the first answer cancels the rest.
+func (s *Scanner) probePorts(ctx context.Context, ip netip.Addr, res *HostResult) {
+	hostCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	record := func(ports map[int]time.Duration, port int, rtt time.Duration) {
+		mu.Lock()
+		res.Alive = true
+		ports[port] = rtt
+		mu.Unlock()
+		if s.profile.StopOnAlive {
+			cancel()
+		}
+	}
+	// launch runs probe in its own goroutine once a slot is free. It
+	// reports false when the host's probing was cancelled first.
+	launch := func(probe func()) bool {
+		if !s.acquire(hostCtx) {
+			return false
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer s.release()
+			probe()
+		}()
+		return true
+	}
+	defer wg.Wait()
+
+	tcpPorts := s.profile.Ports
+	if !s.tcp {
+		tcpPorts = nil
+	}
+	for _, port := range tcpPorts {
+		launched := launch(func() {
+			var conn net.Conn
+			rtt, outcome := s.attempt(hostCtx, ip, true, func(timeout time.Duration) (time.Duration, probeOutcome) {
+				c, rtt, outcome := dialTCP(hostCtx, ip, port, timeout)
+				conn = c
+				return rtt, outcome
+			})
+			if outcome != outcomeOpen {
+				return
+			}
+			record(res.OpenPorts, port, rtt)
+			if !s.profile.ServiceDetection {
+				conn.Close()
+				return
+			}
+			info := service.Detect(hostCtx, conn, port, s.serviceDialer(ip, port),
+				time.Duration(s.profile.ServiceTimeoutMS)*time.Millisecond)
+			if info != (service.Info{}) {
+				mu.Lock()
+				if res.Services == nil {
+					res.Services = map[int]service.Info{}
+				}
+				res.Services[port] = info
+				mu.Unlock()
+			}
+		})
+		if !launched {
+			return
+		}
+	}
+	for _, port := range s.udpPorts {
+		launched := launch(func() {
+			rtt, outcome := s.attempt(hostCtx, ip, false, func(timeout time.Duration) (time.Duration, probeOutcome) {
+				payload, _ := s.udpPayload(port)
+				rtt, outcome, err := probeUDP(hostCtx, ip, port, payload, timeout)
+				if err != nil {
+					s.logger.Debugf("udp probe %s:%d: %v", ip, port, err)
+				}
+				return rtt, outcome
+			})
+			switch {
+			case outcome == outcomeOpen:
+				record(res.UDPPorts, port, rtt)
+			case outcome == outcomeSilent && hostCtx.Err() == nil:
+				mu.Lock()
+				if res.UDPSilent == nil {
+					res.UDPSilent = map[int]bool{}
+				}
+				res.UDPSilent[port] = true
+				mu.Unlock()
+			}
+		})
+		if !launched {
+			return
+		}
+	}
+}
+
+// acquire takes an in-flight slot, giving up when ctx is done.
+func (s *Scanner) acquire(ctx context.Context) bool {
+	select {
+	case s.inflight <- struct{}{}:
+		if ctx.Err() != nil {
+			s.release()
+			return false
+		}
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Scanner) release() {
+	<-s.inflight
+}
+
+// dialTCP attempts a full TCP connect and reports how long the host took
+// to accept or refuse it. The caller owns the returned connection.
+func dialTCP(ctx context.Context, ip netip.Addr, port int, timeout time.Duration) (net.Conn, time.Duration, probeOutcome) {
+	dialer := net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), fmt.Sprintf("%d", port)))
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, classifyError(err)
+	}
+	return conn, rtt, outcomeOpen
+}
+
+// serviceDialer opens the extra connections service detection needs,
+// under the scanner's rate limits.
+func (s *Scanner) serviceDialer(ip netip.Addr, port int) service.DialFunc {
+	return func(ctx context.Context) (net.Conn, error) {
+		if err := s.throttle(ctx, true); err != nil {
+			return nil, err
+		}
+		dialer := net.Dialer{Timeout: s.timeouts.timeout(ip)}
+		return dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), fmt.Sprintf("%d", port)))
+	}
+}
+
+// classifyError maps a probe error to its outcome.
+func classifyError(err error) probeOutcome {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return outcomeClosed
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return outcomeSilent
+	default:
+		return outcomeFailed
+	}
+}
@@ -0,0 +1,194 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+// listen returns the port of a loopback listener that counts the
+// connections it accepts and, when silent, keeps them open without a word.
+func listen(t *testing.T, accepted *atomic.Int32, silent bool) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted.Add(1)
+			if !silent {
+				conn.Close()
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestProbePortsAcquiresBeforeFanOut(t *testing.T) {
+	// The first port holds the only slot while service detection waits for
+	// a banner; the other probes must not be started meanwhile.
+	var accepted atomic.Int32
+	ports := []int{listen(t, &accepted, true)}
+	for i := 0; i < 200; i++ {
+		ports = append(ports, listen(t, new(atomic.Int32), false))
+	}
+	s := NewScanner(config.Profile{Ports: ports, TimeoutMS: 500, MaxInflight: 1, ServiceDetection: true, ServiceTimeoutMS: 300}, nil)
+
+	baseline := runtime.NumGoroutine()
+	res := HostResult{IP: netip.MustParseAddr("127.0.0.1"), OpenPorts: map[int]time.Duration{}, UDPPorts: map[int]time.Duration{}}
+	done := make(chan struct{})
+	go func() {
+		s.probePorts(context.Background(), res.IP, &res)
+		close(done)
+	}()
+	for accepted.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine() - baseline; n > 20 {
+		t.Fatalf("%d goroutines started while one probe holds the only slot", n)
+	}
+	<-done
+	if len(res.OpenPorts) != len(ports) {
+		t.Fatalf("%d of %d ports probed", len(res.OpenPorts), len(ports))
+	}
+	if len(s.inflight) != 0 {
+		t.Fatalf("%d slots leaked", len(s.inflight))
+	}
+}
+
+func TestProbePortsStopOnAlive(t *testing.T) {
+	var first, rest atomic.Int32
+	ports := []int{listen(t, &first, false)}
+	for i := 0; i < 5; i++ {
+		ports = append(ports, listen(t, &rest, false))
+	}
+	s := NewScanner(config.Profile{Ports: ports, TimeoutMS: 500, MaxInflight: 1, StopOnAlive: true}, nil)
+
+	res := HostResult{IP: netip.MustParseAddr("127.0.0.1"), OpenPorts: map[int]time.Duration{}, UDPPorts: map[int]time.Duration{}}
+	s.probePorts(context.Background(), res.IP, &res)
+	if _, ok := res.OpenPorts[ports[0]]; !ok || len(res.OpenPorts) != 1 || !res.Alive {
+		t.Fatalf("open ports %v, want only %d", res.OpenPorts, ports[0])
+	}
+	// Give stray connections time to be accepted.
+	time.Sleep(50 * time.Millisecond)
+	if n := rest.Load(); n != 0 {
+		t.Fatalf("%d ports probed after the host answered", n)
+	}
+	if len(s.inflight) != 0 {
+		t.Fatalf("%d slots leaked", len(s.inflight))
+	}
+}
+
+func TestProbeHostLiveness(t *testing.T) {
+	port := listen(t, new(atomic.Int32), false)
+	ip := netip.MustParseAddr("127.0.0.1")
+	reply := func(context.Context, netip.Addr, time.Duration, bool) (icmpReply, error) {
+		return icmpReply{RTT: time.Millisecond, TTL: 64}, nil
+	}
+	silent := func(context.Context, netip.Addr, time.Duration, bool) (icmpReply, error) {
+		return icmpReply{}, errNoReply
+	}
+	noSocket := func(context.Context, netip.Addr, time.Duration, bool) (icmpReply, error) {
+		return icmpReply{}, &icmpSocketError{errors.New("operation not permitted")}
+	}
+	for _, tc := range []struct {
+		name        string
+		liveness    string
+		stopOnAlive bool
+		ping        func(context.Context, netip.Addr, time.Duration, bool) (icmpReply, error)
+		alive       bool
+		rtt         bool
+		portsProbed bool
+	}{
+		{"tcp ignores icmp", LivenessTCP, false, reply, true, false, true},
+		{"unknown falls back to tcp", "ping", false, reply, true, false, true},
+		{"icmp reply", LivenessICMP, false, reply, true, true, true},
+		{"icmp silent skips ports", LivenessICMP, false, silent, false, false, false},
+		{"icmp without socket uses tcp", LivenessICMP, false, noSocket, true, false, true},
+		{"any silent uses ports", LivenessAny, false, silent, true, false, true},
+		{"any reply stops on alive", LivenessAny, true, reply, true, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewScanner(config.Profile{Ports: []int{port}, TimeoutMS: 500, Liveness: tc.liveness, StopOnAlive: tc.stopOnAlive}, nil)
+			pinged := false
+			s.ping = func(ctx context.Context, ip netip.Addr, timeout time.Duration, timestamp bool) (icmpReply, error) {
+				pinged = true
+				return tc.ping(ctx, ip, timeout, timestamp)
+			}
+			res := s.probeHost(context.Background(), ip)
+			if pinged != (s.profile.Liveness != LivenessTCP) {
+				t.Fatalf("pinged %v with liveness %q", pinged, s.profile.Liveness)
+			}
+			if res.Alive != tc.alive || (res.RTT > 0) != tc.rtt {
+				t.Fatalf("alive %v rtt %s", res.Alive, res.RTT)
+			}
+			if _, ok := res.OpenPorts[port]; ok != tc.portsProbed {
+				t.Fatalf("open ports %v", res.OpenPorts)
+			}
+		})
+	}
+}
+
+func TestProbePortsRecordsSilentUDP(t *testing.T) {
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer silent.Close()
+	closed, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	closed.Close()
+	silentPort := silent.LocalAddr().(*net.UDPAddr).Port
+	closedPort := closed.LocalAddr().(*net.UDPAddr).Port
+
+	s := NewScanner(config.Profile{Protocols: []string{"udp"}, UDPPorts: []int{silentPort, closedPort}, TimeoutMS: 200}, nil,
+		WithUDPPayloads(map[int][]byte{silentPort: []byte("hello"), closedPort: []byte("hello")}))
+	res := HostResult{IP: netip.MustParseAddr("127.0.0.1"), OpenPorts: map[int]time.Duration{}, UDPPorts: map[int]time.Duration{}}
+	s.probePorts(context.Background(), res.IP, &res)
+	if res.Alive || len(res.UDPPorts) != 0 {
+		t.Fatalf("alive %v udp ports %v", res.Alive, res.UDPPorts)
+	}
+	if len(res.UDPSilent) != 1 || !res.UDPSilent[silentPort] {
+		t.Fatalf("silent udp ports %v, want only %d", res.UDPSilent, silentPort)
+	}
+}
+
+func TestProbeICMPTimestampSocketError(t *testing.T) {
+	s := NewScanner(config.Profile{TimeoutMS: 200, Liveness: LivenessICMP, ICMPTimestamp: true}, nil)
+	var echoes, timestamps int
+	s.ping = func(ctx context.Context, ip netip.Addr, timeout time.Duration, timestamp bool) (icmpReply, error) {
+		if timestamp {
+			timestamps++
+			return icmpReply{}, &icmpSocketError{errors.New("operation not permitted")}
+		}
+		echoes++
+		return icmpReply{}, errNoReply
+	}
+	first, second := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")
+	if _, err := s.probeICMP(context.Background(), first); !errors.Is(err, errNoReply) {
+		t.Fatalf("probeICMP(%s) = %v, want the echo error", first, err)
+	}
+	if s.icmpDisabled.Load() || !s.timestampDisabled.Load() {
+		t.Fatalf("icmp disabled %v, timestamp disabled %v", s.icmpDisabled.Load(), s.timestampDisabled.Load())
+	}
+	echoes, timestamps = 0, 0
+	s.probeICMP(context.Background(), second)
+	if echoes == 0 || timestamps != 0 {
+		t.Fatalf("%d echo and %d timestamp requests sent to %s", echoes, timestamps, second)
+	}
+}
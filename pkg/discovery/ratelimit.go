@@ -0,0 +1,187 @@
+package discovery
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+// Limiter throttles the packets and new connections a scan may send and
+// caps how many hosts of the same subnet are probed at once. A single
+// Limiter can be shared by several scanners to enforce a global budget.
+// A nil *Limiter imposes no limits.
+type Limiter struct {
+	packets   *tokenBucket
+	conns     *tokenBucket
+	subnetCap int
+
+	mu      sync.Mutex
+	subnets map[netip.Prefix]chan struct{}
+}
+
+// NewLimiter builds a limiter from cfg. Zero values disable the matching
+// limit; nil is returned when nothing is limited.
+func NewLimiter(cfg config.RateLimit) *Limiter {
+	if cfg.PacketsPerSecond <= 0 && cfg.ConnectionsPerSecond <= 0 && cfg.SubnetConcurrency <= 0 {
+		return nil
+	}
+	return &Limiter{
+		packets:   newTokenBucket(cfg.PacketsPerSecond),
+		conns:     newTokenBucket(cfg.ConnectionsPerSecond),
+		subnetCap: cfg.SubnetConcurrency,
+		subnets:   map[netip.Prefix]chan struct{}{},
+	}
+}
+
+// waitPacket blocks until one more packet may be sent and returns the time
+// spent waiting.
+func (l *Limiter) waitPacket(ctx context.Context) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	return l.packets.wait(ctx)
+}
+
+// waitConn blocks until a new connection may be opened. The connection's
+// SYN also counts against the packet rate.
+func (l *Limiter) waitConn(ctx context.Context) (time.Duration, error) {
+	if l == nil {
+		return 0, nil
+	}
+	waited, err := l.conns.wait(ctx)
+	if err != nil {
+		return waited, err
+	}
+	more, err := l.packets.wait(ctx)
+	return waited + more, err
+}
+
+// enterSubnet takes a concurrency slot for the subnet containing ip: the
+// enclosing /24 for IPv4 and /64 for IPv6. The returned function releases it.
+func (l *Limiter) enterSubnet(ctx context.Context, ip netip.Addr) (func(), time.Duration, error) {
+	if l == nil || l.subnetCap <= 0 {
+		return func() {}, 0, nil
+	}
+	bits := 24
+	if !ip.Is4() {
+		bits = 64
+	}
+	key, _ := ip.Prefix(bits)
+	l.mu.Lock()
+	sem, ok := l.subnets[key]
+	if !ok {
+		sem = make(chan struct{}, l.subnetCap)
+		l.subnets[key] = sem
+	}
+	l.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, time.Since(start), nil
+	case <-ctx.Done():
+		return func() {}, time.Since(start), ctx.Err()
+	}
+}
+
+// throttle waits on every limiter before a packet, or a new connection when
+// conn is set, is sent.
+func (s *Scanner) throttle(ctx context.Context, conn bool) error {
+	for _, l := range s.limiters {
+		var waited time.Duration
+		var err error
+		if conn {
+			waited, err = l.waitConn(ctx)
+		} else {
+			waited, err = l.waitPacket(ctx)
+		}
+		s.throttled.Add(int64(waited))
+		if err != nil {
+			return err
+		}
+	}
+	s.packets.Add(1)
+	if conn {
+		s.conns.Add(1)
+	}
+	return nil
+}
+
+// enterSubnet takes a slot from every limiter's per-subnet cap.
+func (s *Scanner) enterSubnet(ctx context.Context, ip netip.Addr) (func(), error) {
+	releases := make([]func(), 0, len(s.limiters))
+	releaseAll := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+	for _, l := range s.limiters {
+		release, waited, err := l.enterSubnet(ctx, ip)
+		s.throttled.Add(int64(waited))
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releaseAll, nil
+}
+
+// tokenBucket hands out reservations at a steady rate with a burst of one
+// tenth of a second worth of tokens. A nil bucket never blocks.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(perSecond int) *tokenBucket {
+	if perSecond <= 0 {
+		return nil
+	}
+	burst := float64(perSecond) / 10
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: float64(perSecond), burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) (time.Duration, error) {
+	if b == nil {
+		return 0, nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+	if delay == 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		// Hand the unused reservation back.
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+func TestNewLimiterDisabled(t *testing.T) {
+	if l := NewLimiter(config.RateLimit{}); l != nil {
+		t.Fatalf("expected nil limiter without limits")
+	}
+	var l *Limiter
+	if _, err := l.waitConn(context.Background()); err != nil {
+		t.Fatalf("nil limiter must not block: %v", err)
+	}
+}
+
+func TestTokenBucketRate(t *testing.T) {
+	b := newTokenBucket(100)
+	start := time.Now()
+	for i := 0; i < 30; i++ {
+		if _, err := b.wait(context.Background()); err != nil {
+			t.Fatalf("wait: %v", err)
+		}
+	}
+	// 10 tokens of burst, then 20 more at 100/s.
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("30 tokens at 100/s took only %s", elapsed)
+	}
+}
+
+func TestTokenBucketCancel(t *testing.T) {
+	b := newTokenBucket(1)
+	b.wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := b.wait(ctx); err == nil {
+		t.Fatalf("expected context error while waiting for a token")
+	}
+}
+
+func TestSubnetConcurrency(t *testing.T) {
+	l := NewLimiter(config.RateLimit{SubnetConcurrency: 1})
+	ctx := context.Background()
+	release, _, err := l.enterSubnet(ctx, netip.MustParseAddr("10.0.0.1"))
+	if err != nil {
+		t.Fatalf("enterSubnet: %v", err)
+	}
+	// A different /24 is not affected.
+	other, _, err := l.enterSubnet(ctx, netip.MustParseAddr("10.0.1.1"))
+	if err != nil {
+		t.Fatalf("enterSubnet other subnet: %v", err)
+	}
+	other()
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := l.enterSubnet(short, netip.MustParseAddr("10.0.0.200")); err == nil {
+		t.Fatalf("expected second host of the same /24 to wait")
+	}
+	release()
+	if _, _, err := l.enterSubnet(ctx, netip.MustParseAddr("10.0.0.200")); err != nil {
+		t.Fatalf("enterSubnet after release: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// NamedTargets is implemented by target sources that resolve names. Name
+// returns the name addr was resolved from, or "" for literal addresses.
+type NamedTargets interface {
+	Targets
+	Name(addr netip.Addr) string
+}
+
+// listTargets yields a resolved list of addresses, remembering the name
+// each one came from.
+type listTargets struct {
+	addrs []netip.Addr
+	names map[netip.Addr]string
+	next  uint64
+	perm  *permutation
+}
+
+func newListTargets() *listTargets {
+	return &listTargets{names: map[netip.Addr]string{}}
+}
+
+// add appends addr unless it is already listed.
+func (t *listTargets) add(addr netip.Addr, name string) {
+	addr = addr.Unmap()
+	if _, ok := t.names[addr]; ok {
+		return
+	}
+	t.names[addr] = name
+	t.addrs = append(t.addrs, addr)
+}
+
+func (t *listTargets) Next() (netip.Addr, bool) {
+	if t.next >= uint64(len(t.addrs)) {
+		return netip.Addr{}, false
+	}
+	idx := t.next
+	if t.perm != nil {
+		idx = t.perm.next()
+	}
+	t.next++
+	return t.addrs[idx], true
+}
+
+func (t *listTargets) Name(addr netip.Addr) string {
+	return t.names[addr]
+}
+
+// Len returns the number of distinct addresses in the list.
+func (t *listTargets) Len() uint64 {
+	return uint64(len(t.addrs))
+}
+
+// finishList applies the profile's ordering once the list is complete.
+func (s *Scanner) finishList(t *listTargets) *listTargets {
+	if s.profile.Randomize && len(t.addrs) > 1 {
+		t.perm = newPermutation(uint64(len(t.addrs)), s.seed)
+	}
+	return t
+}
+
+// resolve adds entry, an IP address or a hostname, to t. Hostnames
+// contribute every A and AAAA record.
+func (s *Scanner) resolve(ctx context.Context, t *listTargets, entry string) error {
+	if addr, err := netip.ParseAddr(entry); err == nil {
+		t.add(addr, "")
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", entry)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", entry, err)
+	}
+	name := strings.TrimSuffix(entry, ".")
+	for _, addr := range addrs {
+		t.add(addr, name)
+	}
+	return nil
+}
+
+// HostTargets resolves a list of IP addresses and hostnames. Names that do
+// not resolve are logged and skipped.
+func (s *Scanner) HostTargets(ctx context.Context, entries []string) (NamedTargets, error) {
+	t := newListTargets()
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if err := s.resolve(ctx, t, entry); err != nil {
+			s.logger.Errorf("skipping target: %v", err)
+		}
+	}
+	return s.finishList(t), nil
+}
+
+// FileTargets reads IP addresses and hostnames from path, one per line.
+// Blank lines and lines starting with '#' are ignored.
+func (s *Scanner) FileTargets(ctx context.Context, path string) (NamedTargets, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open target file: %w", err)
+	}
+	defer f.Close()
+	var entries []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, strings.Fields(line)[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read target file %s: %w", path, err)
+	}
+	return s.HostTargets(ctx, entries)
+}
+
+// ZoneTargets transfers zone from server with AXFR and targets the
+// address of every A and AAAA record in it.
+func (s *Scanner) ZoneTargets(ctx context.Context, zone, server string) (NamedTargets, error) {
+	records, err := transferZone(ctx, zone, server)
+	if err != nil {
+		return nil, err
+	}
+	t := newListTargets()
+	for _, rr := range records {
+		t.add(rr.addr, rr.name)
+	}
+	return s.finishList(t), nil
+}
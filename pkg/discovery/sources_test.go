@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+func drainTargets(t Targets) []netip.Addr {
+	var out []netip.Addr
+	for {
+		addr, ok := t.Next()
+		if !ok {
+			return out
+		}
+		out = append(out, addr)
+	}
+}
+
+func TestFileTargets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.txt")
+	data := "# core switches\n10.0.0.1\n\n10.0.0.2   # spare\nlocalhost\n10.0.0.1\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewScanner(config.Profile{}, nil)
+	targets, err := s.FileTargets(context.Background(), path)
+	if err != nil {
+		t.Fatalf("FileTargets: %v", err)
+	}
+	got := drainTargets(targets)
+	if len(got) < 3 || got[0] != netip.MustParseAddr("10.0.0.1") || got[1] != netip.MustParseAddr("10.0.0.2") {
+		t.Fatalf("targets %v", got)
+	}
+	if name := targets.Name(got[0]); name != "" {
+		t.Fatalf("literal address named %q", name)
+	}
+	if name := targets.Name(got[2]); name != "localhost" {
+		t.Fatalf("resolved address named %q", name)
+	}
+	if len(got) > 4 {
+		t.Fatalf("duplicate targets %v", got)
+	}
+}
+
+// serveZone answers one AXFR query with the records of example.test split
+// across two messages.
+func serveZone(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		question := query[12:]
+		zoneName := question[:len(question)-4]
+		soa := rr(zoneName, dnsTypeSOA, []byte{0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19})
+		// "www" with a compression pointer to the zone name at offset 12.
+		www := rr(append([]byte{3, 'w', 'w', 'w'}, 0xc0, 12), dnsTypeA, []byte{192, 0, 2, 80})
+		v6 := rr(append([]byte{2, 'v', '6'}, zoneName...), dnsTypeAAAA, netip.MustParseAddr("2001:db8::80").AsSlice())
+		for _, answers := range [][][]byte{{soa, www}, {v6, soa}} {
+			msg := append([]byte{0x67, 0x73, 0x84, 0x00, 0, 1, 0, byte(len(answers)), 0, 0, 0, 0}, question...)
+			for _, a := range answers {
+				msg = append(msg, a...)
+			}
+			conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(msg))))
+			conn.Write(msg)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func rr(name []byte, rtype uint16, data []byte) []byte {
+	out := append([]byte{}, name...)
+	out = binary.BigEndian.AppendUint16(out, rtype)
+	out = binary.BigEndian.AppendUint16(out, dnsClassIN)
+	out = append(out, 0, 0, 0x0e, 0x10)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(data)))
+	return append(out, data...)
+}
+
+func TestZoneTargets(t *testing.T) {
+	server := serveZone(t)
+	s := NewScanner(config.Profile{}, nil)
+	targets, err := s.ZoneTargets(context.Background(), "example.test.", server)
+	if err != nil {
+		t.Fatalf("ZoneTargets: %v", err)
+	}
+	got := drainTargets(targets)
+	want := map[string]string{"192.0.2.80": "www.example.test", "2001:db8::80": "v6.example.test"}
+	if len(got) != len(want) {
+		t.Fatalf("targets %v", got)
+	}
+	for _, addr := range got {
+		if name := targets.Name(addr); name != want[addr.String()] {
+			t.Fatalf("%s named %q, want %q", addr, name, want[addr.String()])
+		}
+	}
+}
+
+func TestParseDNSAnswersRcode(t *testing.T) {
+	msg := []byte{0x67, 0x73, 0x80, 0x05, 0, 0, 0, 0, 0, 0, 0, 0}
+	if _, err := parseDNSAnswers(msg); err == nil || err.Error() != "transfer refused" {
+		t.Fatalf("expected refusal, got %v", err)
+	}
+}
+
+func TestStreamKeepsTargetName(t *testing.T) {
+	targets := newListTargets()
+	targets.add(netip.MustParseAddr("127.0.0.1"), "loopback.test")
+	s := NewScanner(config.Profile{Ports: []int{1}, TimeoutMS: 100}, nil)
+	results := 0
+	for res := range s.Stream(context.Background(), targets) {
+		results++
+		if res.Hostname != "loopback.test" {
+			t.Fatalf("hostname %q", res.Hostname)
+		}
+	}
+	if results != 1 {
+		t.Fatalf("got %d results want 1", results)
+	}
+}
@@ -0,0 +1,155 @@
+package discovery
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+	"net/netip"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+// maxIPv6HostBits bounds the IPv6 prefixes that are swept exhaustively.
+// Anything larger than a /112 needs a seeding strategy.
+const maxIPv6HostBits = 16
+
+// defaultIPv6SeedCount is the number of low host IDs tried by the
+// "lowbyte" seeding strategy when the profile does not set one.
+const defaultIPv6SeedCount = 256
+
+// cidrIterator lazily yields the host addresses of a prefix.
+type cidrIterator struct {
+	base    netip.Addr
+	first   uint64
+	count   uint64
+	emitted uint64
+	perm    *permutation
+}
+
+// newCIDRIterator builds the iterator for cidr. A non-zero seed makes the
+// randomized order reproducible.
+func newCIDRIterator(cidr string, profile config.Profile, seed uint64) (*cidrIterator, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("parse cidr %s: %w", cidr, err)
+	}
+	prefix = prefix.Masked()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	it := &cidrIterator{base: prefix.Addr()}
+
+	if prefix.Addr().Is4() {
+		it.count = uint64(1) << hostBits
+		// Network and broadcast addresses are not hosts, except on
+		// point-to-point /31 and single-host /32 prefixes.
+		if hostBits >= 2 {
+			it.first = 1
+			it.count -= 2
+		}
+	} else if hostBits <= maxIPv6HostBits {
+		it.count = uint64(1) << hostBits
+		// Skip the subnet-router anycast address.
+		if hostBits > 0 {
+			it.first = 1
+			it.count--
+		}
+	} else {
+		switch profile.IPv6Seed {
+		case "":
+			return nil, fmt.Errorf("ipv6 prefix %s too large to sweep (limit /%d); set ipv6_seed on the profile", cidr, 128-maxIPv6HostBits)
+		case "lowbyte":
+			seeds := profile.IPv6SeedCount
+			if seeds <= 0 {
+				seeds = defaultIPv6SeedCount
+			}
+			it.first = 1
+			it.count = uint64(seeds)
+		default:
+			return nil, fmt.Errorf("unknown ipv6 seeding strategy %q", profile.IPv6Seed)
+		}
+	}
+
+	if profile.Randomize {
+		it.perm = newPermutation(it.count, seed)
+	}
+	return it, nil
+}
+
+// Next returns the next address, or false once the prefix is exhausted.
+func (it *cidrIterator) Next() (netip.Addr, bool) {
+	if it.emitted >= it.count {
+		return netip.Addr{}, false
+	}
+	idx := it.emitted
+	if it.perm != nil {
+		idx = it.perm.next()
+	}
+	it.emitted++
+	return addOffset(it.base, it.first+idx), true
+}
+
+// Len returns the total number of addresses the iterator yields.
+func (it *cidrIterator) Len() uint64 {
+	return it.count
+}
+
+// addOffset returns base advanced by off addresses.
+func addOffset(base netip.Addr, off uint64) netip.Addr {
+	if base.Is4() {
+		b := base.As4()
+		v := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+		v += uint32(off)
+		return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	}
+	b := base.As16()
+	var hi, lo uint64
+	for i := 0; i < 8; i++ {
+		hi = hi<<8 | uint64(b[i])
+		lo = lo<<8 | uint64(b[i+8])
+	}
+	var carry uint64
+	lo, carry = bits.Add64(lo, off, 0)
+	hi += carry
+	for i := 7; i >= 0; i-- {
+		b[i] = byte(hi)
+		b[i+8] = byte(lo)
+		hi >>= 8
+		lo >>= 8
+	}
+	return netip.AddrFrom16(b).WithZone(base.Zone())
+}
+
+// permutation walks [0, n) in a pseudo-random order without materializing
+// it. It runs a full-period linear congruential generator modulo the next
+// power of two and discards values outside the range.
+type permutation struct {
+	a, c, mask uint64
+	x, n       uint64
+}
+
+func newPermutation(n, seed uint64) *permutation {
+	m := uint64(1)
+	for m < n {
+		m <<= 1
+	}
+	random := rand.Uint64
+	if seed != 0 {
+		random = rand.New(rand.NewPCG(seed, seed)).Uint64
+	}
+	return &permutation{
+		// a ≡ 1 (mod 4) and odd c give a full period modulo 2^k.
+		a:    random()&^3 | 1,
+		c:    random() | 1,
+		mask: m - 1,
+		x:    random() & (m - 1),
+		n:    n,
+	}
+}
+
+func (p *permutation) next() uint64 {
+	for {
+		p.x = (p.a*p.x + p.c) & p.mask
+		if p.x < p.n {
+			return p.x
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+func collect(t *testing.T, cidr string, profile config.Profile) []netip.Addr {
+	t.Helper()
+	it, err := newCIDRIterator(cidr, profile, 0)
+	if err != nil {
+		t.Fatalf("newCIDRIterator(%s): %v", cidr, err)
+	}
+	var out []netip.Addr
+	for {
+		addr, ok := it.Next()
+		if !ok {
+			return out
+		}
+		out = append(out, addr)
+	}
+}
+
+func TestCIDRIteratorSkipsNetworkAndBroadcast(t *testing.T) {
+	got := collect(t, "192.168.1.0/29", config.Profile{})
+	if len(got) != 6 {
+		t.Fatalf("got %d addresses want 6: %v", len(got), got)
+	}
+	if got[0] != netip.MustParseAddr("192.168.1.1") || got[5] != netip.MustParseAddr("192.168.1.6") {
+		t.Fatalf("unexpected bounds %s..%s", got[0], got[5])
+	}
+	if n := len(collect(t, "10.0.0.0/31", config.Profile{})); n != 2 {
+		t.Fatalf("/31 yielded %d addresses want 2", n)
+	}
+	if n := len(collect(t, "10.0.0.7/32", config.Profile{})); n != 1 {
+		t.Fatalf("/32 yielded %d addresses want 1", n)
+	}
+}
+
+func TestCIDRIteratorRandomizedCoversRange(t *testing.T) {
+	got := collect(t, "10.1.0.0/22", config.Profile{Randomize: true})
+	if len(got) != 1022 {
+		t.Fatalf("got %d addresses want 1022", len(got))
+	}
+	seen := map[netip.Addr]bool{}
+	sequential := true
+	for i, addr := range got {
+		if seen[addr] {
+			t.Fatalf("address %s emitted twice", addr)
+		}
+		seen[addr] = true
+		if i > 0 && addr != got[i-1].Next() {
+			sequential = false
+		}
+	}
+	if sequential {
+		t.Fatalf("randomized iteration returned sequential order")
+	}
+}
+
+func TestCIDRIteratorSeededOrderRepeats(t *testing.T) {
+	profile := config.Profile{Randomize: true}
+	a, _ := newCIDRIterator("10.2.0.0/24", profile, 42)
+	b, _ := newCIDRIterator("10.2.0.0/24", profile, 42)
+	for {
+		x, ok := a.Next()
+		y, _ := b.Next()
+		if x != y {
+			t.Fatalf("seeded iterators diverged: %s vs %s", x, y)
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+func TestCIDRIteratorIPv6(t *testing.T) {
+	if _, err := newCIDRIterator("2001:db8::/64", config.Profile{}, 0); err == nil {
+		t.Fatalf("expected /64 to be refused without a seeding strategy")
+	}
+	got := collect(t, "2001:db8::/64", config.Profile{IPv6Seed: "lowbyte", IPv6SeedCount: 4})
+	want := []string{"2001:db8::1", "2001:db8::2", "2001:db8::3", "2001:db8::4"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+	if n := len(collect(t, "2001:db8::/120", config.Profile{})); n != 255 {
+		t.Fatalf("/120 yielded %d addresses want 255", n)
+	}
+}
+
+func TestAddOffsetCarry(t *testing.T) {
+	base := netip.MustParseAddr("2001:db8::ffff:ffff:ffff:ffff")
+	if got := addOffset(base, 1).String(); got != "2001:db8:0:1::" {
+		t.Fatalf("addOffset carry = %s", got)
+	}
+}
@@ -0,0 +1,204 @@
+package discovery
+
+import (
+	"context"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+)
+
+// probeOutcome classifies the answer to a single probe.
+type probeOutcome int
+
+const (
+	// outcomeSilent means no answer arrived before the timeout.
+	outcomeSilent probeOutcome = iota
+	// outcomeOpen means the service answered.
+	outcomeOpen
+	// outcomeClosed means the host answered that nothing listens there.
+	outcomeClosed
+	// outcomeFailed means the probe could not be sent or the network
+	// reported the destination unreachable; retrying will not help.
+	outcomeFailed
+)
+
+// rttEstimator smooths round-trip samples as TCP does (RFC 6298).
+type rttEstimator struct {
+	srtt    time.Duration
+	rttvar  time.Duration
+	samples int
+}
+
+func (e *rttEstimator) observe(rtt time.Duration) {
+	if e.samples == 0 {
+		e.srtt = rtt
+		e.rttvar = rtt / 2
+	} else {
+		delta := e.srtt - rtt
+		if delta < 0 {
+			delta = -delta
+		}
+		e.rttvar = (3*e.rttvar + delta) / 4
+		e.srtt = (7*e.srtt + rtt) / 8
+	}
+	e.samples++
+}
+
+// rto returns the retransmission timeout implied by the samples so far.
+func (e *rttEstimator) rto() time.Duration {
+	return e.srtt + 4*e.rttvar
+}
+
+// minAdaptiveSamples is the number of answers needed before the measured
+// RTT replaces the profile's initial timeout.
+const minAdaptiveSamples = 3
+
+// adaptiveTimeouts tracks RTT per /24 (/64 for IPv6) and derives probe
+// timeouts from it, bounded by the profile. When disabled it always hands
+// out the initial timeout.
+type adaptiveTimeouts struct {
+	enabled  bool
+	initial  time.Duration
+	min, max time.Duration
+
+	mu      sync.Mutex
+	subnets map[netip.Prefix]*rttEstimator
+}
+
+func newAdaptiveTimeouts(enabled bool, initial, min, max time.Duration) *adaptiveTimeouts {
+	if min <= 0 {
+		min = 50 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 4 * initial
+	}
+	if max < min {
+		max = min
+	}
+	return &adaptiveTimeouts{
+		enabled: enabled,
+		initial: initial,
+		min:     min,
+		max:     max,
+		subnets: map[netip.Prefix]*rttEstimator{},
+	}
+}
+
+func subnetOf(ip netip.Addr) netip.Prefix {
+	bits := 24
+	if !ip.Is4() {
+		bits = 64
+	}
+	prefix, _ := ip.Prefix(bits)
+	return prefix
+}
+
+// timeout returns the probe timeout to use for ip.
+func (a *adaptiveTimeouts) timeout(ip netip.Addr) time.Duration {
+	if !a.enabled {
+		return a.initial
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	est, ok := a.subnets[subnetOf(ip)]
+	if !ok || est.samples < minAdaptiveSamples {
+		return a.clamp(a.initial)
+	}
+	return a.clamp(est.rto())
+}
+
+// backoff returns the timeout for the next retry of a silent probe.
+func (a *adaptiveTimeouts) backoff(timeout time.Duration) time.Duration {
+	if !a.enabled {
+		return timeout
+	}
+	return a.clamp(2 * timeout)
+}
+
+func (a *adaptiveTimeouts) observe(ip netip.Addr, rtt time.Duration) {
+	if !a.enabled || rtt <= 0 {
+		return
+	}
+	key := subnetOf(ip)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	est, ok := a.subnets[key]
+	if !ok {
+		est = &rttEstimator{}
+		a.subnets[key] = est
+	}
+	est.observe(rtt)
+}
+
+func (a *adaptiveTimeouts) clamp(d time.Duration) time.Duration {
+	if d < a.min {
+		return a.min
+	}
+	if d > a.max {
+		return a.max
+	}
+	return d
+}
+
+// subnetTimeout describes the timeout chosen for one subnet.
+type subnetTimeout struct {
+	Subnet  netip.Prefix
+	Timeout time.Duration
+	SRTT    time.Duration
+	Samples int
+}
+
+func (a *adaptiveTimeouts) snapshot() []subnetTimeout {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]subnetTimeout, 0, len(a.subnets))
+	for subnet, est := range a.subnets {
+		timeout := a.initial
+		if est.samples >= minAdaptiveSamples {
+			timeout = est.rto()
+		}
+		out = append(out, subnetTimeout{
+			Subnet:  subnet,
+			Timeout: a.clamp(timeout),
+			SRTT:    est.srtt,
+			Samples: est.samples,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Subnet.Addr().Less(out[j].Subnet.Addr()) })
+	return out
+}
+
+// attempt runs probe with the current timeout for ip and retries silent
+// attempts up to the profile's retry count. Answers feed the RTT estimate.
+func (s *Scanner) attempt(ctx context.Context, ip netip.Addr, conn bool, probe func(timeout time.Duration) (time.Duration, probeOutcome)) (time.Duration, probeOutcome) {
+	timeout := s.timeouts.timeout(ip)
+	for try := 0; ; try++ {
+		if s.throttle(ctx, conn) != nil {
+			return 0, outcomeFailed
+		}
+		rtt, outcome := probe(timeout)
+		switch outcome {
+		case outcomeOpen, outcomeClosed:
+			s.timeouts.observe(ip, rtt)
+			return rtt, outcome
+		case outcomeFailed:
+			return 0, outcome
+		}
+		if try >= s.profile.Retries || ctx.Err() != nil {
+			return 0, outcomeSilent
+		}
+		timeout = s.timeouts.backoff(timeout)
+	}
+}
+
+// logTimeouts reports the timeout chosen for every subnet seen so far.
+func (s *Scanner) logTimeouts() {
+	if !s.timeouts.enabled {
+		return
+	}
+	for _, st := range s.timeouts.snapshot() {
+		s.logger.Infof("adaptive timeout for %s: %s (srtt %s over %d samples, retries %d)",
+			st.Subnet, st.Timeout.Round(time.Millisecond), st.SRTT.Round(time.Microsecond), st.Samples, s.profile.Retries)
+	}
+}
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+func TestAdaptiveTimeoutsConverge(t *testing.T) {
+	a := newAdaptiveTimeouts(true, time.Second, 20*time.Millisecond, 3*time.Second)
+	ip := netip.MustParseAddr("10.1.2.3")
+	if got := a.timeout(ip); got != time.Second {
+		t.Fatalf("initial timeout %s", got)
+	}
+	for i := 0; i < 10; i++ {
+		a.observe(netip.MustParseAddr("10.1.2.9"), 5*time.Millisecond)
+	}
+	if got := a.timeout(ip); got != 20*time.Millisecond {
+		t.Fatalf("fast subnet timeout %s, want clamped to min", got)
+	}
+	if got := a.timeout(netip.MustParseAddr("10.1.3.3")); got != time.Second {
+		t.Fatalf("unmeasured subnet timeout %s", got)
+	}
+	if got := a.backoff(2 * time.Second); got != 3*time.Second {
+		t.Fatalf("backoff %s, want clamped to max", got)
+	}
+
+	fixed := newAdaptiveTimeouts(false, time.Second, 0, 0)
+	fixed.observe(ip, time.Millisecond)
+	if got := fixed.timeout(ip); got != time.Second {
+		t.Fatalf("disabled timeout %s", got)
+	}
+}
+
+func TestAdaptiveTimeoutsConcurrent(t *testing.T) {
+	// Probes of one subnet observe answers while others read the timeout;
+	// run with -race.
+	a := newAdaptiveTimeouts(true, time.Second, time.Millisecond, 3*time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ip := netip.AddrFrom4([4]byte{10, 1, 2, byte(i)})
+			for j := 0; j < 200; j++ {
+				if i%2 == 0 {
+					a.observe(ip, time.Duration(j+1)*time.Millisecond)
+				} else if got := a.timeout(ip); got < time.Millisecond || got > 3*time.Second {
+					t.Errorf("timeout %s out of bounds", got)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := a.snapshot(); len(got) != 1 || got[0].Samples != 800 {
+		t.Fatalf("snapshot %+v", got)
+	}
+}
+
+func TestAttemptRetriesSilentProbes(t *testing.T) {
+	s := NewScanner(config.Profile{TimeoutMS: 100, Retries: 2, AdaptiveTimeout: true, MaxTimeoutMS: 300}, nil)
+	ip := netip.MustParseAddr("10.0.0.1")
+
+	var timeouts []time.Duration
+	_, outcome := s.attempt(context.Background(), ip, false, func(timeout time.Duration) (time.Duration, probeOutcome) {
+		timeouts = append(timeouts, timeout)
+		return 0, outcomeSilent
+	})
+	if outcome != outcomeSilent {
+		t.Fatalf("outcome %v", outcome)
+	}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	if len(timeouts) != len(want) {
+		t.Fatalf("tries %v, want %v", timeouts, want)
+	}
+	for i := range want {
+		if timeouts[i] != want[i] {
+			t.Fatalf("tries %v, want %v", timeouts, want)
+		}
+	}
+
+	tries := 0
+	_, outcome = s.attempt(context.Background(), ip, true, func(time.Duration) (time.Duration, probeOutcome) {
+		tries++
+		return 2 * time.Millisecond, outcomeClosed
+	})
+	if outcome != outcomeClosed || tries != 1 {
+		t.Fatalf("closed port: outcome %v after %d tries", outcome, tries)
+	}
+	if st := s.Stats(); st.Packets != 4 || st.Connections != 1 {
+		t.Fatalf("stats %+v", st)
+	}
+}
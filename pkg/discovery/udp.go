@@ -0,0 +1,69 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/service"
+)
+
+// hasUDPPayload reports whether the scanner knows how to probe port over UDP.
+func hasUDPPayload(port int) bool {
+	_, ok := service.UDPPayload(port)
+	return ok
+}
+
+// udpPorts returns the UDP ports probed by the profile: the explicit UDP
+// list when set, otherwise every profile port with a known payload.
+func udpPorts(ports, explicit []int) []int {
+	if len(explicit) > 0 {
+		return explicit
+	}
+	var out []int
+	for _, port := range ports {
+		if hasUDPPayload(port) {
+			out = append(out, port)
+		}
+	}
+	return out
+}
+
+// probeUDP sends payload, the request registered for port, and waits for
+// any answer. A silent port may be open or filtered; an ICMP port
+// unreachable reported by the host marks it closed.
+func probeUDP(ctx context.Context, ip netip.Addr, port int, payload []byte, timeout time.Duration) (time.Duration, probeOutcome, error) {
+	if payload == nil {
+		return 0, outcomeFailed, fmt.Errorf("no udp payload for port %d", port)
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(ip.String(), fmt.Sprintf("%d", port)))
+	if err != nil {
+		return 0, outcomeFailed, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	deadline := start.Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, outcomeFailed, err
+	}
+	if _, err := conn.Write(payload); err != nil {
+		return 0, outcomeFailed, err
+	}
+	buf := make([]byte, 1500)
+	if _, err := conn.Read(buf); err != nil {
+		rtt := time.Since(start)
+		outcome := classifyError(err)
+		if outcome == outcomeSilent {
+			return 0, outcome, nil
+		}
+		return rtt, outcome, fmt.Errorf("udp/%d: %w", port, err)
+	}
+	return time.Since(start), outcomeOpen, nil
+}
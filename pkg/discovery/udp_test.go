@@ -0,0 +1,45 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUDPPortsSelection(t *testing.T) {
+	got := udpPorts([]int{22, 80, 161, 443, 5353}, nil)
+	if !reflect.DeepEqual(got, []int{161, 5353}) {
+		t.Fatalf("udpPorts = %v", got)
+	}
+	if got := udpPorts([]int{161}, []int{123}); !reflect.DeepEqual(got, []int{123}) {
+		t.Fatalf("explicit udp ports ignored: %v", got)
+	}
+}
+
+func TestProbeUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer pc.Close()
+	port := pc.LocalAddr().(*net.UDPAddr).Port
+	go func() {
+		buf := make([]byte, 64)
+		n, addr, err := pc.ReadFrom(buf)
+		if err == nil {
+			pc.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	ip := netip.MustParseAddr("127.0.0.1")
+	if _, outcome, err := probeUDP(context.Background(), ip, port, []byte("hello"), time.Second); err != nil || outcome != outcomeOpen {
+		t.Fatalf("probeUDP open port: outcome=%v err=%v", outcome, err)
+	}
+	pc.Close()
+	if _, outcome, err := probeUDP(context.Background(), ip, port, []byte("hello"), 200*time.Millisecond); outcome != outcomeClosed || err == nil {
+		t.Fatalf("expected closed port to be reported, outcome=%v err=%v", outcome, err)
+	}
+}
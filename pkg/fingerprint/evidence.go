@@ -0,0 +1,109 @@
+package fingerprint
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// evidence turns a matching rule into evidence for each value it sets.
+func (m ruleMatch) evidence() []inventory.Evidence {
+	var out []inventory.Evidence
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{inventory.FieldType, m.values.Type},
+		{inventory.FieldVendor, m.values.Vendor},
+		{inventory.FieldModel, m.values.Model},
+		{inventory.FieldOSName, m.values.OSName},
+		{inventory.FieldOSVersion, m.values.OSVersion},
+	} {
+		if field.value == "" {
+			continue
+		}
+		out = append(out, inventory.Evidence{
+			Field:  field.name,
+			Value:  field.value,
+			Weight: m.rule.Priority,
+			Source: m.rule.source(),
+			Rule:   m.rule.Name,
+			Detail: m.detail,
+		})
+	}
+	return out
+}
+
+// candidate is a value proposed for a field, with the strongest weight
+// each source gave it.
+type candidate struct {
+	value   string
+	sources map[string]int
+}
+
+// confidence combines the sources as independent observations. Evidence
+// from a single source is not independent, so only its strongest weight
+// counts.
+func (c *candidate) confidence() float64 {
+	doubt := 1.0
+	for _, weight := range c.sources {
+		doubt *= 1 - float64(weight)/100
+	}
+	return 1 - doubt
+}
+
+// decide sets each classified field of asset to the value its evidence
+// supports best and records the confidence in it. Ties go to the value
+// that appears first in the evidence.
+func (e *Engine) decide(asset *inventory.AssetModel) {
+	candidates := map[string][]*candidate{}
+	for _, ev := range asset.Evidence {
+		var c *candidate
+		for _, existing := range candidates[ev.Field] {
+			if strings.EqualFold(existing.value, ev.Value) {
+				c = existing
+				break
+			}
+		}
+		if c == nil {
+			c = &candidate{value: ev.Value, sources: map[string]int{}}
+			candidates[ev.Field] = append(candidates[ev.Field], c)
+		}
+		if weight, ok := c.sources[ev.Source]; !ok || ev.Weight > weight {
+			c.sources[ev.Source] = ev.Weight
+		}
+	}
+
+	if asset.Confidence == nil {
+		asset.Confidence = map[string]int{}
+	}
+	for _, field := range []struct {
+		name   string
+		target *string
+	}{
+		{inventory.FieldType, &asset.Type},
+		{inventory.FieldVendor, &asset.Vendor},
+		{inventory.FieldModel, &asset.Model},
+		{inventory.FieldOSName, &asset.OSName},
+		{inventory.FieldOSVersion, &asset.OSVersion},
+	} {
+		var best *candidate
+		bestScore := -1.0
+		for _, c := range candidates[field.name] {
+			if score := c.confidence(); score > bestScore {
+				best, bestScore = c, score
+			}
+		}
+		if best == nil {
+			continue
+		}
+		*field.target = best.value
+		asset.Confidence[field.name] = int(math.Round(bestScore * 100))
+		if e.verbose {
+			fmt.Printf("[EVIDENCE] %s=%s (confidence %d%%, %d candidates)\n",
+				field.name, best.value, asset.Confidence[field.name], len(candidates[field.name]))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package fingerprint
+
+import (
+	"testing"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+func TestDecideCombinesSources(t *testing.T) {
+	e := NewEngine()
+	e.verbose = false
+	asset := inventory.AssetModel{Evidence: []inventory.Evidence{
+		{Field: inventory.FieldType, Value: "Peripheral", Weight: 30, Source: "http"},
+		{Field: inventory.FieldType, Value: "NetworkEquipment", Weight: 80, Source: "snmp"},
+		{Field: inventory.FieldType, Value: "NetworkEquipment", Weight: 30, Source: "ports"},
+		// A second, weaker observation from the same source adds nothing.
+		{Field: inventory.FieldType, Value: "NetworkEquipment", Weight: 50, Source: "snmp"},
+		{Field: inventory.FieldVendor, Value: "Cisco", Weight: 60, Source: "snmp"},
+		{Field: inventory.FieldVendor, Value: "CISCO", Weight: 30, Source: "http"},
+	}}
+	e.decide(&asset)
+	if asset.Type != "NetworkEquipment" || asset.Confidence[inventory.FieldType] != 86 {
+		t.Fatalf("type %s confidence %d", asset.Type, asset.Confidence[inventory.FieldType])
+	}
+	if asset.Vendor != "Cisco" || asset.Confidence[inventory.FieldVendor] != 72 {
+		t.Fatalf("vendor %s confidence %d", asset.Vendor, asset.Confidence[inventory.FieldVendor])
+	}
+	if _, ok := asset.Confidence[inventory.FieldModel]; ok {
+		t.Fatal("confidence recorded for a field without evidence")
+	}
+}
+
+func TestClassifyRecordsEvidence(t *testing.T) {
+	e := NewEngine()
+	// SSH, HTTP and SNMP open but SNMP unanswered: the port pattern for
+	// network equipment outweighs the weaker computer patterns.
+	got := classifyFacts(t, e, &facts{tcp: ports(22, 80), udp: ports(161)})
+	if got.Type != "NetworkEquipment" {
+		t.Fatalf("type %s", got.Type)
+	}
+	rules := map[string]bool{}
+	for _, ev := range got.Evidence {
+		if ev.Field == inventory.FieldType {
+			rules[ev.Rule] = true
+		}
+	}
+	for _, name := range []string{"ports-network", "ports-unix", "ports-remote-access", "default"} {
+		if !rules[name] {
+			t.Fatalf("evidence %v lacks rule %s", got.Evidence, name)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package fingerprint
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+)
+
+// maxHTTPBody caps how much of a page is read looking for its title.
+const maxHTTPBody = 64 << 10
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// httpProber fetches the web root over HTTP and HTTPS.
+type httpProber struct {
+	client  *http.Client
+	verbose bool
+}
+
+func newHTTPProber(verbose bool) *httpProber {
+	// Accept self-signed certificates. This is necessary for
+	// fingerprinting devices like printers, routers, etc.
+	return &httpProber{
+		client: &http.Client{
+			Timeout: 2 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+		},
+		verbose: verbose,
+	}
+}
+
+func (p *httpProber) Name() string { return "http" }
+
+func (p *httpProber) Ports() []Port { return []Port{TCP(80), TCP(443)} }
+
+func (p *httpProber) Probe(ctx context.Context, host discovery.HostResult, acc *Accumulator) error {
+	var errs []error
+	if hasPort(host.OpenPorts, 80) {
+		errs = append(errs, p.fetch(ctx, acc, host.IP.String(), "http"))
+	}
+	if hasPort(host.OpenPorts, 443) {
+		errs = append(errs, p.fetch(ctx, acc, host.IP.String(), "https"))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *httpProber) fetch(ctx context.Context, acc *Accumulator, ip, scheme string) error {
+	if p.verbose {
+		fmt.Printf("[HTTP] Attempting %s request to %s://%s\n", strings.ToUpper(scheme), scheme, ip)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s", scheme, ip), nil)
+	if err != nil {
+		if p.verbose {
+			fmt.Printf("[HTTP] Failed to create request: %v\n", err)
+		}
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if p.verbose {
+			fmt.Printf("[HTTP] Request failed: %v\n", err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if p.verbose {
+		fmt.Printf("[HTTP] Response status: %d\n", resp.StatusCode)
+	}
+
+	server := resp.Header.Get("Server")
+	if server != "" && p.verbose {
+		fmt.Printf("[HTTP] Server header: %s\n", server)
+	}
+
+	var title string
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	if m := titlePattern.FindSubmatch(body); m != nil {
+		title = strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+		if title != "" && p.verbose {
+			fmt.Printf("[HTTP] Page title: %s\n", title)
+		}
+	}
+	acc.AddHTTPResponse(scheme, server, title, resp.StatusCode)
+	return nil
+}
@@ -0,0 +1,136 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nbnsTransactionID identifies goscanner's node status requests.
+const nbnsTransactionID = 0x6773
+
+// nbnsTypeNBSTAT is the NetBIOS node status resource record type.
+const nbnsTypeNBSTAT = 0x21
+
+// netbiosName is a name a host registered with NetBIOS: its computer name
+// (suffix 0x00 or 0x20, unique) or its workgroup or domain (suffix 0x00,
+// group), among others.
+type netbiosName struct {
+	name   string
+	suffix byte
+	group  bool
+}
+
+// nodeStatus is a NetBIOS node status response (RFC 1002 4.2.18).
+type nodeStatus struct {
+	names []netbiosName
+	mac   string
+}
+
+// computer returns the host's computer name.
+func (s nodeStatus) computer() string {
+	for _, n := range s.names {
+		if !n.group && (n.suffix == 0x00 || n.suffix == 0x20) {
+			return n.name
+		}
+	}
+	return ""
+}
+
+// workgroup returns the workgroup or NetBIOS domain the host belongs to.
+func (s nodeStatus) workgroup() string {
+	for _, n := range s.names {
+		if n.group && n.suffix == 0x00 {
+			return n.name
+		}
+	}
+	return ""
+}
+
+// nodeStatus asks the host for its NetBIOS names.
+func (p *smbProber) nodeStatus(ctx context.Context, ip netip.Addr) (*nodeStatus, error) {
+	d := net.Dialer{Timeout: p.timeout}
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(ip.String(), strconv.Itoa(p.nbnsPort)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(p.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	if _, err := conn.Write(nodeStatusRequest()); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return parseNodeStatus(buf[:n])
+}
+
+// nodeStatusRequest queries the wildcard name "*" (RFC 1002 4.2.17).
+func nodeStatusRequest() []byte {
+	req := binary.BigEndian.AppendUint16(nil, nbnsTransactionID)
+	req = append(req, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	req = append(req, 0x20, 'C', 'K')
+	req = append(req, bytes.Repeat([]byte("A"), 30)...)
+	return append(req, 0x00, 0x00, nbnsTypeNBSTAT, 0x00, 0x01)
+}
+
+// parseNodeStatus parses the answer to nodeStatusRequest.
+func parseNodeStatus(msg []byte) (*nodeStatus, error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg) != nbnsTransactionID {
+		return nil, errors.New("not a NetBIOS node status response")
+	}
+	if binary.BigEndian.Uint16(msg[6:]) == 0 {
+		return nil, errors.New("NetBIOS node status response without answer")
+	}
+	// The answer's name, uncompressed or a pointer.
+	i := 12
+	for i < len(msg) && msg[i] != 0 && msg[i]&0xc0 != 0xc0 {
+		i += 1 + int(msg[i])
+	}
+	switch {
+	case i >= len(msg):
+	case msg[i] == 0:
+		i++
+	default:
+		i += 2
+	}
+	// Type, class, TTL and data length precede the name count.
+	if i+11 > len(msg) || binary.BigEndian.Uint16(msg[i:]) != nbnsTypeNBSTAT {
+		return nil, errors.New("not a NetBIOS node status response")
+	}
+	i += 10
+	count := int(msg[i])
+	i++
+	status := &nodeStatus{}
+	for k := 0; k < count; k++ {
+		if i+18 > len(msg) {
+			return nil, fmt.Errorf("NetBIOS node status truncated after %d of %d names", k, count)
+		}
+		status.names = append(status.names, netbiosName{
+			name:   strings.TrimRight(string(msg[i:i+15]), " \x00"),
+			suffix: msg[i+15],
+			group:  msg[i+16]&0x80 != 0,
+		})
+		i += 18
+	}
+	// The unit ID follows the names; Samba leaves it zero.
+	if i+6 <= len(msg) {
+		if mac := net.HardwareAddr(msg[i : i+6]); !bytes.Equal(mac, make([]byte, 6)) {
+			status.mac = strings.ToUpper(mac.String())
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,65 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// nodeStatusResponse builds the answer of a host with names and unit ID
+// mac to nodeStatusRequest.
+func nodeStatusResponse(mac []byte, names ...netbiosName) []byte {
+	msg := binary.BigEndian.AppendUint16(nil, nbnsTransactionID)
+	msg = append(msg, 0x84, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00)
+	msg = append(msg, 0x20, 'C', 'K')
+	msg = append(msg, bytes.Repeat([]byte("A"), 30)...)
+	msg = append(msg, 0x00, 0x00, nbnsTypeNBSTAT, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00)
+	data := []byte{byte(len(names))}
+	for _, n := range names {
+		data = append(data, fmt.Sprintf("%-15s", n.name)...)
+		flags := byte(0x04) // active
+		if n.group {
+			flags |= 0x80
+		}
+		data = append(data, n.suffix, flags, 0x00)
+	}
+	data = append(data, mac...)
+	data = append(data, make([]byte, 40)...) // statistics
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(data)))
+	return append(msg, data...)
+}
+
+var windowsNames = []netbiosName{
+	{name: "WORKGROUP", suffix: 0x00, group: true},
+	{name: "WS-0042", suffix: 0x00},
+	{name: "WS-0042", suffix: 0x20},
+}
+
+func TestParseNodeStatus(t *testing.T) {
+	status, err := parseNodeStatus(nodeStatusResponse([]byte{0x00, 0x50, 0x56, 0x01, 0x02, 0x03}, windowsNames...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(status.names, windowsNames) || status.mac != "00:50:56:01:02:03" {
+		t.Fatalf("names %+v mac %q", status.names, status.mac)
+	}
+	if status.computer() != "WS-0042" || status.workgroup() != "WORKGROUP" {
+		t.Fatalf("computer %q workgroup %q", status.computer(), status.workgroup())
+	}
+
+	// Samba leaves the unit ID zero.
+	status, err = parseNodeStatus(nodeStatusResponse(make([]byte, 6), windowsNames...))
+	if err != nil || status.mac != "" {
+		t.Fatalf("mac %q, err %v", status.mac, err)
+	}
+
+	truncated := nodeStatusResponse(nil, windowsNames...)
+	if _, err := parseNodeStatus(truncated[:len(truncated)-60]); err == nil {
+		t.Fatal("truncated response parsed")
+	}
+	if _, err := parseNodeStatus(nodeStatusRequest()); err == nil {
+		t.Fatal("request parsed as a response")
+	}
+}
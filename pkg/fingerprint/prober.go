@@ -0,0 +1,385 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// DefaultProberTimeout bounds a prober registered without a timeout.
+const DefaultProberTimeout = 10 * time.Second
+
+// Prober collects facts about a live host for classification. The engine
+// runs every prober that applies to a host concurrently, each under its own
+// timeout.
+type Prober interface {
+	// Name identifies the prober in logs, evidence and probe reports.
+	Name() string
+	// Ports lists the ports the prober applies to; it runs on hosts with
+	// at least one of them open. A prober without ports runs on every
+	// host.
+	Ports() []Port
+	// Probe examines host and records its findings in acc. It must return
+	// once ctx is done.
+	Probe(ctx context.Context, host discovery.HostResult, acc *Accumulator) error
+}
+
+// Port is a transport port a prober applies to.
+type Port struct {
+	Protocol string // "tcp" or "udp"
+	Number   int
+}
+
+// TCP returns the TCP port n.
+func TCP(n int) Port { return Port{Protocol: "tcp", Number: n} }
+
+// UDP returns the UDP port n.
+func UDP(n int) Port { return Port{Protocol: "udp", Number: n} }
+
+func (p Port) String() string {
+	return fmt.Sprintf("%s/%d", p.Protocol, p.Number)
+}
+
+// WithProber registers p. Each run is bounded by timeout, or by
+// DefaultProberTimeout when timeout is zero.
+func WithProber(p Prober, timeout time.Duration) EngineOption {
+	return func(e *Engine) {
+		e.probers = append(e.probers, registeredProber{p, timeout})
+	}
+}
+
+// silentUDPProber is implemented by probers whose servers ignore requests
+// they cannot authenticate, such as SNMP agents given the wrong community.
+// Discovery's anonymous probe of their UDP ports may then go unanswered, so
+// they also run on hosts where one of those ports stayed silent.
+type silentUDPProber interface {
+	probesSilentUDP()
+}
+
+type registeredProber struct {
+	Prober
+	timeout time.Duration
+}
+
+// applies reports whether host has one of the prober's ports open, or for
+// a silentUDPProber, one of its UDP ports silent.
+func applies(p Prober, host discovery.HostResult) bool {
+	ports := p.Ports()
+	if len(ports) == 0 {
+		return true
+	}
+	_, silentUDP := p.(silentUDPProber)
+	for _, port := range ports {
+		switch port.Protocol {
+		case "tcp":
+			if hasPort(host.OpenPorts, port.Number) {
+				return true
+			}
+		case "udp":
+			if hasPort(host.UDPPorts, port.Number) || (silentUDP && host.UDPSilent[port.Number]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runProbers runs the probers that apply to host concurrently and records
+// how each run went on the asset.
+func (e *Engine) runProbers(ctx context.Context, host discovery.HostResult, state *hostState) {
+	results := make([]*inventory.ProbeResult, len(e.probers))
+	var wg sync.WaitGroup
+	for i, p := range e.probers {
+		if !applies(p.Prober, host) {
+			if e.verbose {
+				fmt.Printf("[FINGERPRINT] No %v port open, skipping %s\n", p.Ports(), p.Name())
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, p registeredProber) {
+			defer wg.Done()
+			timeout := p.timeout
+			if timeout <= 0 {
+				timeout = DefaultProberTimeout
+			}
+			probeCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := p.Probe(probeCtx, host, &Accumulator{state: state, prober: p.Name()})
+			result := inventory.ProbeResult{Prober: p.Name(), Duration: time.Since(start)}
+			if err != nil && errors.Is(probeCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				err = fmt.Errorf("timed out after %s: %w", timeout, err)
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			if e.verbose {
+				fmt.Printf("[FINGERPRINT] %s finished in %s %s\n", p.Name(), result.Duration.Round(time.Millisecond), result.Error)
+			}
+			results[i] = &result
+		}(i, p)
+	}
+	wg.Wait()
+	for _, result := range results {
+		if result != nil {
+			state.asset.Probes = append(state.asset.Probes, *result)
+		}
+	}
+}
+
+// hostState is the asset and facts being built for one host, shared by
+// its probers.
+type hostState struct {
+	mu    sync.Mutex
+	asset *inventory.AssetModel
+	facts *facts
+}
+
+// Accumulator records what one prober learns about a host. It is safe for
+// concurrent use.
+type Accumulator struct {
+	state  *hostState
+	prober string
+}
+
+// SetAttribute stores a free-form attribute on the asset.
+func (a *Accumulator) SetAttribute(key, value string) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.asset.Attributes[key] = value
+}
+
+// SetHostname names the host unless it already has a name.
+func (a *Accumulator) SetHostname(name string) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	if a.state.asset.Hostname == "" {
+		a.state.asset.Hostname = name
+	}
+}
+
+// AddEvidence records an observation in favour of value for field, one of
+// the inventory.Field constants, weighted from 0 to 100.
+func (a *Accumulator) AddEvidence(field, value string, weight int, detail string) {
+	if value == "" {
+		return
+	}
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.asset.Evidence = append(a.state.asset.Evidence, inventory.Evidence{
+		Field:  field,
+		Value:  value,
+		Weight: weight,
+		Source: a.prober,
+		Detail: detail,
+	})
+}
+
+// SetSNMPSystem records the SNMP system group for the sysdescr and
+// sysobjectid rule conditions.
+func (a *Accumulator) SetSNMPSystem(sysDescr, sysObjectID, sysName string) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	f, attrs := a.state.facts, a.state.asset.Attributes
+	f.snmp = true
+	f.sysDescr, f.sysObjectID, f.sysName = sysDescr, sysObjectID, sysName
+	if sysDescr != "" {
+		attrs["snmp_sysdescr"] = sysDescr
+	}
+	if sysObjectID != "" {
+		attrs["snmp_sysobjectid"] = sysObjectID
+	}
+	if sysName != "" {
+		attrs["snmp_sysname"] = sysName
+		if a.state.asset.Hostname == "" {
+			a.state.asset.Hostname = sysName
+		}
+	}
+}
+
+// AddHTTPResponse records a response to a request for the web root, for
+// the http_* rule conditions. Scheme is "http" or "https".
+func (a *Accumulator) AddHTTPResponse(scheme, server, title string, status int) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.facts.http = append(a.state.facts.http, httpFacts{server: server, title: title, status: status})
+	attrs := a.state.asset.Attributes
+	if server != "" {
+		attrs["http_server"] = server
+	}
+	if title != "" {
+		attrs[fmt.Sprintf("http_%s_title", scheme)] = title
+	}
+	attrs[fmt.Sprintf("http_%s_status", scheme)] = fmt.Sprintf("%d", status)
+}
+
+// SetSerial records the serial number of the host unless one is known.
+func (a *Accumulator) SetSerial(serial string) {
+	serial = strings.TrimSpace(serial)
+	if serial == "" {
+		return
+	}
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	if a.state.asset.Serial == "" {
+		a.state.asset.Serial = serial
+	}
+}
+
+// SetFirmware records the firmware or software version of the host unless
+// one is known.
+func (a *Accumulator) SetFirmware(version string) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	if a.state.asset.Firmware == "" {
+		a.state.asset.Firmware = strings.TrimSpace(version)
+	}
+}
+
+// AddComponent records a physical component of the host.
+func (a *Accumulator) AddComponent(c inventory.HardwareComponent) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.asset.Components = append(a.state.asset.Components, c)
+}
+
+// SetHostResources records the processors, memory, storage, software and
+// processes of the host. Software already recorded by SetSoftware is kept.
+func (a *Accumulator) SetHostResources(host inventory.HostResources) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	if prev := a.state.asset.Host; prev != nil && len(prev.Software) > 0 {
+		host.Software = prev.Software
+	}
+	a.state.asset.Host = &host
+}
+
+// SetSoftware records the packages installed on the host, as listed by its
+// package manager. It replaces software listed by SetHostResources, which
+// lacks versions.
+func (a *Accumulator) SetSoftware(software []inventory.Software) {
+	if len(software) == 0 {
+		return
+	}
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	if a.state.asset.Host == nil {
+		a.state.asset.Host = &inventory.HostResources{}
+	}
+	a.state.asset.Host.Software = software
+}
+
+// SetKernel records the host's kernel and machine architecture.
+func (a *Accumulator) SetKernel(kernel, arch string) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.asset.Kernel, a.state.asset.Arch = kernel, arch
+}
+
+// SetWorkgroup records the Windows workgroup or domain of the host unless
+// one is known.
+func (a *Accumulator) SetWorkgroup(workgroup string) {
+	if workgroup == "" {
+		return
+	}
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	if a.state.asset.Workgroup == "" {
+		a.state.asset.Workgroup = workgroup
+	}
+}
+
+// SetPrinter records the supplies, counters and state of a printer.
+func (a *Accumulator) SetPrinter(info inventory.PrinterInfo) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.asset.Printer = &info
+}
+
+// sysObjectID returns the SNMP sysObjectID recorded for the host.
+func (a *Accumulator) sysObjectID() string {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	return a.state.facts.sysObjectID
+}
+
+// AddNeighbor records a device seen on one of the host's ports.
+func (a *Accumulator) AddNeighbor(n inventory.Neighbor) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.asset.Neighbors = append(a.state.asset.Neighbors, n)
+}
+
+// AddForwarding records a MAC address the host, a switch, learned on one
+// of its ports.
+func (a *Accumulator) AddForwarding(e inventory.ForwardingEntry) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.asset.Forwarding = append(a.state.asset.Forwarding, e)
+}
+
+// AddARP records an entry of the host's ARP table.
+func (a *Accumulator) AddARP(e inventory.ARPEntry) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.asset.ARP = append(a.state.asset.ARP, e)
+}
+
+// localInterface returns the interface recorded for the host that matches
+// name by name, description or alias, or failing that has ifIndex index.
+func (a *Accumulator) localInterface(name string, index int) (inventory.NetworkInterface, bool) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	ifaces := a.state.asset.Interfaces
+	if name != "" {
+		for _, iface := range ifaces {
+			if strings.EqualFold(iface.Name, name) || strings.EqualFold(iface.Description, name) || strings.EqualFold(iface.Alias, name) {
+				return iface, true
+			}
+		}
+	}
+	for _, iface := range ifaces {
+		if index != 0 && iface.Index == index {
+			return iface, true
+		}
+	}
+	return inventory.NetworkInterface{}, false
+}
+
+// AddInterface records a network interface of the host. The interface
+// holding the host's address supplies its MAC address when discovery could
+// not.
+func (a *Accumulator) AddInterface(iface inventory.NetworkInterface) {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	asset := a.state.asset
+	asset.Interfaces = append(asset.Interfaces, iface)
+	if asset.MAC != "" || iface.MAC == "" {
+		return
+	}
+	for _, prefix := range iface.Addresses {
+		if prefix.Addr() == asset.IP {
+			asset.MAC = iface.MAC
+			return
+		}
+	}
+}
+
+// AddBanner records service identification text for the banner rule
+// condition.
+func (a *Accumulator) AddBanner(banner string) {
+	if banner == "" {
+		return
+	}
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+	a.state.facts.banners = append(a.state.facts.banners, banner)
+}
@@ -0,0 +1,104 @@
+package fingerprint
+
+import (
+	"context"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// stubProber records a UPS on any host with its port open.
+type stubProber struct {
+	name  string
+	port  Port
+	delay time.Duration
+	ran   bool
+}
+
+func (p *stubProber) Name() string  { return p.name }
+func (p *stubProber) Ports() []Port { return []Port{p.port} }
+
+func (p *stubProber) Probe(ctx context.Context, host discovery.HostResult, acc *Accumulator) error {
+	p.ran = true
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(p.delay):
+	}
+	acc.SetHostname("ups-01")
+	acc.SetAttribute("ups_battery", "100%")
+	acc.AddEvidence(inventory.FieldType, "Peripheral", 90, "UPS management card")
+	acc.AddEvidence(inventory.FieldVendor, "APC", 90, "UPS management card")
+	return nil
+}
+
+func TestEngineRunsProbers(t *testing.T) {
+	ups := &stubProber{name: "ups", port: TCP(3052)}
+	slow := &stubProber{name: "slow", port: TCP(3052), delay: time.Second}
+	unused := &stubProber{name: "unused", port: UDP(5000)}
+	e := NewEngine(
+		WithProber(ups, 0),
+		WithProber(slow, 50*time.Millisecond),
+		WithProber(unused, 0))
+	e.verbose = false
+
+	host := discovery.HostResult{IP: netip.MustParseAddr("192.0.2.10"), Alive: true, OpenPorts: ports(3052)}
+	asset := e.FingerprintHost(context.Background(), host)
+
+	if asset.Type != "Peripheral" || asset.Vendor != "Apc" || asset.Hostname != "ups-01" {
+		t.Fatalf("asset %s/%s/%s", asset.Type, asset.Vendor, asset.Hostname)
+	}
+	if asset.Attributes["ups_battery"] != "100%" {
+		t.Fatalf("attributes %v", asset.Attributes)
+	}
+	if unused.ran {
+		t.Fatal("prober ran on a host without its port open")
+	}
+	if len(asset.Probes) != 2 || asset.Probes[0].Prober != "ups" || asset.Probes[1].Prober != "slow" {
+		t.Fatalf("probes %+v", asset.Probes)
+	}
+	if asset.Probes[0].Error != "" {
+		t.Fatalf("ups prober failed: %s", asset.Probes[0].Error)
+	}
+	if !strings.HasPrefix(asset.Probes[1].Error, "timed out after 50ms") || asset.Probes[1].Duration > 500*time.Millisecond {
+		t.Fatalf("slow prober %+v", asset.Probes[1])
+	}
+}
+
+// fdbProber reports a switch whose forwarding table learned mac on Gi0/7.
+type fdbProber struct {
+	mac string
+}
+
+func (p *fdbProber) Name() string  { return "fdb" }
+func (p *fdbProber) Ports() []Port { return []Port{TCP(3053)} }
+
+func (p *fdbProber) Probe(ctx context.Context, host discovery.HostResult, acc *Accumulator) error {
+	acc.SetHostname("access-sw1")
+	acc.AddForwarding(inventory.ForwardingEntry{MAC: p.mac, IfIndex: 7, Port: "Gi0/7"})
+	return nil
+}
+
+func TestEngineLocatesHostBeforeItsSwitch(t *testing.T) {
+	e := NewEngine(WithProber(&fdbProber{mac: "00:50:56:01:02:03"}, 0))
+	e.verbose = false
+
+	// The host comes out of fingerprinting before the switch that sees it.
+	host := e.FingerprintHost(context.Background(), discovery.HostResult{IP: netip.MustParseAddr("192.0.2.20"), Alive: true, MAC: "00:50:56:01:02:03"})
+	if host.SwitchPort != nil {
+		t.Fatalf("located before the switch was fingerprinted: %+v", host.SwitchPort)
+	}
+	sw := e.FingerprintHost(context.Background(), discovery.HostResult{IP: netip.MustParseAddr("192.0.2.2"), Alive: true, OpenPorts: ports(3053)})
+	if len(sw.Forwarding) != 1 {
+		t.Fatalf("switch forwarding %+v", sw.Forwarding)
+	}
+
+	e.Locate(&host)
+	if host.SwitchPort == nil || host.SwitchPort.SwitchIP != sw.IP || host.SwitchPort.Port != "Gi0/7" {
+		t.Fatalf("switch port %+v", host.SwitchPort)
+	}
+}
@@ -0,0 +1,391 @@
+package fingerprint
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+)
+
+//go:embed default_rules.json
+var defaultRules []byte
+
+// Rule classifies hosts whose facts satisfy every condition in Match. A
+// matching rule is evidence for each value it sets, weighted by Priority
+// on a scale of 0 to 100.
+type Rule struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+	// Disabled turns off a rule of the same name loaded earlier.
+	Disabled bool   `json:"disabled"`
+	Match    Match  `json:"match"`
+	Set      Assign `json:"set"`
+}
+
+// Match lists the conditions of a rule. Unset conditions always hold; a
+// pattern only holds when the fact it tests was collected.
+type Match struct {
+	// AllPorts must all be open, at least one of AnyPorts must be open and
+	// none of NoPorts may be. Entries use the profile port syntax: numbers,
+	// ranges, service and group names, "udp/" prefixes.
+	AllPorts PortSet `json:"all_ports"`
+	AnyPorts PortSet `json:"any_ports"`
+	NoPorts  PortSet `json:"no_ports"`
+	// SysDescr and SysObjectID match the SNMP system group.
+	SysDescr    *Pattern `json:"sysdescr"`
+	SysObjectID *Pattern `json:"sysobjectid"`
+	// HTTPServer, HTTPTitle and HTTPStatus must all hold for the same HTTP
+	// or HTTPS response.
+	HTTPServer *Pattern `json:"http_server"`
+	HTTPTitle  *Pattern `json:"http_title"`
+	HTTPStatus *Pattern `json:"http_status"`
+	// Banner matches any identified service ("ssh OpenSSH 8.9p1") or raw
+	// banner found during discovery.
+	Banner *Pattern `json:"banner"`
+}
+
+// Assign holds the values a rule sets. Values are templates: ${name}
+// expands to the named capture group (?P<name>...) of a matching pattern
+// or to one of the facts sysdescr_model, sysname and http_server.
+type Assign struct {
+	Type      string `json:"type"`
+	Vendor    string `json:"vendor"`
+	Model     string `json:"model"`
+	OSName    string `json:"os_name"`
+	OSVersion string `json:"os_version"`
+}
+
+// Pattern is a regular expression in a rule file.
+type Pattern struct {
+	*regexp.Regexp
+}
+
+// UnmarshalJSON compiles the pattern.
+func (p *Pattern) UnmarshalJSON(data []byte) error {
+	var expr string
+	if err := json.Unmarshal(data, &expr); err != nil {
+		return err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	p.Regexp = re
+	return nil
+}
+
+// PortSet is a list of TCP and UDP ports in a rule file.
+type PortSet struct {
+	TCP []int
+	UDP []int
+}
+
+// UnmarshalJSON expands port entries, given as numbers or strings.
+func (s *PortSet) UnmarshalJSON(data []byte) error {
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for _, raw := range entries {
+		var spec string
+		if err := json.Unmarshal(raw, &spec); err != nil {
+			var port int
+			if err := json.Unmarshal(raw, &port); err != nil {
+				return fmt.Errorf("port entry %s: want number or string", raw)
+			}
+			spec = strconv.Itoa(port)
+		}
+		tcp, udp, err := config.ExpandPorts(spec)
+		if err != nil {
+			return err
+		}
+		s.TCP = append(s.TCP, tcp...)
+		s.UDP = append(s.UDP, udp...)
+	}
+	return nil
+}
+
+func (s PortSet) empty() bool {
+	return len(s.TCP) == 0 && len(s.UDP) == 0
+}
+
+// count returns how many ports of the set are open.
+func (s PortSet) count(f *facts) int {
+	return len(s.open(f))
+}
+
+// open lists the ports of the set that are open, as "tcp/22" or "udp/161".
+func (s PortSet) open(f *facts) []string {
+	var out []string
+	for _, port := range s.TCP {
+		if hasPort(f.tcp, port) {
+			out = append(out, fmt.Sprintf("tcp/%d", port))
+		}
+	}
+	for _, port := range s.UDP {
+		if hasPort(f.udp, port) {
+			out = append(out, fmt.Sprintf("udp/%d", port))
+		}
+	}
+	return out
+}
+
+// source names the kind of fact the rule relies on, the strongest first
+// when it tests several.
+func (r *Rule) source() string {
+	m := &r.Match
+	switch {
+	case m.SysDescr != nil || m.SysObjectID != nil:
+		return "snmp"
+	case m.HTTPServer != nil || m.HTTPTitle != nil || m.HTTPStatus != nil:
+		return "http"
+	case m.Banner != nil:
+		return "service"
+	case !m.AllPorts.empty() || !m.AnyPorts.empty() || !m.NoPorts.empty():
+		return "ports"
+	}
+	return "default"
+}
+
+// RuleSet is an ordered list of classification rules.
+type RuleSet struct {
+	rules []Rule
+}
+
+// DefaultRules returns the built-in rules.
+func DefaultRules() *RuleSet {
+	rules, err := ParseRules(defaultRules)
+	if err != nil {
+		panic(fmt.Sprintf("built-in fingerprint rules: %v", err))
+	}
+	return &RuleSet{rules: rules}
+}
+
+// LoadRules returns the built-in rules followed by the rules of each file
+// in paths. A rule named like an earlier one replaces it in place.
+func LoadRules(paths ...string) (*RuleSet, error) {
+	rs := DefaultRules()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read rules: %w", err)
+		}
+		rules, err := ParseRules(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		rs.add(rules)
+	}
+	return rs, nil
+}
+
+// ParseRules decodes a rule file, a YAML or JSON document with a top-level
+// "rules" list.
+func ParseRules(data []byte) ([]Rule, error) {
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := config.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	for i, r := range file.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: missing name", i+1)
+		}
+		if !r.Disabled && r.Set == (Assign{}) {
+			return nil, fmt.Errorf("rule %s: sets nothing", r.Name)
+		}
+		if r.Priority < 0 || r.Priority > 100 {
+			return nil, fmt.Errorf("rule %s: priority %d outside 0-100", r.Name, r.Priority)
+		}
+	}
+	return file.Rules, nil
+}
+
+func (rs *RuleSet) add(rules []Rule) {
+	for _, r := range rules {
+		replaced := false
+		for i := range rs.rules {
+			if rs.rules[i].Name == r.Name {
+				rs.rules[i] = r
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			rs.rules = append(rs.rules, r)
+		}
+	}
+}
+
+// facts is what the engine learned about a host, as seen by the rules.
+type facts struct {
+	tcp, udp    map[int]time.Duration
+	snmp        bool
+	sysDescr    string
+	sysObjectID string
+	sysName     string
+	http        []httpFacts
+	banners     []string
+}
+
+type httpFacts struct {
+	server string
+	title  string
+	status int
+}
+
+// ruleMatch is a matching rule with its values expanded and a description
+// of the facts it matched.
+type ruleMatch struct {
+	rule   *Rule
+	values Assign
+	detail string
+}
+
+// match returns the rules that hold for f, highest priority first.
+func (rs *RuleSet) match(f *facts) []ruleMatch {
+	var out []ruleMatch
+	for i := range rs.rules {
+		r := &rs.rules[i]
+		if r.Disabled {
+			continue
+		}
+		if vars, why, ok := r.Match.eval(f); ok {
+			out = append(out, ruleMatch{rule: r, values: r.Set.expand(vars), detail: strings.Join(why, ", ")})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].rule.Priority > out[j].rule.Priority
+	})
+	return out
+}
+
+// eval reports whether m holds for f, with the variables its values may
+// refer to and the facts that satisfied it.
+func (m *Match) eval(f *facts) (map[string]string, []string, bool) {
+	if m.AllPorts.count(f) < len(m.AllPorts.TCP)+len(m.AllPorts.UDP) {
+		return nil, nil, false
+	}
+	if !m.AnyPorts.empty() && m.AnyPorts.count(f) == 0 {
+		return nil, nil, false
+	}
+	if m.NoPorts.count(f) > 0 {
+		return nil, nil, false
+	}
+	var why []string
+	if open := append(m.AllPorts.open(f), m.AnyPorts.open(f)...); len(open) > 0 {
+		why = append(why, "open "+strings.Join(open, " "))
+	}
+
+	vars := map[string]string{}
+	if f.snmp {
+		vars["sysdescr_model"] = extractModel(f.sysDescr)
+		vars["sysname"] = f.sysName
+	}
+	if m.SysDescr != nil {
+		text, ok := capture(m.SysDescr, f.sysDescr, vars)
+		if !f.snmp || !ok {
+			return nil, nil, false
+		}
+		why = append(why, fmt.Sprintf("sysdescr %q", text))
+	}
+	if m.SysObjectID != nil {
+		text, ok := capture(m.SysObjectID, f.sysObjectID, vars)
+		if !f.snmp || !ok {
+			return nil, nil, false
+		}
+		why = append(why, fmt.Sprintf("sysobjectid %q", text))
+	}
+
+	if m.HTTPServer != nil || m.HTTPTitle != nil || m.HTTPStatus != nil {
+		found := false
+		for _, resp := range f.http {
+			groups := map[string]string{}
+			var matched []string
+			ok := true
+			for _, cond := range []struct {
+				name    string
+				pattern *Pattern
+				value   string
+			}{
+				{"http_server", m.HTTPServer, resp.server},
+				{"http_title", m.HTTPTitle, resp.title},
+				{"http_status", m.HTTPStatus, strconv.Itoa(resp.status)},
+			} {
+				if cond.pattern == nil {
+					continue
+				}
+				text, hit := capture(cond.pattern, cond.value, groups)
+				if !hit {
+					ok = false
+					break
+				}
+				matched = append(matched, fmt.Sprintf("%s %q", cond.name, text))
+			}
+			if !ok {
+				continue
+			}
+			for name, value := range groups {
+				vars[name] = value
+			}
+			vars["http_server"] = resp.server
+			why = append(why, matched...)
+			found = true
+			break
+		}
+		if !found {
+			return nil, nil, false
+		}
+	} else if len(f.http) > 0 {
+		vars["http_server"] = f.http[0].server
+	}
+
+	if m.Banner != nil {
+		found := false
+		for _, banner := range f.banners {
+			if text, ok := capture(m.Banner, banner, vars); ok {
+				why = append(why, fmt.Sprintf("banner %q", text))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, nil, false
+		}
+	}
+	return vars, why, true
+}
+
+// capture matches re against s, records its named groups in vars and
+// returns the matched text.
+func capture(re *Pattern, s string, vars map[string]string) (string, bool) {
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	for i, name := range re.SubexpNames() {
+		if name != "" && m[i] != "" {
+			vars[name] = m[i]
+		}
+	}
+	return m[0], true
+}
+
+func (a Assign) expand(vars map[string]string) Assign {
+	lookup := func(name string) string { return vars[name] }
+	return Assign{
+		Type:      os.Expand(a.Type, lookup),
+		Vendor:    os.Expand(a.Vendor, lookup),
+		Model:     os.Expand(a.Model, lookup),
+		OSName:    os.Expand(a.OSName, lookup),
+		OSVersion: os.Expand(a.OSVersion, lookup),
+	}
+}
@@ -0,0 +1,157 @@
+package fingerprint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+func ports(list ...int) map[int]time.Duration {
+	out := map[int]time.Duration{}
+	for _, p := range list {
+		out[p] = time.Millisecond
+	}
+	return out
+}
+
+func classifyFacts(t *testing.T, e *Engine, f *facts) inventory.AssetModel {
+	t.Helper()
+	e.verbose = false
+	asset := inventory.AssetModel{Type: "Unknown"}
+	e.classify(&asset, f)
+	return asset
+}
+
+func TestDefaultRulesPorts(t *testing.T) {
+	e := NewEngine()
+	cases := []struct {
+		tcp, udp []int
+		want     string
+	}{
+		{[]int{9100}, nil, "Printer"},
+		{[]int{22}, []int{161}, "NetworkEquipment"},
+		{[]int{22, 135}, []int{161}, "Computer"},
+		{[]int{445}, nil, "Computer"},
+		{nil, nil, "Computer"},
+	}
+	for _, tc := range cases {
+		got := classifyFacts(t, e, &facts{tcp: ports(tc.tcp...), udp: ports(tc.udp...)})
+		if got.Type != tc.want {
+			t.Fatalf("tcp %v udp %v: type %q want %q", tc.tcp, tc.udp, got.Type, tc.want)
+		}
+	}
+}
+
+func TestDefaultRulesSNMP(t *testing.T) {
+	e := NewEngine()
+	got := classifyFacts(t, e, &facts{
+		tcp:         ports(80, 9100),
+		udp:         ports(161),
+		snmp:        true,
+		sysDescr:    "HP ETHERNET MULTI-ENVIRONMENT,ROM none,JETDIRECT,JD153,EEPROM JSI24090012,CIDATE 04/06/2021, Model: LaserJet M507",
+		sysObjectID: ".1.3.6.1.4.1.11.2.3.9.1",
+		http:        []httpFacts{{server: "HP HTTP Server; HP LaserJet M507", status: 200}},
+	})
+	want := inventory.AssetModel{Type: "Printer", Vendor: "HP", Model: "LaserJet M507"}
+	if got.Type != want.Type || got.Vendor != want.Vendor || got.Model != want.Model {
+		t.Fatalf("got %s/%s/%s want %s/%s/%s", got.Type, got.Vendor, got.Model, want.Type, want.Vendor, want.Model)
+	}
+
+	// A web interface does not turn a switch into a peripheral.
+	got = classifyFacts(t, e, &facts{
+		tcp:         ports(22, 443),
+		udp:         ports(161),
+		snmp:        true,
+		sysDescr:    "Cisco IOS Software, C2960X Software (C2960X-UNIVERSALK9-M), Catalyst L2 Switch",
+		sysObjectID: ".1.3.6.1.4.1.9.1.1208",
+		http:        []httpFacts{{status: 200}},
+	})
+	if got.Type != "NetworkEquipment" || got.Vendor != "Cisco" {
+		t.Fatalf("switch classified as %s/%s", got.Type, got.Vendor)
+	}
+}
+
+func TestEnterpriseOIDBoundary(t *testing.T) {
+	e := NewEngine()
+	got := classifyFacts(t, e, &facts{snmp: true, sysObjectID: ".1.3.6.1.4.1.94.1"})
+	if got.Vendor != "" {
+		t.Fatalf("enterprise 94 matched vendor %q", got.Vendor)
+	}
+}
+
+func TestLoadRulesOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	data := `rules:
+  - name: acme-ups
+    priority: 95
+    match:
+      any_ports: [3052]
+      http_title: '^(?P<model>Smart-UPS \S+)'
+    set:
+      type: Peripheral
+      vendor: APC
+      model: ${model}
+  - name: default
+    disabled: true
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules: %v", err)
+	}
+	e := NewEngine(WithRules(rules))
+
+	got := classifyFacts(t, e, &facts{
+		tcp:  ports(80, 3052),
+		http: []httpFacts{{server: "Apache", title: "Smart-UPS 1500 Network Management Card", status: 200}},
+	})
+	if got.Type != "Peripheral" || got.Vendor != "APC" || got.Model != "Smart-UPS 1500" {
+		t.Fatalf("got %s/%s/%s", got.Type, got.Vendor, got.Model)
+	}
+
+	// The built-in fallback to Computer is disabled.
+	if got := classifyFacts(t, e, &facts{}); got.Type != "Unknown" {
+		t.Fatalf("disabled default rule still applied: %q", got.Type)
+	}
+}
+
+func TestParseRulesQuotedPatterns(t *testing.T) {
+	// A "#" inside quotes is not a comment, and double-quoted patterns
+	// escape their backslashes.
+	data := `rules:
+  - name: lab-ups  # comment
+    priority: 90
+    match:
+      http_title: "^UPS #\\d+ \\(lab\\)$"
+      http_server: '^ups-httpd #\d'   # comment
+    set:
+      type: Peripheral
+`
+	rules, err := ParseRules([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseRules: %v", err)
+	}
+	e := NewEngine(WithRules(&RuleSet{rules: rules}))
+	got := classifyFacts(t, e, &facts{http: []httpFacts{{server: "ups-httpd #2", title: "UPS #12 (lab)", status: 200}}})
+	if got.Type != "Peripheral" {
+		t.Fatalf("rule did not match: type %q", got.Type)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"rules": [{"priority": 1, "set": {"type": "Printer"}}]}`,
+		`{"rules": [{"name": "empty"}]}`,
+		`{"rules": [{"name": "bad", "match": {"sysdescr": "("}, "set": {"type": "Printer"}}]}`,
+		`{"rules": [{"name": "bad", "match": {"any_ports": ["nosuchservice"]}, "set": {"type": "Printer"}}]}`,
+	} {
+		if _, err := ParseRules([]byte(data)); err == nil {
+			t.Fatalf("expected error for %s", data)
+		}
+	}
+}
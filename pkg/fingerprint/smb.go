@@ -0,0 +1,422 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// smbNTLMWeight is the weight of the OS version a Windows host reports in
+// its NTLM challenge, which it fills in itself. That the host is a computer
+// is weighed less, as appliances run Windows too.
+const (
+	smbNTLMWeight = 85
+	smbTypeWeight = 60
+)
+
+// smbProber identifies Windows hosts without credentials: a NetBIOS-NS node
+// status query lists the names the host registered, and an SMB2 negotiate
+// followed by an anonymous NTLMSSP session setup reveals its names, domain,
+// OS build and signing policy before authentication.
+type smbProber struct {
+	smbPort  int
+	nbnsPort int
+	timeout  time.Duration
+	verbose  bool
+}
+
+func (p *smbProber) Name() string { return "smb" }
+
+func (p *smbProber) Ports() []Port {
+	return []Port{TCP(p.smbPort), TCP(139), UDP(p.nbnsPort)}
+}
+
+func (p *smbProber) Probe(ctx context.Context, host discovery.HostResult, acc *Accumulator) error {
+	var errs []error
+	status, err := p.nodeStatus(ctx, host.IP)
+	if err != nil {
+		if p.verbose {
+			fmt.Printf("[SMB] NetBIOS node status query to %s failed: %v\n", host.IP, err)
+		}
+		errs = append(errs, fmt.Errorf("netbios: %w", err))
+	}
+	var server *smbServer
+	if hasPort(host.OpenPorts, p.smbPort) {
+		server, err = p.negotiate(ctx, host.IP)
+		if err != nil {
+			if p.verbose {
+				fmt.Printf("[SMB] SMB2 negotiate with %s failed: %v\n", host.IP, err)
+			}
+			errs = append(errs, fmt.Errorf("smb2: %w", err))
+		}
+	}
+	if status == nil && server == nil {
+		return errors.Join(errs...)
+	}
+	recordWindowsHost(acc, status, server)
+	return nil
+}
+
+// smbServer is what the SMB2 negotiate and session setup reveal.
+type smbServer struct {
+	dialect uint16
+	signing string // "required", "enabled" or "disabled"
+	ntlm    *ntlmChallenge
+}
+
+// ntlmChallenge holds the names and version in an NTLM CHALLENGE message
+// (MS-NLMP 2.2.1.2).
+type ntlmChallenge struct {
+	nbComputer  string
+	nbDomain    string
+	dnsComputer string
+	dnsDomain   string
+	// The product version, set when the server sent one.
+	major, minor uint8
+	build        uint16
+}
+
+// osBuild returns the Windows version as "major.minor.build", such as
+// "10.0.19045". Samba reports build 0, which is not a Windows build.
+func (c ntlmChallenge) osBuild() string {
+	if c.build == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", c.major, c.minor, c.build)
+}
+
+// recordWindowsHost records the names, domain, OS build and SMB settings of
+// a host.
+func recordWindowsHost(acc *Accumulator, status *nodeStatus, server *smbServer) {
+	var ntlm ntlmChallenge
+	if server != nil && server.ntlm != nil {
+		ntlm = *server.ntlm
+	}
+	name, workgroup := ntlm.nbComputer, ""
+	if status != nil {
+		if name == "" {
+			name = status.computer()
+		}
+		workgroup = status.workgroup()
+		if status.mac != "" {
+			acc.SetAttribute("netbios_mac", status.mac)
+		}
+	}
+	if name != "" {
+		acc.SetHostname(name)
+		acc.SetAttribute("netbios_name", name)
+	}
+	// A standalone computer is its own NTLM domain; a domain member reports
+	// the domain, which GLPI shows as its workgroup.
+	if ntlm.nbDomain != "" && !strings.EqualFold(ntlm.nbDomain, ntlm.nbComputer) {
+		acc.SetAttribute("netbios_domain", ntlm.nbDomain)
+		workgroup = ntlm.nbDomain
+		if ntlm.dnsDomain != "" {
+			acc.SetAttribute("dns_domain", ntlm.dnsDomain)
+			workgroup = ntlm.dnsDomain
+		}
+	}
+	if ntlm.dnsComputer != "" {
+		acc.SetAttribute("dns_hostname", ntlm.dnsComputer)
+	}
+	acc.SetWorkgroup(workgroup)
+
+	if server == nil {
+		return
+	}
+	if dialect, ok := smb2Dialects[server.dialect]; ok {
+		acc.SetAttribute("smb_dialect", dialect)
+	}
+	acc.SetAttribute("smb_signing", server.signing)
+	if build := ntlm.osBuild(); build != "" {
+		acc.SetAttribute("os_build", build)
+		detail := fmt.Sprintf("NTLM challenge over SMB2, version %s", build)
+		acc.AddEvidence(inventory.FieldOSName, "Windows", smbNTLMWeight, detail)
+		acc.AddEvidence(inventory.FieldOSVersion, build, smbNTLMWeight, detail)
+		acc.AddEvidence(inventory.FieldType, "Computer", smbTypeWeight, detail)
+	}
+}
+
+// SMB2 commands and status codes (MS-SMB2 2.2.1).
+const (
+	smb2Negotiate                = 0x0000
+	smb2SessionSetup             = 0x0001
+	statusMoreProcessingRequired = 0xc0000016
+)
+
+// smb2Offered lists the dialects offered. SMB 3.1.1 is left out: offering
+// it requires negotiate contexts.
+var smb2Offered = []uint16{0x0202, 0x0210, 0x0300, 0x0302}
+
+// smb2Dialects names the dialects a server may pick.
+var smb2Dialects = map[uint16]string{
+	0x0202: "2.0.2",
+	0x0210: "2.1",
+	0x0300: "3.0",
+	0x0302: "3.0.2",
+}
+
+// negotiate runs an SMB2 negotiate and an anonymous NTLMSSP session setup
+// with the host. What was learned before an error is returned with it.
+func (p *smbProber) negotiate(ctx context.Context, ip netip.Addr) (*smbServer, error) {
+	d := net.Dialer{Timeout: p.timeout}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(p.smbPort)))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(p.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	resp, err := smbRoundTrip(conn, smb2NegotiateRequest())
+	if err != nil {
+		return nil, err
+	}
+	server, err := parseNegotiateResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = smbRoundTrip(conn, smb2SessionSetupRequest(spnegoInit(ntlmNegotiate())))
+	if err != nil {
+		return server, err
+	}
+	blob, err := sessionSetupBlob(resp)
+	if err != nil {
+		return server, err
+	}
+	i := bytes.Index(blob, ntlmSignature)
+	if i < 0 {
+		return server, errors.New("no NTLMSSP challenge in session setup response")
+	}
+	server.ntlm, err = parseNTLMChallenge(blob[i:])
+	return server, err
+}
+
+// smbRoundTrip sends an SMB2 message over direct TCP and reads the reply.
+func smbRoundTrip(conn net.Conn, msg []byte) ([]byte, error) {
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(msg)))
+	if _, err := conn.Write(append(frame, msg...)); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, frame); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(frame) & 0xffffff
+	if n < 64 || n > 1<<16 {
+		return nil, fmt.Errorf("SMB message of %d bytes", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(resp, []byte("\xffSMB")):
+		return nil, errors.New("server only speaks SMB1")
+	case !bytes.HasPrefix(resp, []byte("\xfeSMB")):
+		return nil, errors.New("not an SMB2 message")
+	}
+	return resp, nil
+}
+
+// smb2Header returns an SMB2 sync header for command.
+func smb2Header(command uint16, messageID uint64) []byte {
+	h := make([]byte, 64)
+	copy(h, "\xfeSMB")
+	binary.LittleEndian.PutUint16(h[4:], 64)
+	binary.LittleEndian.PutUint16(h[12:], command)
+	binary.LittleEndian.PutUint16(h[14:], 1) // credits requested
+	binary.LittleEndian.PutUint64(h[24:], messageID)
+	return h
+}
+
+// smb2NegotiateRequest offers the dialects of smb2Offered with signing
+// enabled but not required.
+func smb2NegotiateRequest() []byte {
+	body := make([]byte, 36)
+	binary.LittleEndian.PutUint16(body[0:], 36)
+	binary.LittleEndian.PutUint16(body[2:], uint16(len(smb2Offered)))
+	binary.LittleEndian.PutUint16(body[4:], 1)
+	rand.Read(body[12:28]) // client GUID
+	for _, dialect := range smb2Offered {
+		body = binary.LittleEndian.AppendUint16(body, dialect)
+	}
+	return append(smb2Header(smb2Negotiate, 0), body...)
+}
+
+// parseNegotiateResponse reads the dialect and security mode of an SMB2
+// NEGOTIATE response.
+func parseNegotiateResponse(resp []byte) (*smbServer, error) {
+	if status := binary.LittleEndian.Uint32(resp[8:]); status != 0 {
+		return nil, fmt.Errorf("negotiate failed with status %#08x", status)
+	}
+	body := resp[64:]
+	if len(body) < 64 {
+		return nil, errors.New("short negotiate response")
+	}
+	server := &smbServer{dialect: binary.LittleEndian.Uint16(body[4:]), signing: "disabled"}
+	switch mode := binary.LittleEndian.Uint16(body[2:]); {
+	case mode&0x02 != 0:
+		server.signing = "required"
+	case mode&0x01 != 0:
+		server.signing = "enabled"
+	}
+	return server, nil
+}
+
+// smb2SessionSetupRequest carries a security token without a session.
+func smb2SessionSetupRequest(token []byte) []byte {
+	body := make([]byte, 24)
+	binary.LittleEndian.PutUint16(body[0:], 25)
+	body[3] = 1 // signing enabled
+	binary.LittleEndian.PutUint16(body[12:], 64+24)
+	binary.LittleEndian.PutUint16(body[14:], uint16(len(token)))
+	return append(append(smb2Header(smb2SessionSetup, 1), body...), token...)
+}
+
+// sessionSetupBlob returns the security buffer of a SESSION_SETUP response
+// asking for another round trip.
+func sessionSetupBlob(resp []byte) ([]byte, error) {
+	if status := binary.LittleEndian.Uint32(resp[8:]); status != statusMoreProcessingRequired {
+		return nil, fmt.Errorf("session setup failed with status %#08x", status)
+	}
+	if len(resp) < 64+8 {
+		return nil, errors.New("short session setup response")
+	}
+	off := int(binary.LittleEndian.Uint16(resp[64+4:]))
+	n := int(binary.LittleEndian.Uint16(resp[64+6:]))
+	if off+n > len(resp) {
+		return nil, errors.New("session setup security buffer out of bounds")
+	}
+	return resp[off : off+n], nil
+}
+
+// NTLMSSP negotiate flags (MS-NLMP 2.2.2.5).
+const (
+	ntlmNegotiateUnicode    = 0x00000001
+	ntlmRequestTarget       = 0x00000004
+	ntlmNegotiateNTLM       = 0x00000200
+	ntlmAlwaysSign          = 0x00008000
+	ntlmExtendedSecurity    = 0x00080000
+	ntlmNegotiateTargetInfo = 0x00800000
+	ntlmNegotiateVersion    = 0x02000000
+	ntlmNegotiate128        = 0x20000000
+	ntlmNegotiate56         = 0x80000000
+
+	ntlmNegotiateFlags = ntlmNegotiateUnicode | ntlmRequestTarget | ntlmNegotiateNTLM | ntlmAlwaysSign |
+		ntlmExtendedSecurity | ntlmNegotiateTargetInfo | ntlmNegotiateVersion | ntlmNegotiate128 | ntlmNegotiate56
+)
+
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// ntlmNegotiate returns an NTLM NEGOTIATE message without domain or
+// workstation.
+func ntlmNegotiate() []byte {
+	msg := make([]byte, 40)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], ntlmNegotiateFlags)
+	return msg
+}
+
+// NTLM AV_PAIR identifiers of the target info names.
+const (
+	avNbComputerName  = 1
+	avNbDomainName    = 2
+	avDNSComputerName = 3
+	avDNSDomainName   = 4
+)
+
+// parseNTLMChallenge parses an NTLM CHALLENGE message.
+func parseNTLMChallenge(msg []byte) (*ntlmChallenge, error) {
+	if len(msg) < 48 || !bytes.HasPrefix(msg, ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, errors.New("not an NTLM challenge")
+	}
+	c := &ntlmChallenge{}
+	if flags := binary.LittleEndian.Uint32(msg[20:]); flags&ntlmNegotiateVersion != 0 && len(msg) >= 56 {
+		c.major, c.minor, c.build = msg[48], msg[49], binary.LittleEndian.Uint16(msg[50:])
+	}
+	info := ntlmField(msg, 40)
+	for len(info) >= 4 {
+		id, n := binary.LittleEndian.Uint16(info), int(binary.LittleEndian.Uint16(info[2:]))
+		if id == 0 || len(info) < 4+n {
+			break
+		}
+		value := info[4 : 4+n]
+		switch id {
+		case avNbComputerName:
+			c.nbComputer = utf16String(value)
+		case avNbDomainName:
+			c.nbDomain = utf16String(value)
+		case avDNSComputerName:
+			c.dnsComputer = utf16String(value)
+		case avDNSDomainName:
+			c.dnsDomain = utf16String(value)
+		}
+		info = info[4+n:]
+	}
+	return c, nil
+}
+
+// ntlmField returns the payload an NTLM length/offset field at at points
+// to, or nil when it is empty or out of bounds.
+func ntlmField(msg []byte, at int) []byte {
+	n := int(binary.LittleEndian.Uint16(msg[at:]))
+	off := int(binary.LittleEndian.Uint32(msg[at+4:]))
+	if n == 0 || off < 0 || off+n > len(msg) {
+		return nil
+	}
+	return msg[off : off+n]
+}
+
+// utf16String decodes a little-endian UTF-16 string.
+func utf16String(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// ASN.1 object identifiers of SPNEGO and NTLMSSP, DER-encoded.
+var (
+	oidSPNEGO  = []byte{0x06, 0x06, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	oidNTLMSSP = []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+)
+
+// spnegoInit wraps an NTLM token in an SPNEGO NegTokenInit (RFC 4178).
+func spnegoInit(token []byte) []byte {
+	mechTypes := derTLV(0xa0, derTLV(0x30, oidNTLMSSP))
+	mechToken := derTLV(0xa2, derTLV(0x04, token))
+	negTokenInit := derTLV(0xa0, derTLV(0x30, append(mechTypes, mechToken...)))
+	return derTLV(0x60, append(append([]byte{}, oidSPNEGO...), negTokenInit...))
+}
+
+// derTLV encodes content under tag with a DER length.
+func derTLV(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n < 0x100:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
@@ -0,0 +1,235 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+type avPair struct {
+	id    uint16
+	value string
+}
+
+func utf16le(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return b
+}
+
+// challengeMessage builds an NTLM CHALLENGE message from a server running
+// version major.minor.build.
+func challengeMessage(major, minor byte, build uint16, target string, pairs ...avPair) []byte {
+	name := utf16le(target)
+	var info []byte
+	for _, p := range pairs {
+		v := utf16le(p.value)
+		info = binary.LittleEndian.AppendUint16(info, p.id)
+		info = binary.LittleEndian.AppendUint16(info, uint16(len(v)))
+		info = append(info, v...)
+	}
+	info = append(info, 0, 0, 0, 0)
+	msg := make([]byte, 56)
+	copy(msg, ntlmSignature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint16(msg[12:], uint16(len(name)))
+	binary.LittleEndian.PutUint16(msg[14:], uint16(len(name)))
+	binary.LittleEndian.PutUint32(msg[16:], 56)
+	binary.LittleEndian.PutUint32(msg[20:], ntlmNegotiateFlags)
+	copy(msg[24:], "12345678") // server challenge
+	binary.LittleEndian.PutUint16(msg[40:], uint16(len(info)))
+	binary.LittleEndian.PutUint16(msg[42:], uint16(len(info)))
+	binary.LittleEndian.PutUint32(msg[44:], uint32(56+len(name)))
+	msg[48], msg[49] = major, minor
+	binary.LittleEndian.PutUint16(msg[50:], build)
+	msg[55] = 15 // NTLM revision
+	return append(append(msg, name...), info...)
+}
+
+// newFakeSMBServer answers an SMB2 negotiate with dialect 3.0.2 and
+// securityMode, and an NTLMSSP session setup with challenge.
+func newFakeSMBServer(t *testing.T, securityMode uint16, challenge []byte) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMB(conn, securityMode, challenge)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func serveSMB(conn net.Conn, securityMode uint16, challenge []byte) {
+	defer conn.Close()
+	for {
+		frame := make([]byte, 4)
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(frame))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		command := binary.LittleEndian.Uint16(req[12:])
+		resp := smb2Header(command, binary.LittleEndian.Uint64(req[24:]))
+		switch command {
+		case smb2Negotiate:
+			body := make([]byte, 64)
+			binary.LittleEndian.PutUint16(body[0:], 65)
+			binary.LittleEndian.PutUint16(body[2:], securityMode)
+			binary.LittleEndian.PutUint16(body[4:], 0x0302)
+			resp = append(resp, body...)
+		case smb2SessionSetup:
+			if !bytes.Contains(req, ntlmNegotiate()) {
+				return
+			}
+			binary.LittleEndian.PutUint32(resp[8:], statusMoreProcessingRequired)
+			// The challenge comes wrapped in an SPNEGO NegTokenResp.
+			blob := derTLV(0xa1, derTLV(0x30, derTLV(0xa2, derTLV(0x04, challenge))))
+			body := make([]byte, 8)
+			binary.LittleEndian.PutUint16(body[0:], 9)
+			binary.LittleEndian.PutUint16(body[4:], 64+8)
+			binary.LittleEndian.PutUint16(body[6:], uint16(len(blob)))
+			resp = append(append(resp, body...), blob...)
+		}
+		conn.Write(append(binary.BigEndian.AppendUint32(nil, uint32(len(resp))), resp...))
+	}
+}
+
+// newFakeNBNS answers node status requests with names.
+func newFakeNBNS(t *testing.T, names ...netbiosName) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if bytes.Equal(buf[:n], nodeStatusRequest()) {
+				conn.WriteTo(nodeStatusResponse([]byte{0x00, 0x50, 0x56, 0x01, 0x02, 0x03}, names...), addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// probeSMB runs the SMB prober against the fake servers.
+func probeSMB(t *testing.T, smbPort, nbnsPort int) (inventory.AssetModel, error) {
+	t.Helper()
+	p := &smbProber{smbPort: smbPort, nbnsPort: nbnsPort, timeout: 500 * time.Millisecond}
+	asset := inventory.AssetModel{IP: netip.MustParseAddr("192.0.2.80"), Attributes: map[string]string{}}
+	host := discovery.HostResult{IP: netip.MustParseAddr("127.0.0.1"), OpenPorts: map[int]time.Duration{smbPort: 0}}
+	err := p.Probe(context.Background(), host, &Accumulator{state: &hostState{asset: &asset, facts: &facts{}}, prober: "smb"})
+	return asset, err
+}
+
+func TestSMBProberDomainMember(t *testing.T) {
+	challenge := challengeMessage(10, 0, 19045, "CORP",
+		avPair{avNbDomainName, "CORP"},
+		avPair{avNbComputerName, "WS-0042"},
+		avPair{avDNSDomainName, "corp.example.com"},
+		avPair{avDNSComputerName, "ws-0042.corp.example.com"},
+	)
+	smbPort := newFakeSMBServer(t, 0x01, challenge)
+	nbnsPort := newFakeNBNS(t,
+		netbiosName{name: "WS-0042", suffix: 0x00},
+		netbiosName{name: "CORP", suffix: 0x00, group: true},
+	)
+	asset, err := probeSMB(t, smbPort, nbnsPort)
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if asset.Hostname != "WS-0042" || asset.Workgroup != "corp.example.com" {
+		t.Fatalf("hostname %q workgroup %q", asset.Hostname, asset.Workgroup)
+	}
+	wantAttrs := map[string]string{
+		"netbios_name":   "WS-0042",
+		"netbios_domain": "CORP",
+		"netbios_mac":    "00:50:56:01:02:03",
+		"dns_domain":     "corp.example.com",
+		"dns_hostname":   "ws-0042.corp.example.com",
+		"smb_dialect":    "3.0.2",
+		"smb_signing":    "enabled",
+		"os_build":       "10.0.19045",
+	}
+	if !reflect.DeepEqual(asset.Attributes, wantAttrs) {
+		t.Fatalf("attributes\n got %v\nwant %v", asset.Attributes, wantAttrs)
+	}
+	if got := evidenceFor(asset, inventory.FieldOSName); !reflect.DeepEqual(got, []string{"Windows"}) {
+		t.Fatalf("os_name evidence %v", got)
+	}
+	if got := evidenceFor(asset, inventory.FieldOSVersion); !reflect.DeepEqual(got, []string{"10.0.19045"}) {
+		t.Fatalf("os_version evidence %v", got)
+	}
+}
+
+func TestSMBProberWorkgroup(t *testing.T) {
+	// A standalone server is its own NTLM domain; its workgroup comes from
+	// NetBIOS.
+	challenge := challengeMessage(10, 0, 20348, "FS01",
+		avPair{avNbDomainName, "FS01"},
+		avPair{avNbComputerName, "FS01"},
+		avPair{avDNSDomainName, "fs01"},
+		avPair{avDNSComputerName, "fs01"},
+	)
+	smbPort := newFakeSMBServer(t, 0x03, challenge)
+	nbnsPort := newFakeNBNS(t, netbiosName{name: "FS01", suffix: 0x20}, netbiosName{name: "OFFICE", suffix: 0x00, group: true})
+	asset, err := probeSMB(t, smbPort, nbnsPort)
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if asset.Hostname != "FS01" || asset.Workgroup != "OFFICE" || asset.Attributes["smb_signing"] != "required" {
+		t.Fatalf("hostname %q workgroup %q attributes %v", asset.Hostname, asset.Workgroup, asset.Attributes)
+	}
+	if _, ok := asset.Attributes["dns_domain"]; ok {
+		t.Fatalf("standalone host given a domain: %v", asset.Attributes)
+	}
+}
+
+func TestSMBProberSamba(t *testing.T) {
+	// Samba reports version 6.1 build 0, which is no Windows build; NetBIOS
+	// is silent.
+	challenge := challengeMessage(6, 1, 0, "WORKGROUP",
+		avPair{avNbDomainName, "WORKGROUP"},
+		avPair{avNbComputerName, "NAS"},
+	)
+	smbPort := newFakeSMBServer(t, 0x01, challenge)
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	asset, err := probeSMB(t, smbPort, silent.LocalAddr().(*net.UDPAddr).Port)
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if asset.Hostname != "NAS" || asset.Workgroup != "WORKGROUP" || len(asset.Evidence) != 0 {
+		t.Fatalf("hostname %q workgroup %q evidence %+v", asset.Hostname, asset.Workgroup, asset.Evidence)
+	}
+}
@@ -0,0 +1,313 @@
+package fingerprint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/config"
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+)
+
+// Common SNMP OIDs for device identification
+const (
+	oidSysDescr    = ".1.3.6.1.2.1.1.1.0" // System description
+	oidSysObjectID = ".1.3.6.1.2.1.1.2.0" // System Object ID
+	oidSysName     = ".1.3.6.1.2.1.1.5.0" // System name
+	oidSysContact  = ".1.3.6.1.2.1.1.4.0" // System contact
+	oidSysLocation = ".1.3.6.1.2.1.1.6.0" // System location
+)
+
+// snmpProber reads the SNMP system group. SNMP runs over udp/161; tcp/161
+// is kept for agents that also listen on TCP. The prober also runs when
+// udp/161 stayed silent, since an agent ignores discovery's probe unless
+// it happens to carry a community the agent accepts.
+type snmpProber struct {
+	creds []config.Credential
+	cache *SNMPCache
+	port  uint16
+	// timeout bounds each request; requests are retried once.
+	timeout time.Duration
+	verbose bool
+}
+
+func (p *snmpProber) Name() string { return "snmp" }
+
+func (p *snmpProber) Ports() []Port { return []Port{UDP(int(p.port)), TCP(int(p.port))} }
+
+// probesSilentUDP lets the prober try its credentials on agents that
+// ignored discovery's probe.
+func (p *snmpProber) probesSilentUDP() {}
+
+// Probe performs SNMP queries to identify the device, trying the
+// combination of credential and version that last worked for the host
+// first.
+func (p *snmpProber) Probe(ctx context.Context, host discovery.HostResult, acc *Accumulator) error {
+	ip := host.IP.String()
+	attempts := p.attempts(ip, host.Site)
+	if len(attempts) == 0 {
+		return fmt.Errorf("no SNMP credential for site %q", host.Site)
+	}
+	var errs []error
+	for _, a := range attempts {
+		if p.verbose {
+			fmt.Printf("[SNMP] Attempting SNMP query to %s (%s)\n", ip, a)
+		}
+		snmp, result, err := p.get(ctx, ip, a)
+		if err != nil {
+			if p.verbose {
+				fmt.Printf("[SNMP] Query failed for %s (%s): %v\n", ip, a, err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", a, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if p.verbose {
+			fmt.Printf("[SNMP] Successfully queried %s\n", ip)
+		}
+		p.cache.remember(ip, a.cred.Name, a.versionName())
+		acc.SetAttribute("snmp_credential", a.cred.Name)
+		acc.SetAttribute("snmp_version", a.versionName())
+		p.record(result, acc)
+		p.collect(snmp, acc)
+		snmp.Conn.Close()
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// get queries the system group of ip. On success the connection is left
+// open for further queries.
+func (p *snmpProber) get(ctx context.Context, ip string, a snmpAttempt) (*gosnmp.GoSNMP, *gosnmp.SnmpPacket, error) {
+	snmp := a.client(ctx, ip, p.port, p.timeout)
+	if err := snmp.Connect(); err != nil {
+		return nil, nil, fmt.Errorf("connect: %w", err)
+	}
+
+	// Query system description
+	oids := []string{oidSysDescr, oidSysName, oidSysObjectID}
+	result, err := snmp.Get(oids)
+	if err == nil && result.Error != gosnmp.NoError {
+		err = fmt.Errorf("%s", result.Error)
+	}
+	if err != nil {
+		snmp.Conn.Close()
+		return nil, nil, fmt.Errorf("get system group: %w", err)
+	}
+	return snmp, result, nil
+}
+
+// collect walks the MIB tables describing the device's hardware and
+// configuration. A table the agent does not implement is skipped; a failed
+// walk leaves its part of the inventory empty.
+func (p *snmpProber) collect(snmp *gosnmp.GoSNMP, acc *Accumulator) {
+	collectors := []struct {
+		name string
+		fn   func(*gosnmp.GoSNMP, *Accumulator) error
+	}{
+		{"interfaces", walkInterfaces},
+		{"neighbors", walkNeighbors},
+		{"forwarding table", walkBridge},
+		{"ARP table", walkARP},
+		{"printer", walkPrinter},
+		{"entities", walkEntities},
+		{"host resources", walkHostResources},
+	}
+	for _, c := range collectors {
+		if err := c.fn(snmp, acc); err != nil && p.verbose {
+			fmt.Printf("[SNMP] Walking %s of %s failed: %v\n", c.name, snmp.Target, err)
+		}
+	}
+}
+
+// walk calls fn with the instance suffix and value of each object under the
+// table column oid.
+func walk(snmp *gosnmp.GoSNMP, column string, fn func(index string, pdu gosnmp.SnmpPDU)) error {
+	return snmp.BulkWalk(column, func(pdu gosnmp.SnmpPDU) error {
+		fn(strings.TrimPrefix(pdu.Name, column+"."), pdu)
+		return nil
+	})
+}
+
+// dotted returns oid with the leading dot gosnmp uses for OID names.
+func dotted(oid string) string {
+	return "." + strings.TrimPrefix(oid, ".")
+}
+
+// snmpString returns the text of an OCTET STRING value.
+func snmpString(pdu gosnmp.SnmpPDU) string {
+	b, _ := pdu.Value.([]byte)
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// snmpInt returns an integer value, or 0 when the value is not numeric.
+func snmpInt(pdu gosnmp.SnmpPDU) int64 {
+	switch pdu.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		return gosnmp.ToBigInt(pdu.Value).Int64()
+	}
+	return 0
+}
+
+func (p *snmpProber) record(result *gosnmp.SnmpPacket, acc *Accumulator) {
+	var sysDescr, sysObjectID, sysName string
+	for _, variable := range result.Variables {
+		switch variable.Name {
+		case oidSysDescr:
+			if desc, ok := variable.Value.([]byte); ok {
+				sysDescr = string(desc)
+				if p.verbose {
+					fmt.Printf("[SNMP]   sysDescr: %s\n", sysDescr)
+				}
+			}
+
+		case oidSysName:
+			if name, ok := variable.Value.([]byte); ok {
+				sysName = string(name)
+				if p.verbose {
+					fmt.Printf("[SNMP]   sysName: %s\n", sysName)
+				}
+			}
+
+		case oidSysObjectID:
+			if oid, ok := variable.Value.(string); ok {
+				sysObjectID = oid
+			}
+		}
+	}
+	acc.SetSNMPSystem(sysDescr, sysObjectID, sysName)
+}
+
+// snmpAttempt is one way of querying an agent: a credential used with one
+// SNMP version.
+type snmpAttempt struct {
+	cred    config.Credential
+	version gosnmp.SnmpVersion
+}
+
+// String describes the attempt without its secrets.
+func (a snmpAttempt) String() string {
+	return fmt.Sprintf("credential %s, SNMPv%s", a.cred.Name, a.versionName())
+}
+
+func (a snmpAttempt) versionName() string {
+	if a.version == gosnmp.Version3 {
+		return "3"
+	}
+	return "2c"
+}
+
+// attempts lists the ways to query ip, a host of site, in credential
+// order, starting with the one that last worked for it.
+func (p *snmpProber) attempts(ip, site string) []snmpAttempt {
+	var out []snmpAttempt
+	for _, cred := range p.creds {
+		if cred.AppliesToSite(site) {
+			out = append(out, credentialAttempts(cred)...)
+		}
+	}
+	if name, version, ok := p.cache.lookup(ip); ok {
+		for i, a := range out {
+			if a.cred.Name == name && a.versionName() == version {
+				copy(out[1:i+1], out[:i])
+				out[0] = a
+				break
+			}
+		}
+	}
+	return out
+}
+
+// credentialAttempts lists the versions to try cred with: SNMPv3 when it
+// has a username, SNMPv2c when it has a community, unless it names one.
+func credentialAttempts(cred config.Credential) []snmpAttempt {
+	var out []snmpAttempt
+	if (cred.SNMPVersion == "3" || cred.SNMPVersion == "") && cred.Username != "" {
+		out = append(out, snmpAttempt{cred, gosnmp.Version3})
+	}
+	if (cred.SNMPVersion == "2c" || cred.SNMPVersion == "") && cred.Community != "" {
+		out = append(out, snmpAttempt{cred, gosnmp.Version2c})
+	}
+	return out
+}
+
+// snmpCollectTimeout is the time left for the MIB walks once a credential
+// has been answered.
+const snmpCollectTimeout = 30 * time.Second
+
+// budget returns the prober timeout that lets every credential be tried
+// before the walks: each attempt may send its request twice, and SNMPv3
+// first discovers the agent's engine ID the same way.
+func (p *snmpProber) budget() time.Duration {
+	requests := 0
+	for _, cred := range p.creds {
+		for _, a := range credentialAttempts(cred) {
+			requests++
+			if a.version == gosnmp.Version3 {
+				requests++
+			}
+		}
+	}
+	return time.Duration(2*requests)*p.timeout + snmpCollectTimeout
+}
+
+var (
+	snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+		"MD5":    gosnmp.MD5,
+		"SHA":    gosnmp.SHA,
+		"SHA224": gosnmp.SHA224,
+		"SHA256": gosnmp.SHA256,
+		"SHA384": gosnmp.SHA384,
+		"SHA512": gosnmp.SHA512,
+	}
+	snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+		"DES":     gosnmp.DES,
+		"AES":     gosnmp.AES,
+		"AES192":  gosnmp.AES192,
+		"AES256":  gosnmp.AES256,
+		"AES192C": gosnmp.AES192C,
+		"AES256C": gosnmp.AES256C,
+	}
+)
+
+// client configures a query of ip for the attempt.
+func (a snmpAttempt) client(ctx context.Context, ip string, port uint16, timeout time.Duration) *gosnmp.GoSNMP {
+	snmp := &gosnmp.GoSNMP{
+		Target:  ip,
+		Port:    port,
+		Version: a.version,
+		Timeout: timeout,
+		Retries: 1,
+		Context: ctx,
+	}
+	if a.version != gosnmp.Version3 {
+		snmp.Community = a.cred.Community
+		return snmp
+	}
+
+	usm := &gosnmp.UsmSecurityParameters{
+		UserName:               a.cred.Username,
+		AuthenticationProtocol: gosnmp.NoAuth,
+		PrivacyProtocol:        gosnmp.NoPriv,
+	}
+	snmp.MsgFlags = gosnmp.NoAuthNoPriv
+	if auth, ok := snmpAuthProtocols[strings.ToUpper(a.cred.AuthProtocol)]; ok {
+		usm.AuthenticationProtocol = auth
+		usm.AuthenticationPassphrase = a.cred.AuthPassword
+		snmp.MsgFlags = gosnmp.AuthNoPriv
+		if priv, ok := snmpPrivProtocols[strings.ToUpper(a.cred.PrivProtocol)]; ok {
+			usm.PrivacyProtocol = priv
+			usm.PrivacyPassphrase = a.cred.PrivPassword
+			snmp.MsgFlags = gosnmp.AuthPriv
+		}
+	}
+	snmp.SecurityModel = gosnmp.UserSecurityModel
+	snmp.SecurityParameters = usm
+	snmp.ContextName = a.cred.ContextName
+	return snmp
+}
@@ -0,0 +1,176 @@
+package fingerprint
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// BRIDGE-MIB, Q-BRIDGE-MIB and IP-MIB objects read for the forwarding and
+// ARP tables.
+const (
+	oidDot1dBasePortIfIndex = ".1.3.6.1.2.1.17.1.4.1.2"
+	oidDot1dTpFdbPort       = ".1.3.6.1.2.1.17.4.3.1.2"
+	oidDot1dTpFdbStatus     = ".1.3.6.1.2.1.17.4.3.1.3"
+	oidDot1qTpFdbPort       = ".1.3.6.1.2.1.17.7.1.2.2.1.2"
+	oidDot1qTpFdbStatus     = ".1.3.6.1.2.1.17.7.1.2.2.1.3"
+	oidDot1qVlanFdbID       = ".1.3.6.1.2.1.17.7.1.4.2.1.3"
+
+	oidIPNetToMediaPhysAddress = ".1.3.6.1.2.1.4.22.1.2"
+	oidIPNetToMediaType        = ".1.3.6.1.2.1.4.22.1.4"
+)
+
+// Forwarding database entry statuses that do not describe a device behind
+// the port: invalid entries and the switch's own addresses.
+const (
+	fdbStatusInvalid = 2
+	fdbStatusSelf    = 4
+)
+
+// ipNetToMediaType value of entries that are being removed.
+const arpTypeInvalid = 2
+
+// walkBridge records the MAC addresses the switch learned on its ports.
+// The per-VLAN Q-BRIDGE-MIB table is preferred; switches without it are
+// read from the BRIDGE-MIB dot1dTpFdbTable, which has no VLAN.
+func walkBridge(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	portIfIndex := map[string]int{}
+	err := walk(snmp, oidDot1dBasePortIfIndex, func(index string, pdu gosnmp.SnmpPDU) {
+		portIfIndex[index] = int(snmpInt(pdu))
+	})
+	if err != nil {
+		return err
+	}
+
+	// dot1qTpFdbTable is indexed by filtering database and MAC address.
+	// The filtering database of each VLAN is given by dot1qVlanFdbId; most
+	// switches use the VLAN ID itself.
+	fdbVLAN := map[string]int{}
+	err = walk(snmp, oidDot1qVlanFdbID, func(index string, pdu gosnmp.SnmpPDU) {
+		if _, vlan, ok := strings.Cut(index, "."); ok {
+			if n, err := strconv.Atoi(vlan); err == nil {
+				fdbVLAN[strconv.FormatInt(snmpInt(pdu), 10)] = n
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	entries, err := walkFdb(snmp, oidDot1qTpFdbPort, oidDot1qTpFdbStatus)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if entries, err = walkFdb(snmp, oidDot1dTpFdbPort, oidDot1dTpFdbStatus); err != nil {
+			return err
+		}
+	}
+
+	for _, index := range sortedIndexes(entries) {
+		e := entries[index]
+		parts := strings.Split(index, ".")
+		if len(parts) == 7 {
+			fdb := parts[0]
+			if vlan, ok := fdbVLAN[fdb]; ok {
+				e.entry.VLAN = vlan
+			} else {
+				e.entry.VLAN, _ = strconv.Atoi(fdb)
+			}
+		}
+		e.entry.IfIndex = portIfIndex[e.port]
+		if iface, ok := acc.localInterface("", e.entry.IfIndex); ok {
+			e.entry.Port = interfaceName(iface)
+		}
+		acc.AddForwarding(e.entry)
+	}
+	return nil
+}
+
+// fdbEntry is a forwarding entry along with its bridge port number.
+type fdbEntry struct {
+	entry inventory.ForwardingEntry
+	port  string
+}
+
+// walkFdb reads a forwarding table whose index ends with the six octets of
+// the MAC address, skipping invalid entries and the switch's own.
+func walkFdb(snmp *gosnmp.GoSNMP, portColumn, statusColumn string) (map[string]fdbEntry, error) {
+	status := map[string]int64{}
+	err := walk(snmp, statusColumn, func(index string, pdu gosnmp.SnmpPDU) {
+		status[index] = snmpInt(pdu)
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := map[string]fdbEntry{}
+	err = walk(snmp, portColumn, func(index string, pdu gosnmp.SnmpPDU) {
+		if s := status[index]; s == fdbStatusInvalid || s == fdbStatusSelf {
+			return
+		}
+		port := snmpInt(pdu)
+		if port == 0 {
+			return
+		}
+		mac, ok := macIndex(index)
+		if !ok {
+			return
+		}
+		entries[index] = fdbEntry{inventory.ForwardingEntry{MAC: mac}, strconv.FormatInt(port, 10)}
+	})
+	return entries, err
+}
+
+// walkARP records the router's ipNetToMediaTable, indexed by ifIndex and
+// IPv4 address.
+func walkARP(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	invalid := map[string]bool{}
+	err := walk(snmp, oidIPNetToMediaType, func(index string, pdu gosnmp.SnmpPDU) {
+		invalid[index] = snmpInt(pdu) == arpTypeInvalid
+	})
+	if err != nil {
+		return err
+	}
+	var entries []inventory.ARPEntry
+	err = walk(snmp, oidIPNetToMediaPhysAddress, func(index string, pdu gosnmp.SnmpPDU) {
+		b, ok := pdu.Value.([]byte)
+		if !ok || len(b) != 6 || invalid[index] {
+			return
+		}
+		ifIndex, addr, _ := strings.Cut(index, ".")
+		ip, err := netip.ParseAddr(addr)
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(ifIndex)
+		entries = append(entries, inventory.ARPEntry{IP: ip, MAC: net.HardwareAddr(b).String(), IfIndex: n})
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		acc.AddARP(e)
+	}
+	return nil
+}
+
+// macIndex decodes the MAC address in the last six sub-identifiers of a
+// table index.
+func macIndex(index string) (string, bool) {
+	parts := strings.Split(index, ".")
+	if len(parts) < 6 {
+		return "", false
+	}
+	mac := make(net.HardwareAddr, 6)
+	for i, part := range parts[len(parts)-6:] {
+		n, err := strconv.Atoi(part)
+		if err != nil || n > 255 {
+			return "", false
+		}
+		mac[i] = byte(n)
+	}
+	return mac.String(), true
+}
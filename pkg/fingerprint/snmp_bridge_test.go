@@ -0,0 +1,68 @@
+package fingerprint
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+func TestWalkBridgeQBridge(t *testing.T) {
+	pdus := append([]gosnmp.SnmpPDU{
+		integer(oidDot1dBasePortIfIndex+".1", 1),
+		integer(oidDot1dBasePortIfIndex+".2", 2),
+		// VLAN 20 uses filtering database 2; VLAN 10 has none listed and
+		// uses its own ID.
+		gauge(oidDot1qVlanFdbID+".0.20", 2),
+		integer(oidDot1qTpFdbPort+".10.0.80.86.1.2.3", 1),
+		integer(oidDot1qTpFdbPort+".2.0.80.86.1.2.4", 2),
+		integer(oidDot1qTpFdbPort+".2.0.27.84.170.187.1", 0),
+		integer(oidDot1qTpFdbStatus+".10.0.80.86.1.2.3", 3),
+		integer(oidDot1qTpFdbStatus+".2.0.80.86.1.2.4", 3),
+		integer(oidDot1qTpFdbStatus+".2.0.27.84.170.187.1", fdbStatusSelf),
+		// The BRIDGE-MIB view of the same table is ignored.
+		integer(oidDot1dTpFdbPort+".0.80.86.1.2.3", 1),
+	}, ifEntries...)
+	asset := probeFakeAgent(t, "192.0.2.60", pdus...)
+
+	want := []inventory.ForwardingEntry{
+		{MAC: "00:50:56:01:02:04", IfIndex: 2, Port: "Te1/1", VLAN: 20},
+		{MAC: "00:50:56:01:02:03", IfIndex: 1, Port: "Gi0/1", VLAN: 10},
+	}
+	if !reflect.DeepEqual(asset.Forwarding, want) {
+		t.Fatalf("forwarding\n got %+v\nwant %+v", asset.Forwarding, want)
+	}
+}
+
+func TestWalkBridgeDot1d(t *testing.T) {
+	pdus := append([]gosnmp.SnmpPDU{
+		integer(oidDot1dBasePortIfIndex+".1", 1),
+		integer(oidDot1dTpFdbPort+".0.80.86.1.2.3", 1),
+		integer(oidDot1dTpFdbStatus+".0.80.86.1.2.3", 3),
+		integer(oidDot1dTpFdbPort+".0.80.86.1.2.9", 1),
+		integer(oidDot1dTpFdbStatus+".0.80.86.1.2.9", fdbStatusInvalid),
+	}, ifEntries...)
+	asset := probeFakeAgent(t, "192.0.2.61", pdus...)
+
+	want := []inventory.ForwardingEntry{{MAC: "00:50:56:01:02:03", IfIndex: 1, Port: "Gi0/1"}}
+	if !reflect.DeepEqual(asset.Forwarding, want) {
+		t.Fatalf("forwarding\n got %+v\nwant %+v", asset.Forwarding, want)
+	}
+}
+
+func TestWalkARP(t *testing.T) {
+	asset := probeFakeAgent(t, "192.0.2.62",
+		octets(oidSysDescr, "Cisco IOS Software"),
+		gosnmp.SnmpPDU{Name: oidIPNetToMediaPhysAddress + ".3.10.20.0.5", Type: gosnmp.OctetString, Value: []byte{0x00, 0x50, 0x56, 0x01, 0x02, 0x03}},
+		gosnmp.SnmpPDU{Name: oidIPNetToMediaPhysAddress + ".3.10.20.0.6", Type: gosnmp.OctetString, Value: []byte{0x00, 0x50, 0x56, 0x01, 0x02, 0x04}},
+		integer(oidIPNetToMediaType+".3.10.20.0.5", 3),
+		integer(oidIPNetToMediaType+".3.10.20.0.6", arpTypeInvalid),
+	)
+
+	want := []inventory.ARPEntry{{IP: netip.MustParseAddr("10.20.0.5"), MAC: "00:50:56:01:02:03", IfIndex: 3}}
+	if !reflect.DeepEqual(asset.ARP, want) {
+		t.Fatalf("ARP\n got %+v\nwant %+v", asset.ARP, want)
+	}
+}
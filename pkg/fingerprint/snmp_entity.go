@@ -0,0 +1,92 @@
+package fingerprint
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// ENTITY-MIB entPhysicalTable columns.
+const (
+	oidEntPhysicalDescr       = ".1.3.6.1.2.1.47.1.1.1.1.2"
+	oidEntPhysicalContainedIn = ".1.3.6.1.2.1.47.1.1.1.1.4"
+	oidEntPhysicalClass       = ".1.3.6.1.2.1.47.1.1.1.1.5"
+	oidEntPhysicalName        = ".1.3.6.1.2.1.47.1.1.1.1.7"
+	oidEntPhysicalHardwareRev = ".1.3.6.1.2.1.47.1.1.1.1.8"
+	oidEntPhysicalFirmwareRev = ".1.3.6.1.2.1.47.1.1.1.1.9"
+	oidEntPhysicalSoftwareRev = ".1.3.6.1.2.1.47.1.1.1.1.10"
+	oidEntPhysicalSerialNum   = ".1.3.6.1.2.1.47.1.1.1.1.11"
+	oidEntPhysicalMfgName     = ".1.3.6.1.2.1.47.1.1.1.1.12"
+	oidEntPhysicalModelName   = ".1.3.6.1.2.1.47.1.1.1.1.13"
+)
+
+// physicalClassNames maps ENTITY-MIB PhysicalClass values to their names.
+var physicalClassNames = map[int64]string{
+	1: "other", 2: "unknown", 3: "chassis", 4: "backplane", 5: "container",
+	6: "powerSupply", 7: "fan", 8: "sensor", 9: "module", 10: "port",
+	11: "stack", 12: "cpu",
+}
+
+// entityModelWeight is the weight of a chassis model name as evidence for
+// the model: it is read from the hardware itself, so it outweighs a model
+// guessed from sysDescr.
+const entityModelWeight = 80
+
+// walkEntities records the physical components of the device. The first
+// chassis, in index order, supplies the device's serial number, model and
+// firmware; on a stack that is the first member.
+func walkEntities(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	components := map[string]*inventory.HardwareComponent{}
+	get := func(index string) *inventory.HardwareComponent {
+		if components[index] == nil {
+			n, _ := strconv.Atoi(index)
+			components[index] = &inventory.HardwareComponent{Index: n}
+		}
+		return components[index]
+	}
+	columns := []struct {
+		oid string
+		set func(*inventory.HardwareComponent, gosnmp.SnmpPDU)
+	}{
+		{oidEntPhysicalDescr, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.Description = snmpString(pdu) }},
+		{oidEntPhysicalContainedIn, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.ContainedIn = int(snmpInt(pdu)) }},
+		{oidEntPhysicalClass, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.Class = physicalClassNames[snmpInt(pdu)] }},
+		{oidEntPhysicalName, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.Name = snmpString(pdu) }},
+		{oidEntPhysicalHardwareRev, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.HardwareRev = snmpString(pdu) }},
+		{oidEntPhysicalFirmwareRev, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.FirmwareRev = snmpString(pdu) }},
+		{oidEntPhysicalSoftwareRev, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.SoftwareRev = snmpString(pdu) }},
+		{oidEntPhysicalSerialNum, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.Serial = snmpString(pdu) }},
+		{oidEntPhysicalMfgName, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.Manufacturer = snmpString(pdu) }},
+		{oidEntPhysicalModelName, func(c *inventory.HardwareComponent, pdu gosnmp.SnmpPDU) { c.Model = snmpString(pdu) }},
+	}
+	for _, col := range columns {
+		err := walk(snmp, col.oid, func(index string, pdu gosnmp.SnmpPDU) {
+			col.set(get(index), pdu)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	chassis := false
+	for _, index := range sortedIndexes(components) {
+		c := *components[index]
+		acc.AddComponent(c)
+		if c.Class != "chassis" || chassis {
+			continue
+		}
+		chassis = true
+		acc.SetSerial(c.Serial)
+		if c.Model != "" {
+			acc.AddEvidence(inventory.FieldModel, c.Model, entityModelWeight, fmt.Sprintf("ENTITY-MIB chassis %d model", c.Index))
+		}
+		if c.SoftwareRev != "" {
+			acc.SetFirmware(c.SoftwareRev)
+		} else {
+			acc.SetFirmware(c.FirmwareRev)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package fingerprint
+
+import (
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+func TestWalkEntitiesStack(t *testing.T) {
+	asset := probeFakeAgent(t, "192.0.2.40",
+		octets(oidSysDescr, "Cisco IOS Software, C2960X Software"),
+		// A stack of two switches, each holding a module.
+		integer(oidEntPhysicalClass+".1", 11),
+		integer(oidEntPhysicalClass+".1001", 3),
+		integer(oidEntPhysicalClass+".1002", 9),
+		integer(oidEntPhysicalClass+".2001", 3),
+		integer(oidEntPhysicalContainedIn+".1", 0),
+		integer(oidEntPhysicalContainedIn+".1001", 1),
+		integer(oidEntPhysicalContainedIn+".1002", 1001),
+		integer(oidEntPhysicalContainedIn+".2001", 1),
+		octets(oidEntPhysicalName+".1", "c29xxStack"),
+		octets(oidEntPhysicalName+".1001", "Switch 1"),
+		octets(oidEntPhysicalName+".1002", "Switch 1 - FlexStackPlus Module"),
+		octets(oidEntPhysicalName+".2001", "Switch 2"),
+		octets(oidEntPhysicalModelName+".1001", "WS-C2960X-48FPD-L"),
+		octets(oidEntPhysicalModelName+".1002", "C2960X-STACK"),
+		octets(oidEntPhysicalModelName+".2001", "WS-C2960X-24PD-L"),
+		octets(oidEntPhysicalSerialNum+".1001", "FOC1234X0AB"),
+		octets(oidEntPhysicalSerialNum+".1002", "FOC1234X0CD"),
+		octets(oidEntPhysicalSerialNum+".2001", "FOC5678X0EF"),
+		octets(oidEntPhysicalSoftwareRev+".1001", "15.2(7)E3"),
+		octets(oidEntPhysicalHardwareRev+".1001", "V06"),
+		octets(oidEntPhysicalMfgName+".1001", "Cisco"),
+	)
+
+	if asset.Serial != "FOC1234X0AB" || asset.Firmware != "15.2(7)E3" {
+		t.Fatalf("serial %q firmware %q", asset.Serial, asset.Firmware)
+	}
+	if len(asset.Components) != 4 {
+		t.Fatalf("components %+v", asset.Components)
+	}
+	member := asset.Components[1]
+	want := inventory.HardwareComponent{
+		Index: 1001, ContainedIn: 1, Class: "chassis", Name: "Switch 1", Manufacturer: "Cisco",
+		Model: "WS-C2960X-48FPD-L", Serial: "FOC1234X0AB", HardwareRev: "V06", SoftwareRev: "15.2(7)E3",
+	}
+	if member != want {
+		t.Fatalf("stack member\n got %+v\nwant %+v", member, want)
+	}
+	if asset.Components[0].Class != "stack" || asset.Components[2].Class != "module" || asset.Components[3].Serial != "FOC5678X0EF" {
+		t.Fatalf("components %+v", asset.Components)
+	}
+	var model []inventory.Evidence
+	for _, ev := range asset.Evidence {
+		if ev.Field == inventory.FieldModel {
+			model = append(model, ev)
+		}
+	}
+	if len(model) != 1 || model[0].Value != "WS-C2960X-48FPD-L" || model[0].Weight != entityModelWeight {
+		t.Fatalf("model evidence %+v", model)
+	}
+}
+
+func TestWalkHostResources(t *testing.T) {
+	asset := probeFakeAgent(t, "192.0.2.41",
+		octets(oidSysDescr, "Linux web01 5.15.0-91-generic"),
+		integer(oidHrMemorySize, 8161024),
+		gosnmp.SnmpPDU{Name: oidHrStorageType + ".1", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.2.1.25.2.1.2"},
+		gosnmp.SnmpPDU{Name: oidHrStorageType + ".31", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.2.1.25.2.1.4"},
+		octets(oidHrStorageDescr+".1", "Physical memory"),
+		octets(oidHrStorageDescr+".31", "/"),
+		integer(oidHrStorageUnits+".1", 1024),
+		integer(oidHrStorageUnits+".31", 4096),
+		integer(oidHrStorageSize+".1", 8161024),
+		integer(oidHrStorageSize+".31", 25600000),
+		integer(oidHrStorageUsed+".1", 4000000),
+		integer(oidHrStorageUsed+".31", 12800000),
+		gosnmp.SnmpPDU{Name: oidHrDeviceType + ".196608", Type: gosnmp.ObjectIdentifier, Value: oidHrDeviceTypeCPU},
+		gosnmp.SnmpPDU{Name: oidHrDeviceType + ".196609", Type: gosnmp.ObjectIdentifier, Value: oidHrDeviceTypeCPU},
+		octets(oidHrDeviceDescr+".196608", "GenuineIntel: Intel(R) Xeon(R) CPU E5-2680 v4"),
+		octets(oidHrDeviceDescr+".196609", "GenuineIntel: Intel(R) Xeon(R) CPU E5-2680 v4"),
+		integer(oidHrProcessorLoad+".196608", 12),
+		integer(oidHrProcessorLoad+".196609", 3),
+		octets(oidHrSWRunName+".1", "systemd"),
+		octets(oidHrSWRunName+".812", "nginx"),
+		octets(oidHrSWRunPath+".812", "/usr/sbin/nginx"),
+		octets(oidHrSWRunParameters+".812", "-g daemon off;"),
+		integer(oidHrSWRunPerfMem+".812", 20480),
+		octets(oidHrSWInstalledName+".1", "nginx-1.18.0-6ubuntu14"),
+		gosnmp.SnmpPDU{Name: oidHrSWInstalledDate + ".1", Type: gosnmp.OctetString, Value: []byte{0x07, 0xe7, 10, 3, 14, 2, 0, 0}},
+		octets(oidHrSWInstalledName+".2", "openssl-3.0.2"),
+		gosnmp.SnmpPDU{Name: oidHrSWInstalledDate + ".2", Type: gosnmp.OctetString, Value: []byte{0, 0, 0, 0, 0, 0, 0, 0}},
+	)
+
+	host := asset.Host
+	if host == nil {
+		t.Fatal("no host resources")
+	}
+	if host.Memory != 8161024*1024 {
+		t.Fatalf("memory %d", host.Memory)
+	}
+	if len(host.Storage) != 2 || host.Storage[1] != (inventory.Storage{Description: "/", Type: "fixedDisk", Size: 25600000 * 4096, Used: 12800000 * 4096}) || host.Storage[0].Type != "ram" {
+		t.Fatalf("storage %+v", host.Storage)
+	}
+	if len(host.Processors) != 2 || host.Processors[0].Load != 12 {
+		t.Fatalf("processors %+v", host.Processors)
+	}
+	if len(host.Processes) != 2 || host.Processes[1] != (inventory.Process{PID: 812, Name: "nginx", Path: "/usr/sbin/nginx", Args: "-g daemon off;", Memory: 20480 * 1024}) {
+		t.Fatalf("processes %+v", host.Processes)
+	}
+	wantSoftware := []inventory.Software{{Name: "nginx-1.18.0-6ubuntu14", Installed: "2023-10-03"}, {Name: "openssl-3.0.2"}}
+	if len(host.Software) != 2 || host.Software[0] != wantSoftware[0] || host.Software[1] != wantSoftware[1] {
+		t.Fatalf("software %+v", host.Software)
+	}
+}
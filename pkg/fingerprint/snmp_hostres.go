@@ -0,0 +1,169 @@
+package fingerprint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// HOST-RESOURCES-MIB objects read for the host inventory.
+const (
+	oidHrMemorySize      = ".1.3.6.1.2.1.25.2.2.0"
+	oidHrStorageType     = ".1.3.6.1.2.1.25.2.3.1.2"
+	oidHrStorageDescr    = ".1.3.6.1.2.1.25.2.3.1.3"
+	oidHrStorageUnits    = ".1.3.6.1.2.1.25.2.3.1.4"
+	oidHrStorageSize     = ".1.3.6.1.2.1.25.2.3.1.5"
+	oidHrStorageUsed     = ".1.3.6.1.2.1.25.2.3.1.6"
+	oidHrDeviceType      = ".1.3.6.1.2.1.25.3.2.1.2"
+	oidHrDeviceDescr     = ".1.3.6.1.2.1.25.3.2.1.3"
+	oidHrProcessorLoad   = ".1.3.6.1.2.1.25.3.3.1.2"
+	oidHrSWRunName       = ".1.3.6.1.2.1.25.4.2.1.2"
+	oidHrSWRunPath       = ".1.3.6.1.2.1.25.4.2.1.4"
+	oidHrSWRunParameters = ".1.3.6.1.2.1.25.4.2.1.5"
+	oidHrSWRunPerfMem    = ".1.3.6.1.2.1.25.5.1.1.2"
+	oidHrSWInstalledName = ".1.3.6.1.2.1.25.6.3.1.2"
+	oidHrSWInstalledDate = ".1.3.6.1.2.1.25.6.3.1.5"
+	oidHrStorageTypes    = ".1.3.6.1.2.1.25.2.1."
+	oidHrDeviceTypeCPU   = ".1.3.6.1.2.1.25.3.1.3"
+)
+
+// storageTypeNames maps hrStorageType identifiers, below hrStorageTypes,
+// to their names.
+var storageTypeNames = map[string]string{
+	"1":  "other",
+	"2":  "ram",
+	"3":  "virtualMemory",
+	"4":  "fixedDisk",
+	"5":  "removableDisk",
+	"6":  "floppyDisk",
+	"7":  "compactDisc",
+	"8":  "ramDisk",
+	"9":  "flashMemory",
+	"10": "networkDisk",
+}
+
+// walkHostResources records the processors, memory, storage, installed
+// software and running processes of hosts implementing the
+// HOST-RESOURCES-MIB.
+func walkHostResources(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	var host inventory.HostResources
+	result, err := snmp.Get([]string{oidHrMemorySize})
+	if err != nil {
+		return err
+	}
+	for _, pdu := range result.Variables {
+		host.Memory = uint64(snmpInt(pdu)) * 1024
+	}
+
+	storage := map[string]*inventory.Storage{}
+	units := map[string]uint64{}
+	sizes := map[string]uint64{}
+	used := map[string]uint64{}
+	deviceTypes := map[string]string{}
+	deviceDescr := map[string]string{}
+	loads := map[string]int{}
+	procs := map[string]*inventory.Process{}
+	software := map[string]*inventory.Software{}
+	proc := func(index string) *inventory.Process {
+		if procs[index] == nil {
+			pid, _ := strconv.Atoi(index)
+			procs[index] = &inventory.Process{PID: pid}
+		}
+		return procs[index]
+	}
+	sw := func(index string) *inventory.Software {
+		if software[index] == nil {
+			software[index] = &inventory.Software{}
+		}
+		return software[index]
+	}
+	store := func(index string) *inventory.Storage {
+		if storage[index] == nil {
+			storage[index] = &inventory.Storage{Type: "other"}
+		}
+		return storage[index]
+	}
+	columns := []struct {
+		oid string
+		fn  func(index string, pdu gosnmp.SnmpPDU)
+	}{
+		{oidHrStorageType, func(i string, pdu gosnmp.SnmpPDU) {
+			if id, ok := pdu.Value.(string); ok {
+				if name, ok := storageTypeNames[strings.TrimPrefix(dotted(id), oidHrStorageTypes)]; ok {
+					store(i).Type = name
+				}
+			}
+		}},
+		{oidHrStorageDescr, func(i string, pdu gosnmp.SnmpPDU) { store(i).Description = snmpString(pdu) }},
+		{oidHrStorageUnits, func(i string, pdu gosnmp.SnmpPDU) { units[i] = uint64(snmpInt(pdu)) }},
+		{oidHrStorageSize, func(i string, pdu gosnmp.SnmpPDU) { sizes[i] = uint64(snmpInt(pdu)) }},
+		{oidHrStorageUsed, func(i string, pdu gosnmp.SnmpPDU) { used[i] = uint64(snmpInt(pdu)) }},
+		{oidHrDeviceType, func(i string, pdu gosnmp.SnmpPDU) {
+			if id, ok := pdu.Value.(string); ok {
+				deviceTypes[i] = dotted(id)
+			}
+		}},
+		{oidHrDeviceDescr, func(i string, pdu gosnmp.SnmpPDU) { deviceDescr[i] = snmpString(pdu) }},
+		{oidHrProcessorLoad, func(i string, pdu gosnmp.SnmpPDU) { loads[i] = int(snmpInt(pdu)) }},
+		{oidHrSWRunName, func(i string, pdu gosnmp.SnmpPDU) { proc(i).Name = snmpString(pdu) }},
+		{oidHrSWRunPath, func(i string, pdu gosnmp.SnmpPDU) { proc(i).Path = snmpString(pdu) }},
+		{oidHrSWRunParameters, func(i string, pdu gosnmp.SnmpPDU) { proc(i).Args = snmpString(pdu) }},
+		{oidHrSWRunPerfMem, func(i string, pdu gosnmp.SnmpPDU) { proc(i).Memory = uint64(snmpInt(pdu)) * 1024 }},
+		{oidHrSWInstalledName, func(i string, pdu gosnmp.SnmpPDU) { sw(i).Name = snmpString(pdu) }},
+		{oidHrSWInstalledDate, func(i string, pdu gosnmp.SnmpPDU) {
+			b, _ := pdu.Value.([]byte)
+			sw(i).Installed = dateAndTime(b)
+		}},
+	}
+	for _, c := range columns {
+		if err := walk(snmp, c.oid, c.fn); err != nil {
+			return err
+		}
+	}
+
+	for _, index := range sortedIndexes(storage) {
+		s := storage[index]
+		s.Size = sizes[index] * units[index]
+		s.Used = used[index] * units[index]
+		host.Storage = append(host.Storage, *s)
+	}
+	// hrProcessorTable shares its index with hrDeviceTable.
+	for _, index := range sortedIndexes(loads) {
+		if t, ok := deviceTypes[index]; ok && t != oidHrDeviceTypeCPU {
+			continue
+		}
+		host.Processors = append(host.Processors, inventory.Processor{Description: deviceDescr[index], Load: loads[index]})
+	}
+	for _, index := range sortedIndexes(software) {
+		if s := software[index]; s.Name != "" {
+			host.Software = append(host.Software, *s)
+		}
+	}
+	for _, index := range sortedIndexes(procs) {
+		if p := procs[index]; p.Name != "" {
+			host.Processes = append(host.Processes, *p)
+		}
+	}
+
+	if host.Memory == 0 && len(host.Storage) == 0 && len(host.Processors) == 0 && len(host.Software) == 0 && len(host.Processes) == 0 {
+		return nil
+	}
+	acc.SetHostResources(host)
+	return nil
+}
+
+// dateAndTime formats the date of an SNMPv2-TC DateAndTime value as
+// YYYY-MM-DD. Unset dates, all zero, yield "".
+func dateAndTime(b []byte) string {
+	if len(b) < 4 {
+		return ""
+	}
+	year := int(b[0])<<8 | int(b[1])
+	if year == 0 || b[2] == 0 || b[3] == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", year, b[2], b[3])
+}
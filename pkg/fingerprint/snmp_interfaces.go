@@ -0,0 +1,224 @@
+package fingerprint
+
+import (
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// IF-MIB and IP-MIB columns read for the interface inventory.
+const (
+	oidIfDescr       = ".1.3.6.1.2.1.2.2.1.2"
+	oidIfType        = ".1.3.6.1.2.1.2.2.1.3"
+	oidIfMtu         = ".1.3.6.1.2.1.2.2.1.4"
+	oidIfSpeed       = ".1.3.6.1.2.1.2.2.1.5"
+	oidIfPhysAddress = ".1.3.6.1.2.1.2.2.1.6"
+	oidIfAdminStatus = ".1.3.6.1.2.1.2.2.1.7"
+	oidIfOperStatus  = ".1.3.6.1.2.1.2.2.1.8"
+	oidIfName        = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHighSpeed   = ".1.3.6.1.2.1.31.1.1.1.15"
+	oidIfAlias       = ".1.3.6.1.2.1.31.1.1.1.18"
+
+	oidIPAdEntIfIndex = ".1.3.6.1.2.1.4.20.1.2" // ipAddrTable, IPv4 only
+	oidIPAdEntNetMask = ".1.3.6.1.2.1.4.20.1.3" // ipAddrTable
+	oidIPAddressIf    = ".1.3.6.1.2.1.4.34.1.3" // ipAddressTable
+	oidIPAddressPfx   = ".1.3.6.1.2.1.4.34.1.5" // ipAddressTable
+)
+
+// ifStatusNames maps IF-MIB ifAdminStatus and ifOperStatus values to their
+// names.
+var ifStatusNames = map[int64]string{
+	1: "up",
+	2: "down",
+	3: "testing",
+	4: "unknown",
+	5: "dormant",
+	6: "notPresent",
+	7: "lowerLayerDown",
+}
+
+// walkInterfaces records the interface table along with the addresses
+// assigned to each interface.
+func walkInterfaces(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	ifaces := map[int]*inventory.NetworkInterface{}
+	get := func(index string) *inventory.NetworkInterface {
+		n, err := strconv.Atoi(index)
+		if err != nil {
+			return nil
+		}
+		if ifaces[n] == nil {
+			ifaces[n] = &inventory.NetworkInterface{Index: n}
+		}
+		return ifaces[n]
+	}
+	columns := []struct {
+		oid string
+		set func(*inventory.NetworkInterface, gosnmp.SnmpPDU)
+	}{
+		{oidIfDescr, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) { i.Description = snmpString(pdu) }},
+		{oidIfType, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) { i.Type = int(snmpInt(pdu)) }},
+		{oidIfMtu, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) { i.MTU = int(snmpInt(pdu)) }},
+		{oidIfSpeed, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) { i.Speed = uint64(snmpInt(pdu)) }},
+		{oidIfPhysAddress, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) {
+			if b, ok := pdu.Value.([]byte); ok && len(b) == 6 {
+				i.MAC = net.HardwareAddr(b).String()
+			}
+		}},
+		{oidIfAdminStatus, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) { i.AdminStatus = ifStatusNames[snmpInt(pdu)] }},
+		{oidIfOperStatus, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) { i.OperStatus = ifStatusNames[snmpInt(pdu)] }},
+		{oidIfName, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) { i.Name = snmpString(pdu) }},
+		// ifSpeed saturates at 4294967295; ifHighSpeed counts megabits.
+		{oidIfHighSpeed, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) {
+			if mbps := uint64(snmpInt(pdu)); mbps > 0 {
+				i.Speed = mbps * 1_000_000
+			}
+		}},
+		{oidIfAlias, func(i *inventory.NetworkInterface, pdu gosnmp.SnmpPDU) { i.Alias = snmpString(pdu) }},
+	}
+	for _, c := range columns {
+		err := walk(snmp, c.oid, func(index string, pdu gosnmp.SnmpPDU) {
+			if iface := get(index); iface != nil {
+				c.set(iface, pdu)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(ifaces) == 0 {
+		return nil
+	}
+
+	addresses, err := walkAddresses(snmp)
+	if err != nil {
+		return err
+	}
+	for index, prefixes := range addresses {
+		if iface := ifaces[index]; iface != nil {
+			iface.Addresses = prefixes
+		}
+	}
+
+	indexes := make([]int, 0, len(ifaces))
+	for index := range ifaces {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		acc.AddInterface(*ifaces[index])
+	}
+	return nil
+}
+
+// walkAddresses returns the addresses of each interface by ifIndex, from
+// ipAddressTable or, on agents that lack it, the IPv4-only ipAddrTable.
+func walkAddresses(snmp *gosnmp.GoSNMP) (map[int][]netip.Prefix, error) {
+	out := map[int][]netip.Prefix{}
+	ifIndex := map[string]int{}
+	err := walk(snmp, oidIPAddressIf, func(index string, pdu gosnmp.SnmpPDU) {
+		ifIndex[index] = int(snmpInt(pdu))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ifIndex) > 0 {
+		bits := map[string]int{}
+		err := walk(snmp, oidIPAddressPfx, func(index string, pdu gosnmp.SnmpPDU) {
+			// The value points into ipAddressPrefixTable, whose index
+			// ends with the prefix length.
+			if ptr, ok := pdu.Value.(string); ok {
+				if n, err := strconv.Atoi(ptr[strings.LastIndex(ptr, ".")+1:]); err == nil {
+					bits[index] = n
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		for index, n := range ifIndex {
+			addr, ok := inetAddressIndex(index)
+			if !ok {
+				continue
+			}
+			b, ok := bits[index]
+			if !ok || b > addr.BitLen() {
+				b = addr.BitLen()
+			}
+			out[n] = append(out[n], netip.PrefixFrom(addr, b))
+		}
+	} else {
+		masks := map[string]int{}
+		err := walk(snmp, oidIPAdEntNetMask, func(index string, pdu gosnmp.SnmpPDU) {
+			if mask, ok := pdu.Value.(string); ok {
+				if ip := net.ParseIP(mask).To4(); ip != nil {
+					masks[index], _ = net.IPMask(ip).Size()
+				}
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = walk(snmp, oidIPAdEntIfIndex, func(index string, pdu gosnmp.SnmpPDU) {
+			addr, err := netip.ParseAddr(index)
+			if err != nil {
+				return
+			}
+			bits, ok := masks[index]
+			if !ok {
+				bits = 32
+			}
+			n := int(snmpInt(pdu))
+			out[n] = append(out[n], netip.PrefixFrom(addr, bits))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, prefixes := range out {
+		sort.Slice(prefixes, func(i, j int) bool { return prefixes[i].Addr().Less(prefixes[j].Addr()) })
+	}
+	return out, nil
+}
+
+// inetAddressIndex decodes an ipAddressTable index: the InetAddressType
+// followed by the length-prefixed address octets. Zoned addresses are
+// returned without their zone, and link-local addresses are skipped.
+func inetAddressIndex(index string) (netip.Addr, bool) {
+	parts := strings.Split(index, ".")
+	if len(parts) < 2 {
+		return netip.Addr{}, false
+	}
+	octets := make([]byte, 0, len(parts)-2)
+	for _, part := range parts[2:] {
+		n, err := strconv.Atoi(part)
+		if err != nil || n > 255 {
+			return netip.Addr{}, false
+		}
+		octets = append(octets, byte(n))
+	}
+	if n, err := strconv.Atoi(parts[1]); err != nil || n != len(octets) {
+		return netip.Addr{}, false
+	}
+	switch parts[0] {
+	case "1", "3": // ipv4, ipv4z
+		if len(octets) == 8 {
+			octets = octets[:4]
+		}
+	case "2", "4": // ipv6, ipv6z
+		if len(octets) == 20 {
+			octets = octets[:16]
+		}
+	default:
+		return netip.Addr{}, false
+	}
+	addr, ok := netip.AddrFromSlice(octets)
+	if !ok || addr.IsLinkLocalUnicast() {
+		return netip.Addr{}, false
+	}
+	return addr, true
+}
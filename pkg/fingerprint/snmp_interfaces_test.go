@@ -0,0 +1,110 @@
+package fingerprint
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// ifEntries serves two interfaces: a gigabit port and a 10G port whose
+// ifSpeed saturates.
+var ifEntries = []gosnmp.SnmpPDU{
+	octets(oidSysDescr, "Cisco IOS Software"),
+	octets(oidIfDescr+".1", "GigabitEthernet0/1"),
+	octets(oidIfDescr+".2", "TenGigabitEthernet1/1"),
+	integer(oidIfType+".1", 6),
+	integer(oidIfType+".2", 6),
+	integer(oidIfMtu+".1", 1500),
+	integer(oidIfMtu+".2", 9000),
+	gauge(oidIfSpeed+".1", 1_000_000_000),
+	gauge(oidIfSpeed+".2", 4294967295),
+	{Name: oidIfPhysAddress + ".1", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1b, 0x54, 0xaa, 0xbb, 0x01}},
+	{Name: oidIfPhysAddress + ".2", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1b, 0x54, 0xaa, 0xbb, 0x02}},
+	integer(oidIfAdminStatus+".1", 1),
+	integer(oidIfAdminStatus+".2", 2),
+	integer(oidIfOperStatus+".1", 1),
+	integer(oidIfOperStatus+".2", 2),
+	octets(oidIfName+".1", "Gi0/1"),
+	octets(oidIfName+".2", "Te1/1"),
+	gauge(oidIfHighSpeed+".1", 1000),
+	gauge(oidIfHighSpeed+".2", 10000),
+	octets(oidIfAlias+".1", "uplink"),
+}
+
+func TestWalkInterfacesIPAddrTable(t *testing.T) {
+	pdus := append([]gosnmp.SnmpPDU{
+		integer(oidIPAdEntIfIndex+".10.0.0.2", 1),
+		integer(oidIPAdEntIfIndex+".192.168.5.1", 1),
+		{Name: oidIPAdEntNetMask + ".10.0.0.2", Type: gosnmp.IPAddress, Value: "255.255.255.0"},
+		{Name: oidIPAdEntNetMask + ".192.168.5.1", Type: gosnmp.IPAddress, Value: "255.255.255.252"},
+	}, ifEntries...)
+	asset := probeFakeAgent(t, "10.0.0.2", pdus...)
+
+	want := []inventory.NetworkInterface{
+		{
+			Index: 1, Name: "Gi0/1", Description: "GigabitEthernet0/1", Alias: "uplink",
+			Type: 6, MTU: 1500, Speed: 1_000_000_000, AdminStatus: "up", OperStatus: "up",
+			MAC:       "00:1b:54:aa:bb:01",
+			Addresses: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/24"), netip.MustParsePrefix("192.168.5.1/30")},
+		},
+		{
+			Index: 2, Name: "Te1/1", Description: "TenGigabitEthernet1/1",
+			Type: 6, MTU: 9000, Speed: 10_000_000_000, AdminStatus: "down", OperStatus: "down",
+			MAC: "00:1b:54:aa:bb:02",
+		},
+	}
+	if !reflect.DeepEqual(asset.Interfaces, want) {
+		t.Fatalf("interfaces\n got %+v\nwant %+v", asset.Interfaces, want)
+	}
+	if asset.MAC != "00:1b:54:aa:bb:01" {
+		t.Fatalf("MAC not backfilled from the interface holding the host address: %q", asset.MAC)
+	}
+}
+
+func TestWalkInterfacesIPAddressTable(t *testing.T) {
+	prefix := ".1.3.6.1.2.1.4.32.1.5.1."
+	pdus := append([]gosnmp.SnmpPDU{
+		integer(oidIPAddressIf+".1.4.10.0.0.2", 1),
+		integer(oidIPAddressIf+".2.16.32.1.13.184.0.0.0.0.0.0.0.0.0.0.0.1", 2),
+		// Link-local addresses are skipped.
+		integer(oidIPAddressIf+".2.16.254.128.0.0.0.0.0.0.0.0.0.0.0.0.0.1", 2),
+		{Name: oidIPAddressPfx + ".1.4.10.0.0.2", Type: gosnmp.ObjectIdentifier, Value: prefix + "1.4.10.0.0.0.24"},
+		{Name: oidIPAddressPfx + ".2.16.32.1.13.184.0.0.0.0.0.0.0.0.0.0.0.1", Type: gosnmp.ObjectIdentifier, Value: prefix + "2.16.32.1.13.184.0.0.0.0.0.0.0.0.0.0.0.0.64"},
+		// The legacy table is ignored when ipAddressTable answers.
+		integer(oidIPAdEntIfIndex+".172.16.0.1", 1),
+	}, ifEntries...)
+	asset := probeFakeAgent(t, "192.0.2.1", pdus...)
+
+	if len(asset.Interfaces) != 2 {
+		t.Fatalf("interfaces %+v", asset.Interfaces)
+	}
+	if got := asset.Interfaces[0].Addresses; !reflect.DeepEqual(got, []netip.Prefix{netip.MustParsePrefix("10.0.0.2/24")}) {
+		t.Fatalf("interface 1 addresses %v", got)
+	}
+	if got := asset.Interfaces[1].Addresses; !reflect.DeepEqual(got, []netip.Prefix{netip.MustParsePrefix("2001:db8::1/64")}) {
+		t.Fatalf("interface 2 addresses %v", got)
+	}
+	if asset.MAC != "" {
+		t.Fatalf("MAC set from an interface not holding the host address: %q", asset.MAC)
+	}
+}
+
+func TestInetAddressIndex(t *testing.T) {
+	cases := map[string]string{
+		"1.4.192.168.1.10":         "192.168.1.10",
+		"3.8.192.168.1.10.0.0.0.3": "192.168.1.10",
+		"1.4.169.254.0.1":          "",
+		"1.3.10.0.0":               "",
+		"5.4.10.0.0.1":             "",
+		"1.4.10.0.0.256":           "",
+	}
+	for index, want := range cases {
+		addr, ok := inetAddressIndex(index)
+		if (want == "") == ok || (ok && addr.String() != want) {
+			t.Fatalf("inetAddressIndex(%q) = %v, %v; want %q", index, addr, ok, want)
+		}
+	}
+}
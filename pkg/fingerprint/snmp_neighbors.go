@@ -0,0 +1,204 @@
+package fingerprint
+
+import (
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// LLDP-MIB (IEEE 802.1AB) and CISCO-CDP-MIB objects read for the neighbor
+// table.
+const (
+	oidLldpLocPortID       = ".1.0.8802.1.1.2.1.3.7.1.3"
+	oidLldpLocPortDesc     = ".1.0.8802.1.1.2.1.3.7.1.4"
+	oidLldpRemChassisIDSub = ".1.0.8802.1.1.2.1.4.1.1.4"
+	oidLldpRemChassisID    = ".1.0.8802.1.1.2.1.4.1.1.5"
+	oidLldpRemPortIDSub    = ".1.0.8802.1.1.2.1.4.1.1.6"
+	oidLldpRemPortID       = ".1.0.8802.1.1.2.1.4.1.1.7"
+	oidLldpRemPortDesc     = ".1.0.8802.1.1.2.1.4.1.1.8"
+	oidLldpRemSysName      = ".1.0.8802.1.1.2.1.4.1.1.9"
+	oidLldpRemSysDesc      = ".1.0.8802.1.1.2.1.4.1.1.10"
+	oidLldpRemManAddrIf    = ".1.0.8802.1.1.2.1.4.2.1.3"
+
+	oidCdpCacheAddress    = ".1.3.6.1.4.1.9.9.23.1.2.1.1.4"
+	oidCdpCacheDeviceID   = ".1.3.6.1.4.1.9.9.23.1.2.1.1.6"
+	oidCdpCacheDevicePort = ".1.3.6.1.4.1.9.9.23.1.2.1.1.7"
+	oidCdpCachePlatform   = ".1.3.6.1.4.1.9.9.23.1.2.1.1.8"
+)
+
+// LLDP chassis and port ID subtypes whose value is a MAC address.
+const (
+	lldpChassisMAC = 4
+	lldpPortMAC    = 3
+)
+
+// walkNeighbors records the devices the host sees on its ports through
+// LLDP and CDP. It runs after walkInterfaces so that neighbors can be tied
+// to the local interface they were seen on.
+func walkNeighbors(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	if err := walkLLDP(snmp, acc); err != nil {
+		return err
+	}
+	return walkCDP(snmp, acc)
+}
+
+// walkLLDP reads lldpRemTable, indexed by timeMark.localPortNum.remIndex,
+// and the management addresses of each remote system.
+func walkLLDP(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	locPorts := map[string]string{}
+	err := walk(snmp, oidLldpLocPortDesc, func(index string, pdu gosnmp.SnmpPDU) {
+		locPorts[index] = snmpString(pdu)
+	})
+	if err != nil {
+		return err
+	}
+	err = walk(snmp, oidLldpLocPortID, func(index string, pdu gosnmp.SnmpPDU) {
+		if locPorts[index] == "" {
+			locPorts[index] = snmpString(pdu)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	remotes := map[string]*inventory.Neighbor{}
+	chassisSub := map[string]int64{}
+	portSub := map[string]int64{}
+	chassis := map[string][]byte{}
+	ports := map[string][]byte{}
+	get := func(index string) *inventory.Neighbor {
+		if remotes[index] == nil {
+			remotes[index] = &inventory.Neighbor{Protocol: "lldp"}
+		}
+		return remotes[index]
+	}
+	columns := []struct {
+		oid string
+		fn  func(index string, pdu gosnmp.SnmpPDU)
+	}{
+		{oidLldpRemChassisIDSub, func(i string, pdu gosnmp.SnmpPDU) { chassisSub[i] = snmpInt(pdu) }},
+		{oidLldpRemChassisID, func(i string, pdu gosnmp.SnmpPDU) {
+			b, _ := pdu.Value.([]byte)
+			chassis[i] = b
+			get(i)
+		}},
+		{oidLldpRemPortIDSub, func(i string, pdu gosnmp.SnmpPDU) { portSub[i] = snmpInt(pdu) }},
+		{oidLldpRemPortID, func(i string, pdu gosnmp.SnmpPDU) {
+			b, _ := pdu.Value.([]byte)
+			ports[i] = b
+			get(i)
+		}},
+		{oidLldpRemPortDesc, func(i string, pdu gosnmp.SnmpPDU) {
+			// Prefer the port ID; the description is only a fallback.
+			if n := get(i); n.RemotePort == "" {
+				n.RemotePort = snmpString(pdu)
+			}
+		}},
+		{oidLldpRemSysName, func(i string, pdu gosnmp.SnmpPDU) { get(i).RemoteName = snmpString(pdu) }},
+		{oidLldpRemSysDesc, func(i string, pdu gosnmp.SnmpPDU) { get(i).RemoteDescription = snmpString(pdu) }},
+		// The address is part of the index:
+		// timeMark.localPortNum.remIndex.addrSubtype.addrLen.addr.
+		{oidLldpRemManAddrIf, func(i string, pdu gosnmp.SnmpPDU) {
+			parts := strings.SplitN(i, ".", 4)
+			if len(parts) < 4 {
+				return
+			}
+			remote := strings.Join(parts[:3], ".")
+			if n := remotes[remote]; n != nil && !n.RemoteIP.IsValid() {
+				if addr, ok := inetAddressIndex(parts[3]); ok {
+					n.RemoteIP = addr
+				}
+			}
+		}},
+	}
+	for _, c := range columns {
+		if err := walk(snmp, c.oid, c.fn); err != nil {
+			return err
+		}
+	}
+
+	for _, index := range sortedIndexes(remotes) {
+		n := remotes[index]
+		if b := chassis[index]; len(b) > 0 {
+			n.RemoteChassisID = lldpID(b, chassisSub[index] == lldpChassisMAC)
+		}
+		if b := ports[index]; len(b) > 0 {
+			n.RemotePort = lldpID(b, portSub[index] == lldpPortMAC)
+		}
+		parts := strings.Split(index, ".")
+		if len(parts) != 3 {
+			continue
+		}
+		portNum, _ := strconv.Atoi(parts[1])
+		n.LocalPort = locPorts[parts[1]]
+		if iface, ok := acc.localInterface(n.LocalPort, portNum); ok {
+			n.LocalIndex, n.LocalPort = iface.Index, interfaceName(iface)
+		}
+		acc.AddNeighbor(*n)
+	}
+	return nil
+}
+
+// walkCDP reads cdpCacheTable, indexed by ifIndex.deviceIndex.
+func walkCDP(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	cache := map[string]*inventory.Neighbor{}
+	get := func(index string) *inventory.Neighbor {
+		if cache[index] == nil {
+			cache[index] = &inventory.Neighbor{Protocol: "cdp"}
+		}
+		return cache[index]
+	}
+	columns := []struct {
+		oid string
+		fn  func(index string, pdu gosnmp.SnmpPDU)
+	}{
+		{oidCdpCacheDeviceID, func(i string, pdu gosnmp.SnmpPDU) { get(i).RemoteName = snmpString(pdu) }},
+		{oidCdpCacheDevicePort, func(i string, pdu gosnmp.SnmpPDU) { get(i).RemotePort = snmpString(pdu) }},
+		{oidCdpCachePlatform, func(i string, pdu gosnmp.SnmpPDU) { get(i).RemoteDescription = snmpString(pdu) }},
+		{oidCdpCacheAddress, func(i string, pdu gosnmp.SnmpPDU) {
+			if b, ok := pdu.Value.([]byte); ok {
+				if addr, ok := netip.AddrFromSlice(b); ok {
+					get(i).RemoteIP = addr
+				}
+			}
+		}},
+	}
+	for _, c := range columns {
+		if err := walk(snmp, c.oid, c.fn); err != nil {
+			return err
+		}
+	}
+
+	for _, index := range sortedIndexes(cache) {
+		n := cache[index]
+		ifIndex, _, _ := strings.Cut(index, ".")
+		n.LocalIndex, _ = strconv.Atoi(ifIndex)
+		if iface, ok := acc.localInterface("", n.LocalIndex); ok {
+			n.LocalPort = interfaceName(iface)
+		}
+		acc.AddNeighbor(*n)
+	}
+	return nil
+}
+
+// lldpID formats an LLDP chassis or port ID, which is a MAC address when
+// mac is set and text otherwise.
+func lldpID(b []byte, mac bool) string {
+	if mac && len(b) == 6 {
+		return net.HardwareAddr(b).String()
+	}
+	return strings.TrimRight(string(b), "\x00")
+}
+
+// interfaceName returns the short name of an interface, falling back to
+// its description.
+func interfaceName(iface inventory.NetworkInterface) string {
+	if iface.Name != "" {
+		return iface.Name
+	}
+	return iface.Description
+}
@@ -0,0 +1,54 @@
+package fingerprint
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+func TestWalkNeighbors(t *testing.T) {
+	pdus := append([]gosnmp.SnmpPDU{
+		// LLDP: local port 1 is described like ifIndex 1; local port 7 is
+		// only known by number, which matches ifIndex 2 by description.
+		octets(oidLldpLocPortDesc+".1", "GigabitEthernet0/1"),
+		octets(oidLldpLocPortID+".7", "TenGigabitEthernet1/1"),
+		integer(oidLldpRemChassisIDSub+".0.1.3", lldpChassisMAC),
+		{Name: oidLldpRemChassisID + ".0.1.3", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1b, 0x54, 0x10, 0x20, 0x30}},
+		integer(oidLldpRemPortIDSub+".0.1.3", 5),
+		octets(oidLldpRemPortID+".0.1.3", "Gi1/0/24"),
+		octets(oidLldpRemPortDesc+".0.1.3", "uplink to access"),
+		octets(oidLldpRemSysName+".0.1.3", "core-sw1.example.com"),
+		octets(oidLldpRemSysDesc+".0.1.3", "Cisco IOS Software, C9300"),
+		integer(oidLldpRemManAddrIf+".0.1.3.1.4.10.0.0.1", 2),
+		integer(oidLldpRemChassisIDSub+".0.7.1", 7),
+		octets(oidLldpRemChassisID+".0.7.1", "ap-01"),
+		integer(oidLldpRemPortIDSub+".0.7.1", lldpPortMAC),
+		{Name: oidLldpRemPortID + ".0.7.1", Type: gosnmp.OctetString, Value: []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}},
+		// CDP on ifIndex 1.
+		{Name: oidCdpCacheAddress + ".1.4", Type: gosnmp.OctetString, Value: []byte{10, 0, 0, 1}},
+		octets(oidCdpCacheDeviceID+".1.4", "core-sw1"),
+		octets(oidCdpCacheDevicePort+".1.4", "GigabitEthernet1/0/24"),
+		octets(oidCdpCachePlatform+".1.4", "cisco C9300-48P"),
+	}, ifEntries...)
+	asset := probeFakeAgent(t, "192.0.2.50", pdus...)
+
+	want := []inventory.Neighbor{
+		{
+			Protocol: "lldp", LocalIndex: 1, LocalPort: "Gi0/1",
+			RemoteName: "core-sw1.example.com", RemotePort: "Gi1/0/24", RemoteChassisID: "00:1b:54:10:20:30",
+			RemoteIP: netip.MustParseAddr("10.0.0.1"), RemoteDescription: "Cisco IOS Software, C9300",
+		},
+		{Protocol: "lldp", LocalIndex: 2, LocalPort: "Te1/1", RemotePort: "00:11:22:33:44:55", RemoteChassisID: "ap-01"},
+		{
+			Protocol: "cdp", LocalIndex: 1, LocalPort: "Gi0/1",
+			RemoteName: "core-sw1", RemotePort: "GigabitEthernet1/0/24",
+			RemoteIP: netip.MustParseAddr("10.0.0.1"), RemoteDescription: "cisco C9300-48P",
+		},
+	}
+	if !reflect.DeepEqual(asset.Neighbors, want) {
+		t.Fatalf("neighbors\n got %+v\nwant %+v", asset.Neighbors, want)
+	}
+}
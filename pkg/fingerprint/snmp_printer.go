@@ -0,0 +1,266 @@
+package fingerprint
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// Printer-MIB (RFC 3805) and HOST-RESOURCES-MIB printer objects.
+const (
+	oidPrtSerialNumber      = ".1.3.6.1.2.1.43.5.1.1.17.1"
+	oidPrtMarkerLifeCount   = ".1.3.6.1.2.1.43.10.2.1.4"
+	oidPrtInputMaxCapacity  = ".1.3.6.1.2.1.43.8.2.1.9"
+	oidPrtInputCurrentLevel = ".1.3.6.1.2.1.43.8.2.1.10"
+	oidPrtInputMediaName    = ".1.3.6.1.2.1.43.8.2.1.12"
+	oidPrtInputName         = ".1.3.6.1.2.1.43.8.2.1.13"
+	oidPrtSuppliesColorant  = ".1.3.6.1.2.1.43.11.1.1.3"
+	oidPrtSuppliesType      = ".1.3.6.1.2.1.43.11.1.1.5"
+	oidPrtSuppliesDescr     = ".1.3.6.1.2.1.43.11.1.1.6"
+	oidPrtSuppliesMax       = ".1.3.6.1.2.1.43.11.1.1.8"
+	oidPrtSuppliesLevel     = ".1.3.6.1.2.1.43.11.1.1.9"
+	oidPrtColorantValue     = ".1.3.6.1.2.1.43.12.1.1.4"
+	oidHrPrinterStatus      = ".1.3.6.1.2.1.25.3.5.1.1"
+	oidHrPrinterErrorState  = ".1.3.6.1.2.1.25.3.5.1.2"
+)
+
+// supplyTypeNames maps PrtMarkerSuppliesTypeTC values to their names.
+var supplyTypeNames = map[int64]string{
+	1: "other", 2: "unknown", 3: "toner", 4: "wasteToner", 5: "ink",
+	6: "inkCartridge", 7: "inkRibbon", 8: "wasteInk", 9: "opc",
+	10: "developer", 11: "fuserOil", 12: "solidWax", 13: "ribbonWax",
+	14: "wasteWax", 15: "fuser", 16: "coronaWire", 17: "fuserOilWick",
+	18: "cleanerUnit", 19: "fuserCleaningPad", 20: "transferUnit",
+	21: "tonerCartridge", 22: "fuserOiler", 23: "water", 24: "wasteWater",
+	26: "wastePaper", 32: "staples",
+}
+
+// printerStatusNames maps hrPrinterStatus values to their names.
+var printerStatusNames = map[int64]string{1: "other", 2: "unknown", 3: "idle", 4: "printing", 5: "warmup"}
+
+// printerErrorNames names the bits of hrPrinterDetectedErrorState, most
+// significant bit of the first octet first.
+var printerErrorNames = []string{
+	"lowPaper", "noPaper", "lowToner", "noToner", "doorOpen", "jammed",
+	"offline", "serviceRequested", "inputTrayMissing", "outputTrayMissing",
+	"markerSupplyMissing", "outputNearFull", "outputFull", "inputTrayEmpty",
+	"overduePreventMaint",
+}
+
+// vendorCounter reads page counters from a vendor MIB when the agent's
+// sysObjectID is under the vendor's enterprise number.
+type vendorCounter struct {
+	enterprise string
+	read       func(*gosnmp.GoSNMP, *inventory.PageCounters) error
+}
+
+var vendorCounters = []vendorCounter{
+	{".1.3.6.1.4.1.11.", readHPCounters},
+	{".1.3.6.1.4.1.367.", readRicohCounters},
+}
+
+// walkPrinter records the supplies, page counters, paper trays and error
+// state of a printer. Agents without the Printer-MIB are left alone.
+func walkPrinter(snmp *gosnmp.GoSNMP, acc *Accumulator) error {
+	var info inventory.PrinterInfo
+	supplies := map[string]*inventory.PrinterSupply{}
+	colorants := map[string]string{}
+	supplyColorant := map[string]string{}
+	supply := func(index string) *inventory.PrinterSupply {
+		if supplies[index] == nil {
+			supplies[index] = &inventory.PrinterSupply{Level: -2}
+		}
+		return supplies[index]
+	}
+	trays := map[string]*inventory.PaperTray{}
+	tray := func(index string) *inventory.PaperTray {
+		if trays[index] == nil {
+			trays[index] = &inventory.PaperTray{Level: -2}
+		}
+		return trays[index]
+	}
+
+	columns := []struct {
+		oid string
+		fn  func(index string, pdu gosnmp.SnmpPDU)
+	}{
+		{oidPrtSuppliesType, func(i string, pdu gosnmp.SnmpPDU) { supply(i).Type = supplyTypeNames[snmpInt(pdu)] }},
+		{oidPrtSuppliesDescr, func(i string, pdu gosnmp.SnmpPDU) { supply(i).Description = snmpString(pdu) }},
+		{oidPrtSuppliesMax, func(i string, pdu gosnmp.SnmpPDU) { supply(i).MaxCapacity = int(snmpInt(pdu)) }},
+		{oidPrtSuppliesLevel, func(i string, pdu gosnmp.SnmpPDU) { supply(i).Level = int(snmpInt(pdu)) }},
+		{oidPrtSuppliesColorant, func(i string, pdu gosnmp.SnmpPDU) {
+			// The colorant index is relative to the same hrDeviceIndex.
+			if dev, _, ok := strings.Cut(i, "."); ok {
+				supplyColorant[i] = dev + "." + strconv.FormatInt(snmpInt(pdu), 10)
+			}
+		}},
+		{oidPrtColorantValue, func(i string, pdu gosnmp.SnmpPDU) { colorants[i] = strings.ToLower(snmpString(pdu)) }},
+		{oidPrtInputName, func(i string, pdu gosnmp.SnmpPDU) { tray(i).Name = snmpString(pdu) }},
+		{oidPrtInputMediaName, func(i string, pdu gosnmp.SnmpPDU) { tray(i).MediaName = snmpString(pdu) }},
+		{oidPrtInputMaxCapacity, func(i string, pdu gosnmp.SnmpPDU) { tray(i).Capacity = int(snmpInt(pdu)) }},
+		{oidPrtInputCurrentLevel, func(i string, pdu gosnmp.SnmpPDU) { tray(i).Level = int(snmpInt(pdu)) }},
+		// Only the first marker is counted; printers with several
+		// markers report the same impressions on each.
+		{oidPrtMarkerLifeCount, func(i string, pdu gosnmp.SnmpPDU) {
+			if info.Counters.Total == 0 {
+				info.Counters.Total = int(snmpInt(pdu))
+			}
+		}},
+		{oidHrPrinterStatus, func(i string, pdu gosnmp.SnmpPDU) { info.Status = printerStatusNames[snmpInt(pdu)] }},
+		{oidHrPrinterErrorState, func(i string, pdu gosnmp.SnmpPDU) {
+			b, _ := pdu.Value.([]byte)
+			for bit, name := range printerErrorNames {
+				if bit/8 < len(b) && b[bit/8]&(0x80>>(bit%8)) != 0 {
+					info.Errors = append(info.Errors, name)
+				}
+			}
+		}},
+	}
+	for _, c := range columns {
+		if err := walk(snmp, c.oid, c.fn); err != nil {
+			return err
+		}
+	}
+	if len(supplies) == 0 && info.Counters.Total == 0 {
+		return nil
+	}
+
+	for _, index := range sortedIndexes(supplies) {
+		s := supplies[index]
+		s.Color = colorants[supplyColorant[index]]
+		info.Supplies = append(info.Supplies, *s)
+	}
+	for _, index := range sortedIndexes(trays) {
+		info.Trays = append(info.Trays, *trays[index])
+	}
+
+	if id := acc.sysObjectID(); id != "" {
+		id = dotted(id)
+		for _, v := range vendorCounters {
+			if strings.HasPrefix(id, v.enterprise) {
+				if err := v.read(snmp, &info.Counters); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := getSerial(snmp, acc, oidPrtSerialNumber); err != nil {
+		return err
+	}
+	acc.SetPrinter(info)
+	return nil
+}
+
+// getSerial records the serial number held by the scalar oid.
+func getSerial(snmp *gosnmp.GoSNMP, acc *Accumulator, oid string) error {
+	result, err := snmp.Get([]string{oid})
+	if err != nil {
+		return err
+	}
+	for _, pdu := range result.Variables {
+		if pdu.Type == gosnmp.OctetString {
+			acc.SetSerial(snmpString(pdu))
+		}
+	}
+	return nil
+}
+
+// HP LaserJet page counters (HP-LASERJET-COMMON-MIB).
+const (
+	oidHPTotalPages  = ".1.3.6.1.4.1.11.2.3.9.4.2.1.4.1.2.5.0"
+	oidHPMonoPages   = ".1.3.6.1.4.1.11.2.3.9.4.2.1.4.1.2.6.0"
+	oidHPColorPages  = ".1.3.6.1.4.1.11.2.3.9.4.2.1.4.1.2.7.0"
+	oidHPDuplexPages = ".1.3.6.1.4.1.11.2.3.9.4.2.1.4.1.2.22.0"
+)
+
+func readHPCounters(snmp *gosnmp.GoSNMP, c *inventory.PageCounters) error {
+	result, err := snmp.Get([]string{oidHPTotalPages, oidHPMonoPages, oidHPColorPages, oidHPDuplexPages})
+	if err != nil {
+		return err
+	}
+	for _, pdu := range result.Variables {
+		n := int(snmpInt(pdu))
+		if n == 0 {
+			continue
+		}
+		switch pdu.Name {
+		case oidHPTotalPages:
+			c.Total = n
+		case oidHPMonoPages:
+			c.Mono = n
+		case oidHPColorPages:
+			c.Color = n
+		case oidHPDuplexPages:
+			c.Duplex = n
+		}
+	}
+	return nil
+}
+
+// Ricoh engine counters: a table of named counters (RICOH-PRIVATE-MIB
+// ricohEngCounterTable).
+const (
+	oidRicohCounterName  = ".1.3.6.1.4.1.367.3.2.1.2.19.5.1.5"
+	oidRicohCounterValue = ".1.3.6.1.4.1.367.3.2.1.2.19.5.1.9"
+)
+
+func readRicohCounters(snmp *gosnmp.GoSNMP, c *inventory.PageCounters) error {
+	names := map[string]string{}
+	err := walk(snmp, oidRicohCounterName, func(index string, pdu gosnmp.SnmpPDU) {
+		names[index] = strings.ToLower(snmpString(pdu))
+	})
+	if err != nil || len(names) == 0 {
+		return err
+	}
+	var mono, color int
+	err = walk(snmp, oidRicohCounterValue, func(index string, pdu gosnmp.SnmpPDU) {
+		n := int(snmpInt(pdu))
+		switch name := names[index]; {
+		case name == "total":
+			c.Total = n
+		case strings.Contains(name, "duplex"):
+			c.Duplex += n
+		case strings.Contains(name, "black & white"), strings.Contains(name, "b&w"):
+			mono += n
+		case strings.Contains(name, "color"):
+			color += n
+		}
+	})
+	if mono > 0 {
+		c.Mono = mono
+	}
+	if color > 0 {
+		c.Color = color
+	}
+	return err
+}
+
+// sortedIndexes returns the keys of a table keyed by OID index, in OID
+// order.
+func sortedIndexes[T any](table map[string]T) []string {
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return indexLess(keys[i], keys[j]) })
+	return keys
+}
+
+// indexLess orders OID indexes numerically, sub-identifier by
+// sub-identifier.
+func indexLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] != bs[i] {
+			if len(as[i]) != len(bs[i]) {
+				return len(as[i]) < len(bs[i])
+			}
+			return as[i] < bs[i]
+		}
+	}
+	return len(as) < len(bs)
+}
@@ -0,0 +1,71 @@
+package fingerprint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+func TestWalkPrinter(t *testing.T) {
+	asset := probeFakeAgent(t, "192.0.2.30",
+		octets(oidSysDescr, "HP ETHERNET MULTI-ENVIRONMENT"),
+		gosnmp.SnmpPDU{Name: oidSysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.11.2.3.9.1"},
+		octets(oidPrtSerialNumber, "CNB1234567"),
+		integer(oidPrtMarkerLifeCount+".1.1", 15230),
+		integer(oidPrtSuppliesType+".1.1", 3),
+		integer(oidPrtSuppliesType+".1.2", 9),
+		integer(oidPrtSuppliesType+".1.3", 4),
+		octets(oidPrtSuppliesDescr+".1.1", "Black Cartridge HP CF259A"),
+		octets(oidPrtSuppliesDescr+".1.2", "Imaging Drum"),
+		octets(oidPrtSuppliesDescr+".1.3", "Waste Toner"),
+		integer(oidPrtSuppliesColorant+".1.1", 1),
+		integer(oidPrtSuppliesColorant+".1.2", 1),
+		integer(oidPrtSuppliesColorant+".1.3", 0),
+		integer(oidPrtSuppliesMax+".1.1", 3000),
+		integer(oidPrtSuppliesMax+".1.2", 100),
+		integer(oidPrtSuppliesMax+".1.3", -2),
+		integer(oidPrtSuppliesLevel+".1.1", 750),
+		integer(oidPrtSuppliesLevel+".1.2", 80),
+		integer(oidPrtSuppliesLevel+".1.3", -3),
+		octets(oidPrtColorantValue+".1.1", "Black"),
+		octets(oidPrtInputName+".1.1", "Tray 1"),
+		octets(oidPrtInputMediaName+".1.1", "A4"),
+		integer(oidPrtInputMaxCapacity+".1.1", 250),
+		integer(oidPrtInputCurrentLevel+".1.1", -3),
+		integer(oidHrPrinterStatus+".1", 3),
+		gosnmp.SnmpPDU{Name: oidHrPrinterErrorState + ".1", Type: gosnmp.OctetString, Value: []byte{0x24, 0x00}},
+		gauge(oidHPTotalPages, 15230),
+		gauge(oidHPMonoPages, 15230),
+		gauge(oidHPDuplexPages, 4100),
+	)
+
+	if asset.Serial != "CNB1234567" {
+		t.Fatalf("serial %q", asset.Serial)
+	}
+	want := &inventory.PrinterInfo{
+		Supplies: []inventory.PrinterSupply{
+			{Description: "Black Cartridge HP CF259A", Type: "toner", Color: "black", Level: 750, MaxCapacity: 3000},
+			{Description: "Imaging Drum", Type: "opc", Color: "black", Level: 80, MaxCapacity: 100},
+			{Description: "Waste Toner", Type: "wasteToner", Level: -3, MaxCapacity: -2},
+		},
+		Counters: inventory.PageCounters{Total: 15230, Mono: 15230, Duplex: 4100},
+		Trays:    []inventory.PaperTray{{Name: "Tray 1", MediaName: "A4", Capacity: 250, Level: -3}},
+		Status:   "idle",
+		Errors:   []string{"lowToner", "jammed"},
+	}
+	if !reflect.DeepEqual(asset.Printer, want) {
+		t.Fatalf("printer\n got %+v\nwant %+v", asset.Printer, want)
+	}
+	if level, ok := want.Supplies[0].Percent(); !ok || level != 25 {
+		t.Fatalf("toner percent %d %v", level, ok)
+	}
+}
+
+func TestWalkPrinterSkipsOtherDevices(t *testing.T) {
+	asset := probeFakeAgent(t, "192.0.2.31", octets(oidSysDescr, "Linux web01"))
+	if asset.Printer != nil {
+		t.Fatalf("printer info on a host without the Printer-MIB: %+v", asset.Printer)
+	}
+}
@@ -0,0 +1,429 @@
+package fingerprint
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/nmasdoufi/goscanner/pkg/config"
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+	"github.com/nmasdoufi/goscanner/pkg/service"
+)
+
+// fakeAgent is an SNMPv2c agent on the loopback interface serving a fixed
+// MIB view to one community.
+type fakeAgent struct {
+	community string
+	oids      []string
+	values    map[string]gosnmp.SnmpPDU
+}
+
+func newFakeAgent(t *testing.T, community string, pdus ...gosnmp.SnmpPDU) uint16 {
+	t.Helper()
+	a := &fakeAgent{community: community, values: map[string]gosnmp.SnmpPDU{}}
+	for _, pdu := range pdus {
+		a.values[pdu.Name] = pdu
+		a.oids = append(a.oids, pdu.Name)
+	}
+	sort.Slice(a.oids, func(i, j int) bool { return oidLess(a.oids[i], a.oids[j]) })
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go a.serve(conn)
+	return uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func (a *fakeAgent) serve(conn net.PacketConn) {
+	buf := make([]byte, 65535)
+	decoder := &gosnmp.GoSNMP{}
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil || req.Version != gosnmp.Version2c || req.Community != a.community {
+			continue
+		}
+		resp := &gosnmp.SnmpPacket{
+			Version:   gosnmp.Version2c,
+			Community: a.community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: req.RequestID,
+			Variables: a.answer(req),
+		}
+		out, err := resp.MarshalMsg()
+		if err != nil {
+			continue
+		}
+		conn.WriteTo(out, addr)
+	}
+}
+
+// fakeV3Agent is an SNMPv3-only agent serving the MIB view of agent to one
+// noAuthNoPriv user. It drops SNMPv1 and v2c requests.
+type fakeV3Agent struct {
+	*fakeAgent
+	user     string
+	engineID string
+}
+
+// USM report counters (RFC 3414).
+const (
+	oidUnknownUserNames = ".1.3.6.1.6.3.15.1.1.3.0"
+	oidUnknownEngineIDs = ".1.3.6.1.6.3.15.1.1.4.0"
+)
+
+func newFakeV3Agent(t *testing.T, user string, pdus ...gosnmp.SnmpPDU) uint16 {
+	t.Helper()
+	a := &fakeV3Agent{fakeAgent: &fakeAgent{values: map[string]gosnmp.SnmpPDU{}}, user: user, engineID: "\x80\x00\x1f\x88\x04fake-v3"}
+	for _, pdu := range pdus {
+		a.values[pdu.Name] = pdu
+		a.oids = append(a.oids, pdu.Name)
+	}
+	sort.Slice(a.oids, func(i, j int) bool { return oidLess(a.oids[i], a.oids[j]) })
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go a.serve(conn)
+	return uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func (a *fakeV3Agent) serve(conn net.PacketConn) {
+	buf := make([]byte, 65535)
+	// The decoder needs a user to accept SNMPv3 messages; each request's
+	// own security parameters replace it.
+	decoder := &gosnmp.GoSNMP{
+		Version:            gosnmp.Version3,
+		SecurityModel:      gosnmp.UserSecurityModel,
+		MsgFlags:           gosnmp.NoAuthNoPriv,
+		SecurityParameters: &gosnmp.UsmSecurityParameters{UserName: a.user},
+	}
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil || req.Version != gosnmp.Version3 {
+			continue
+		}
+		usm := req.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+		resp := &gosnmp.SnmpPacket{
+			Version:         gosnmp.Version3,
+			MsgFlags:        gosnmp.NoAuthNoPriv,
+			SecurityModel:   gosnmp.UserSecurityModel,
+			MsgID:           req.MsgID,
+			MsgMaxSize:      65507,
+			RequestID:       req.RequestID,
+			ContextEngineID: a.engineID,
+			SecurityParameters: &gosnmp.UsmSecurityParameters{
+				AuthoritativeEngineID:    a.engineID,
+				AuthoritativeEngineBoots: 1,
+				AuthoritativeEngineTime:  100,
+				UserName:                 usm.UserName,
+			},
+		}
+		switch {
+		case usm.AuthoritativeEngineID != a.engineID:
+			resp.PDUType = gosnmp.Report
+			resp.Variables = []gosnmp.SnmpPDU{{Name: oidUnknownEngineIDs, Type: gosnmp.Counter32, Value: uint32(1)}}
+		case usm.UserName != a.user:
+			resp.PDUType = gosnmp.Report
+			resp.Variables = []gosnmp.SnmpPDU{{Name: oidUnknownUserNames, Type: gosnmp.Counter32, Value: uint32(1)}}
+		default:
+			resp.PDUType = gosnmp.GetResponse
+			resp.Variables = a.answer(req)
+		}
+		out, err := resp.MarshalMsg()
+		if err != nil {
+			continue
+		}
+		conn.WriteTo(out, addr)
+	}
+}
+
+func (a *fakeAgent) answer(req *gosnmp.SnmpPacket) []gosnmp.SnmpPDU {
+	var out []gosnmp.SnmpPDU
+	switch req.PDUType {
+	case gosnmp.GetRequest:
+		for _, v := range req.Variables {
+			pdu, ok := a.values[v.Name]
+			if !ok {
+				pdu = gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchObject}
+			}
+			out = append(out, pdu)
+		}
+	case gosnmp.GetNextRequest:
+		for _, v := range req.Variables {
+			out = append(out, a.next(v.Name))
+		}
+	case gosnmp.GetBulkRequest:
+		// Non-repeaters are not used by walks.
+		name := req.Variables[0].Name
+		for i := 0; i < int(req.MaxRepetitions); i++ {
+			pdu := a.next(name)
+			out = append(out, pdu)
+			if pdu.Type == gosnmp.EndOfMibView {
+				break
+			}
+			name = pdu.Name
+		}
+	}
+	return out
+}
+
+func (a *fakeAgent) next(name string) gosnmp.SnmpPDU {
+	i := sort.Search(len(a.oids), func(i int) bool { return oidLess(name, a.oids[i]) })
+	if i == len(a.oids) {
+		return gosnmp.SnmpPDU{Name: name, Type: gosnmp.EndOfMibView}
+	}
+	return a.values[a.oids[i]]
+}
+
+func oidLess(a, b string) bool {
+	as := strings.Split(strings.TrimPrefix(a, "."), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "."), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		if x != y {
+			return x < y
+		}
+	}
+	return len(as) < len(bs)
+}
+
+func octets(oid, value string) gosnmp.SnmpPDU {
+	return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.OctetString, Value: []byte(value)}
+}
+
+func integer(oid string, value int) gosnmp.SnmpPDU {
+	return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Integer, Value: value}
+}
+
+func gauge(oid string, value uint) gosnmp.SnmpPDU {
+	return gosnmp.SnmpPDU{Name: oid, Type: gosnmp.Gauge32, Value: value}
+}
+
+// probeFakeAgent runs the SNMP prober against an agent serving pdus for
+// the host ip and returns the asset it built.
+func probeFakeAgent(t *testing.T, ip string, pdus ...gosnmp.SnmpPDU) inventory.AssetModel {
+	t.Helper()
+	port := newFakeAgent(t, "public", pdus...)
+	p := &snmpProber{creds: []config.Credential{{Name: "public", Community: "public"}}, port: port, timeout: time.Second}
+	asset := inventory.AssetModel{IP: netip.MustParseAddr(ip), Attributes: map[string]string{}}
+	// The agent listens on the loopback interface whatever the asset's
+	// address.
+	host := discovery.HostResult{IP: netip.MustParseAddr("127.0.0.1")}
+	if err := p.Probe(context.Background(), host, &Accumulator{state: &hostState{asset: &asset, facts: &facts{}}, prober: "snmp"}); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	return asset
+}
+
+func TestSNMPClientSecurity(t *testing.T) {
+	cases := []struct {
+		cred  config.Credential
+		flags gosnmp.SnmpV3MsgFlags
+		auth  gosnmp.SnmpV3AuthProtocol
+		priv  gosnmp.SnmpV3PrivProtocol
+	}{
+		{config.Credential{Username: "ro"}, gosnmp.NoAuthNoPriv, gosnmp.NoAuth, gosnmp.NoPriv},
+		{config.Credential{Username: "ro", AuthProtocol: "md5", AuthPassword: "a"}, gosnmp.AuthNoPriv, gosnmp.MD5, gosnmp.NoPriv},
+		{config.Credential{Username: "ro", AuthProtocol: "SHA256", AuthPassword: "a", PrivProtocol: "aes", PrivPassword: "p"}, gosnmp.AuthPriv, gosnmp.SHA256, gosnmp.AES},
+	}
+	for _, tc := range cases {
+		snmp := snmpAttempt{tc.cred, gosnmp.Version3}.client(context.Background(), "192.0.2.1", 161, time.Second)
+		usm := snmp.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+		if snmp.MsgFlags != tc.flags || usm.AuthenticationProtocol != tc.auth || usm.PrivacyProtocol != tc.priv || usm.UserName != "ro" {
+			t.Fatalf("%+v: flags %v auth %v priv %v", tc.cred, snmp.MsgFlags, usm.AuthenticationProtocol, usm.PrivacyProtocol)
+		}
+	}
+}
+
+func TestSNMPAttemptsPreferCache(t *testing.T) {
+	cache, err := OpenSNMPCache(filepath.Join(t.TempDir(), "snmp.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &snmpProber{creds: []config.Credential{{Name: "core", Username: "ro", Community: "secret"}}, cache: cache}
+	got := p.attempts("192.0.2.1", "")
+	if len(got) != 2 || got[0].versionName() != "3" || got[1].versionName() != "2c" {
+		t.Fatalf("attempts %v", got)
+	}
+	cache.remember("192.0.2.1", "core", "2c")
+	if got := p.attempts("192.0.2.1", ""); got[0].versionName() != "2c" {
+		t.Fatalf("cached attempt not first: %v", got)
+	}
+}
+
+func TestSNMPProberFallsBackToV2c(t *testing.T) {
+	port := newFakeAgent(t, "secret",
+		octets(oidSysDescr, "Cisco IOS Software, Catalyst L2 Switch"),
+		octets(oidSysName, "core-sw1"),
+		gosnmp.SnmpPDU{Name: oidSysObjectID, Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.9.1.1208"})
+
+	path := filepath.Join(t.TempDir(), "snmp.json")
+	cache, err := OpenSNMPCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred := config.Credential{Name: "core", Type: "snmp", Username: "ro", AuthProtocol: "SHA", AuthPassword: "authpass123", Community: "secret"}
+	p := &snmpProber{creds: []config.Credential{cred}, cache: cache, port: port, timeout: 100 * time.Millisecond}
+
+	asset := inventory.AssetModel{Attributes: map[string]string{}}
+	f := &facts{}
+	host := discovery.HostResult{IP: netip.MustParseAddr("127.0.0.1")}
+	if err := p.Probe(context.Background(), host, &Accumulator{state: &hostState{asset: &asset, facts: f}, prober: "snmp"}); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if f.sysName != "core-sw1" || f.sysObjectID != ".1.3.6.1.4.1.9.1.1208" || asset.Attributes["snmp_version"] != "2c" {
+		t.Fatalf("facts %+v attributes %v", f, asset.Attributes)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenSNMPCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, version, ok := reopened.lookup("127.0.0.1"); !ok || name != "core" || version != "2c" {
+		t.Fatalf("cache entry %q %q %v", name, version, ok)
+	}
+}
+
+func TestSNMPProberTriesCredentialsInOrder(t *testing.T) {
+	port := newFakeAgent(t, "secret", octets(oidSysDescr, "HP ETHERNET MULTI-ENVIRONMENT"))
+	cache, err := OpenSNMPCache(filepath.Join(t.TempDir(), "snmp.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &snmpProber{
+		creds: []config.Credential{
+			{Name: "branch", Community: "secret", Sites: []string{"branch"}},
+			{Name: "public", Community: "public"},
+			{Name: "hq", Community: "secret", Sites: []string{"hq"}},
+		},
+		cache:   cache,
+		port:    port,
+		timeout: 100 * time.Millisecond,
+	}
+	if got := p.attempts("127.0.0.1", "hq"); len(got) != 2 || got[0].cred.Name != "public" || got[1].cred.Name != "hq" {
+		t.Fatalf("attempts %v", got)
+	}
+
+	asset := inventory.AssetModel{Attributes: map[string]string{}}
+	host := discovery.HostResult{IP: netip.MustParseAddr("127.0.0.1"), Site: "hq"}
+	if err := p.Probe(context.Background(), host, &Accumulator{state: &hostState{asset: &asset, facts: &facts{}}, prober: "snmp"}); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if asset.Attributes["snmp_credential"] != "hq" {
+		t.Fatalf("attributes %v", asset.Attributes)
+	}
+	if got := p.attempts("127.0.0.1", "hq"); got[0].cred.Name != "hq" {
+		t.Fatalf("known-good credential not first: %v", got)
+	}
+
+	host.Site = "lab"
+	err = p.Probe(context.Background(), host, &Accumulator{state: &hostState{asset: &asset, facts: &facts{}}, prober: "snmp"})
+	if err == nil || !strings.Contains(err.Error(), "credential public") {
+		t.Fatalf("expected failure with the unscoped credential only, got %v", err)
+	}
+}
+
+func TestSNMPProberBudget(t *testing.T) {
+	port := newFakeAgent(t, "fourth", octets(oidSysDescr, "HP ETHERNET MULTI-ENVIRONMENT"))
+	p := &snmpProber{
+		creds: []config.Credential{
+			{Name: "v3", Username: "ro", Community: "first"},
+			{Name: "second", Community: "second"},
+			{Name: "third", Community: "third"},
+			{Name: "fourth", Community: "fourth"},
+		},
+		port:    port,
+		timeout: 100 * time.Millisecond,
+	}
+	// SNMPv3 needs engine discovery and the request, each sent twice; every
+	// SNMPv2c attempt its request twice.
+	if got, want := p.budget(), 12*100*time.Millisecond+snmpCollectTimeout; got != want {
+		t.Fatalf("budget %s, want %s", got, want)
+	}
+
+	// Every credential is tried within the budget, leaving the walks theirs.
+	ctx, cancel := context.WithTimeout(context.Background(), p.budget()-snmpCollectTimeout)
+	defer cancel()
+	asset := inventory.AssetModel{Attributes: map[string]string{}}
+	host := discovery.HostResult{IP: netip.MustParseAddr("127.0.0.1")}
+	if err := p.Probe(ctx, host, &Accumulator{state: &hostState{asset: &asset, facts: &facts{}}, prober: "snmp"}); err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if asset.Attributes["snmp_credential"] != "fourth" {
+		t.Fatalf("attributes %v", asset.Attributes)
+	}
+}
+
+func TestSNMPProberRunsOnSilentPort(t *testing.T) {
+	// The agent ignored discovery's probe, which carried another
+	// community, so udp/161 stayed silent.
+	port := newFakeAgent(t, "private", octets(oidSysDescr, "HP ETHERNET MULTI-ENVIRONMENT"))
+	host := discovery.HostResult{IP: netip.MustParseAddr("127.0.0.1"), Alive: true, UDPSilent: map[int]bool{int(port): true}}
+	if applies(&stubProber{port: UDP(int(port))}, host) {
+		t.Fatal("prober without credentials applies to a silent port")
+	}
+	p := &snmpProber{creds: []config.Credential{{Name: "private", Community: "private"}}, port: port, timeout: 100 * time.Millisecond}
+	e := &Engine{probers: []registeredProber{{p, 0}}}
+	asset := inventory.AssetModel{Attributes: map[string]string{}}
+	e.runProbers(context.Background(), host, &hostState{asset: &asset, facts: &facts{}})
+	if len(asset.Probes) != 1 || asset.Probes[0].Error != "" || asset.Attributes["snmp_credential"] != "private" {
+		t.Fatalf("probes %+v attributes %v", asset.Probes, asset.Attributes)
+	}
+}
+
+func TestSNMPv3OnlyAgentDiscoveredAndFingerprinted(t *testing.T) {
+	port := newFakeV3Agent(t, "monitor",
+		octets(oidSysDescr, "Juniper Networks, Inc. ex2300-24t"),
+		octets(oidSysName, "access-sw3"))
+	payload, _ := service.UDPPayload(161)
+	scanner := discovery.NewScanner(config.Profile{Protocols: []string{"udp"}, UDPPorts: []int{int(port)}, TimeoutMS: 500}, nil,
+		discovery.WithUDPPayloads(map[int][]byte{int(port): payload}))
+	targets, err := scanner.CIDRTargets("127.0.0.1/32")
+	if err != nil {
+		t.Fatalf("CIDRTargets: %v", err)
+	}
+	var hosts []discovery.HostResult
+	for host := range scanner.Stream(context.Background(), targets) {
+		hosts = append(hosts, host)
+	}
+	if len(hosts) != 1 || !hosts[0].Alive || !hasPort(hosts[0].UDPPorts, int(port)) {
+		t.Fatalf("v3 agent not discovered: %+v", hosts)
+	}
+
+	cred := config.Credential{Name: "v3", Type: "snmp", SNMPVersion: "3", Username: "monitor"}
+	p := &snmpProber{creds: []config.Credential{cred}, port: port, timeout: 200 * time.Millisecond}
+	e := &Engine{probers: []registeredProber{{p, 0}}}
+	asset := inventory.AssetModel{Attributes: map[string]string{}}
+	f := &facts{}
+	e.runProbers(context.Background(), hosts[0], &hostState{asset: &asset, facts: f})
+	if len(asset.Probes) != 1 || asset.Probes[0].Error != "" {
+		t.Fatalf("probes %+v", asset.Probes)
+	}
+	if f.sysName != "access-sw3" || asset.Attributes["snmp_version"] != "3" || asset.Attributes["snmp_credential"] != "v3" {
+		t.Fatalf("facts %+v attributes %v", f, asset.Attributes)
+	}
+}
@@ -0,0 +1,99 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// SNMPCache remembers, per host, the SNMP credential and version that last
+// worked, so that later scans try them first. It is safe for concurrent
+// use; a nil cache remembers nothing.
+type SNMPCache struct {
+	path  string
+	mu    sync.Mutex
+	hosts map[string]snmpCacheEntry
+	dirty bool
+}
+
+type snmpCacheEntry struct {
+	Credential string    `json:"credential"`
+	Version    string    `json:"version"`
+	Updated    time.Time `json:"updated"`
+}
+
+// OpenSNMPCache loads the cache stored at path. A missing file yields an
+// empty cache.
+func OpenSNMPCache(path string) (*SNMPCache, error) {
+	c := &SNMPCache{path: path, hosts: map[string]snmpCacheEntry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snmp cache: %w", err)
+	}
+	var file struct {
+		Hosts map[string]snmpCacheEntry `json:"hosts"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse snmp cache %s: %w", path, err)
+	}
+	if file.Hosts != nil {
+		c.hosts = file.Hosts
+	}
+	return c, nil
+}
+
+// lookup returns the credential name and version that last worked for ip.
+func (c *SNMPCache) lookup(ip string) (credential, version string, ok bool) {
+	if c == nil {
+		return "", "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.hosts[ip]
+	return entry.Credential, entry.Version, ok
+}
+
+// remember records that credential and version worked for ip.
+func (c *SNMPCache) remember(ip, credential, version string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hosts[ip] = snmpCacheEntry{Credential: credential, Version: version, Updated: time.Now().UTC()}
+	c.dirty = true
+}
+
+// Save writes the cache back to its file if it changed.
+func (c *SNMPCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(struct {
+		Hosts map[string]snmpCacheEntry `json:"hosts"`
+	}{c.hosts}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write snmp cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("write snmp cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,325 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshProberTimeout bounds an SSH probe, which may list thousands of
+// installed packages.
+const sshProberTimeout = 30 * time.Second
+
+// sshBannerWeight is the weight of OS hints read from an SSH banner: the
+// distributions patch the banner themselves, but it can be changed.
+const sshBannerWeight = 60
+
+// sshProber reads the SSH server banner and host key of a host and, when an
+// SSH credential is configured for the host's site, logs in to collect its
+// inventory.
+type sshProber struct {
+	creds   []config.Credential
+	port    int
+	timeout time.Duration
+	verbose bool
+}
+
+func (p *sshProber) Name() string { return "ssh" }
+
+func (p *sshProber) Ports() []Port { return []Port{TCP(p.port)} }
+
+func (p *sshProber) Probe(ctx context.Context, host discovery.HostResult, acc *Accumulator) error {
+	addr := net.JoinHostPort(host.IP.String(), strconv.Itoa(p.port))
+	var creds []*config.Credential
+	var errs []error
+	for i := range p.creds {
+		cred := &p.creds[i]
+		if !cred.AppliesToSite(host.Site) {
+			continue
+		}
+		// A rogue server would be handed the password, so it only goes to
+		// hosts whose key is checked, or on explicit request.
+		if cred.PrivateKey == "" && !sshPasswordAllowed(cred) {
+			errs = append(errs, fmt.Errorf("credential %s: password not sent without known_hosts or insecure_ignore_host_key", cred.Name))
+			continue
+		}
+		creds = append(creds, cred)
+	}
+	// Without a credential the handshake still yields the banner and the
+	// host key before authentication fails.
+	if len(creds) == 0 {
+		creds = []*config.Credential{nil}
+	}
+
+	recorded := false
+	for _, cred := range creds {
+		client, server, err := p.dial(ctx, addr, cred)
+		if !recorded && server.banner != "" {
+			recordSSHServer(acc, server)
+			recorded = true
+		}
+		if cred == nil {
+			if err != nil && !recorded {
+				return errors.Join(append(errs, err)...)
+			}
+			// Some embedded servers let anyone in without authentication.
+			if client != nil {
+				client.Close()
+			}
+			return errors.Join(errs...)
+		}
+		if err != nil {
+			if p.verbose {
+				fmt.Printf("[SSH] Login to %s as %s (credential %s) failed: %v\n", addr, cred.Username, cred.Name, err)
+			}
+			errs = append(errs, fmt.Errorf("credential %s: %w", cred.Name, err))
+			continue
+		}
+		defer client.Close()
+		if p.verbose {
+			fmt.Printf("[SSH] Logged in to %s as %s (credential %s)\n", addr, cred.Username, cred.Name)
+			if cred.KnownHosts == "" {
+				fmt.Printf("[SSH] Warning: host key of %s not verified (credential %s has no known_hosts)\n", addr, cred.Name)
+			}
+		}
+		acc.SetAttribute("ssh_credential", cred.Name)
+		stop := context.AfterFunc(ctx, func() { client.Close() })
+		defer stop()
+		collectSSHInventory(client, acc, p.verbose)
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// sshServer is what the handshake reveals about a server.
+type sshServer struct {
+	banner  string
+	hostKey ssh.PublicKey
+}
+
+// dial connects to addr and authenticates with cred, or with no method at
+// all when cred is nil. The server's banner and host key are returned even
+// when authentication fails.
+func (p *sshProber) dial(ctx context.Context, addr string, cred *config.Credential) (*ssh.Client, sshServer, error) {
+	var server sshServer
+	cfg := &ssh.ClientConfig{
+		User:    "goscanner",
+		Timeout: p.timeout,
+	}
+	var verify ssh.HostKeyCallback
+	if cred != nil {
+		cfg.User = cred.Username
+		auth, err := sshAuth(cred)
+		if err != nil {
+			return nil, server, err
+		}
+		cfg.Auth = auth
+		if cred.KnownHosts != "" {
+			if verify, err = knownhosts.New(cred.KnownHosts); err != nil {
+				return nil, server, err
+			}
+		}
+	}
+	cfg.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		server.hostKey = key
+		if verify != nil {
+			return verify(hostname, remote, key)
+		}
+		return nil
+	}
+
+	d := net.Dialer{Timeout: p.timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, server, err
+	}
+	deadline := time.Now().Add(p.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	bc := &bannerConn{Conn: conn}
+	c, chans, reqs, err := ssh.NewClientConn(bc, addr, cfg)
+	server.banner = bc.banner()
+	if err != nil {
+		conn.Close()
+		return nil, server, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), server, nil
+}
+
+// sshAuth returns the authentication methods of an SSH credential: its
+// private key, then its password when sshPasswordAllowed.
+func sshAuth(cred *config.Credential) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	if cred.PrivateKey != "" {
+		pem, err := os.ReadFile(cred.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		var signer ssh.Signer
+		if cred.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(cred.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("private key %s: %w", cred.PrivateKey, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if cred.Password != "" && sshPasswordAllowed(cred) {
+		methods = append(methods, ssh.Password(cred.Password))
+	}
+	return methods, nil
+}
+
+// sshPasswordAllowed reports whether the password of cred may be sent: the
+// server's host key is checked against known_hosts, or the credential
+// opts out of the check.
+func sshPasswordAllowed(cred *config.Credential) bool {
+	return cred.KnownHosts != "" || cred.InsecureIgnoreHostKey
+}
+
+// bannerConn records what the server sends up to and including its
+// identification line, which the SSH library does not expose when the
+// handshake fails.
+type bannerConn struct {
+	net.Conn
+	mu   sync.Mutex
+	buf  []byte
+	done bool
+}
+
+func (c *bannerConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.done {
+		c.buf = append(c.buf, b[:n]...)
+		_, found := identificationLine(c.buf)
+		c.done = found || len(c.buf) > 4096
+	}
+	return n, err
+}
+
+// banner returns the server's identification line, such as
+// "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6".
+func (c *bannerConn) banner() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	line, _ := identificationLine(c.buf)
+	return line
+}
+
+// identificationLine returns the first complete line of buf starting with
+// "SSH-". Servers may send other lines before it.
+func identificationLine(buf []byte) (string, bool) {
+	for {
+		line, rest, ok := bytes.Cut(buf, []byte("\n"))
+		if !ok {
+			return "", false
+		}
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			return string(bytes.TrimRight(line, "\r")), true
+		}
+		buf = rest
+	}
+}
+
+// recordSSHServer records the banner and host key of an SSH server, and the
+// hints its banner gives.
+func recordSSHServer(acc *Accumulator, server sshServer) {
+	acc.SetAttribute("ssh_banner", server.banner)
+	acc.AddBanner(server.banner)
+	if server.hostKey != nil {
+		// The same key on several addresses means the same host.
+		acc.SetAttribute("ssh_host_key", server.hostKey.Type()+" "+ssh.FingerprintSHA256(server.hostKey))
+	}
+	hint := sshBannerHint(server.banner)
+	detail := fmt.Sprintf("SSH banner %q", server.banner)
+	acc.AddEvidence(inventory.FieldOSName, hint.osName, sshBannerWeight, detail)
+	acc.AddEvidence(inventory.FieldOSVersion, hint.osVersion, sshBannerWeight, detail)
+	acc.AddEvidence(inventory.FieldVendor, hint.vendor, sshBannerWeight, detail)
+	acc.AddEvidence(inventory.FieldType, hint.deviceType, sshBannerWeight, detail)
+}
+
+// sshHint is what an SSH banner tells about the host.
+type sshHint struct {
+	osName, osVersion, vendor, deviceType string
+}
+
+// OpenSSH releases shipped by each Ubuntu and Debian release, for banners
+// that carry no release number of their own.
+var (
+	ubuntuOpenSSH = map[string]string{
+		"6.6.1p1": "14.04", "7.2p2": "16.04", "7.6p1": "18.04", "8.2p1": "20.04",
+		"8.9p1": "22.04", "9.6p1": "24.04",
+	}
+	debianOpenSSH = map[string]string{
+		"6.7p1": "8", "7.4p1": "9", "7.9p1": "10", "8.4p1": "11", "9.2p1": "12",
+	}
+)
+
+var (
+	openSSHVersion = regexp.MustCompile(`^OpenSSH_([0-9.]+p[0-9]+)`)
+	debianRelease  = regexp.MustCompile(`\+deb([0-9]+)u`)
+)
+
+// sshBannerHint derives OS and device hints from the software version and
+// comments of an SSH identification line. OS names follow the NAME field
+// of os-release, so that they agree with an authenticated inventory.
+func sshBannerHint(banner string) sshHint {
+	// SSH-protoversion-softwareversion SP comments
+	parts := strings.SplitN(banner, "-", 3)
+	if len(parts) < 3 {
+		return sshHint{}
+	}
+	software, comments, _ := strings.Cut(parts[2], " ")
+	var openssh string
+	if m := openSSHVersion.FindStringSubmatch(software); m != nil {
+		openssh = m[1]
+	}
+	switch {
+	case strings.HasPrefix(software, "OpenSSH_for_Windows"):
+		return sshHint{osName: "Windows", deviceType: "Computer"}
+	case strings.Contains(comments, "Ubuntu"):
+		return sshHint{osName: "Ubuntu", osVersion: ubuntuOpenSSH[openssh], deviceType: "Computer"}
+	case strings.Contains(comments, "Raspbian"):
+		return sshHint{osName: "Raspbian GNU/Linux", deviceType: "Computer"}
+	case strings.Contains(comments, "Debian"):
+		version := debianOpenSSH[openssh]
+		if m := debianRelease.FindStringSubmatch(comments); m != nil {
+			version = m[1]
+		}
+		return sshHint{osName: "Debian GNU/Linux", osVersion: version, deviceType: "Computer"}
+	case strings.HasPrefix(comments, "FreeBSD"):
+		return sshHint{osName: "FreeBSD", deviceType: "Computer"}
+	case strings.HasPrefix(software, "Sun_SSH"):
+		return sshHint{osName: "Solaris", deviceType: "Computer"}
+	case strings.HasPrefix(software, "Cisco-"):
+		return sshHint{vendor: "Cisco", deviceType: "NetworkEquipment"}
+	case strings.HasPrefix(software, "HUAWEI-"):
+		return sshHint{vendor: "Huawei", deviceType: "NetworkEquipment"}
+	case strings.HasPrefix(software, "ROSSSH"):
+		return sshHint{osName: "RouterOS", vendor: "MikroTik", deviceType: "NetworkEquipment"}
+	case strings.HasPrefix(strings.ToLower(software), "dropbear"):
+		return sshHint{osName: "Linux"}
+	}
+	return sshHint{}
+}
@@ -0,0 +1,191 @@
+package fingerprint
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+	"golang.org/x/crypto/ssh"
+)
+
+// Commands run over SSH for the authenticated inventory. dmidecode usually
+// needs root; the DMI files in sysfs are the fallback.
+const (
+	sshCmdHostname     = "hostname"
+	sshCmdOSRelease    = "cat /etc/os-release"
+	sshCmdUname        = "uname -srm"
+	sshCmdSerial       = "dmidecode -s system-serial-number 2>/dev/null || cat /sys/class/dmi/id/product_serial"
+	sshCmdManufacturer = "dmidecode -s system-manufacturer 2>/dev/null || cat /sys/class/dmi/id/sys_vendor"
+	sshCmdProduct      = "dmidecode -s system-product-name 2>/dev/null || cat /sys/class/dmi/id/product_name"
+	sshCmdLinks        = "ip -o link show"
+	sshCmdAddresses    = "ip -o addr show"
+	sshCmdPackages     = `dpkg-query -W -f '${Package}\t${Version}\n' 2>/dev/null || rpm -qa --qf '%{NAME}\t%{VERSION}-%{RELEASE}\n'`
+)
+
+// sshLoginWeight is the weight of what a host reports about itself once
+// logged in.
+const sshLoginWeight = 95
+
+// collectSSHInventory runs the inventory commands on a host logged in over
+// SSH. A command that fails, for lack of privileges or because the host
+// does not have it, leaves its part of the inventory empty.
+func collectSSHInventory(client *ssh.Client, acc *Accumulator, verbose bool) {
+	run := func(cmd string) string {
+		session, err := client.NewSession()
+		if err != nil {
+			return ""
+		}
+		defer session.Close()
+		out, err := session.Output(cmd)
+		if err != nil {
+			if verbose {
+				fmt.Printf("[SSH] %q failed: %v\n", cmd, err)
+			}
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	if name := run(sshCmdHostname); name != "" {
+		acc.SetHostname(name)
+		acc.SetAttribute("ssh_hostname", name)
+	}
+	detail := "logged in over SSH"
+	osRelease := parseOSRelease(run(sshCmdOSRelease))
+	if osRelease["NAME"] != "" {
+		acc.AddEvidence(inventory.FieldOSName, osRelease["NAME"], sshLoginWeight, detail+": /etc/os-release")
+		acc.AddEvidence(inventory.FieldOSVersion, osRelease["VERSION_ID"], sshLoginWeight, detail+": /etc/os-release")
+		acc.AddEvidence(inventory.FieldType, "Computer", sshBannerWeight, detail+": /etc/os-release")
+		if pretty := osRelease["PRETTY_NAME"]; pretty != "" {
+			acc.SetAttribute("os_pretty_name", pretty)
+		}
+	}
+	if fields := strings.Fields(run(sshCmdUname)); len(fields) == 3 {
+		acc.SetKernel(fields[0]+" "+fields[1], fields[2])
+		if osRelease["NAME"] == "" {
+			acc.AddEvidence(inventory.FieldOSName, fields[0], sshLoginWeight, detail+": uname")
+		}
+	}
+	acc.SetSerial(dmiValue(run(sshCmdSerial)))
+	acc.AddEvidence(inventory.FieldVendor, dmiValue(run(sshCmdManufacturer)), sshLoginWeight, detail+": DMI")
+	acc.AddEvidence(inventory.FieldModel, dmiValue(run(sshCmdProduct)), sshLoginWeight, detail+": DMI")
+
+	for _, iface := range parseIPLinks(run(sshCmdLinks), run(sshCmdAddresses)) {
+		acc.AddInterface(iface)
+	}
+	acc.SetSoftware(parsePackages(run(sshCmdPackages)))
+}
+
+// parseOSRelease parses the KEY=value lines of os-release(5).
+func parseOSRelease(out string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `'"`)
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// dmiValue returns a DMI string, or "" for the placeholders vendors leave
+// in unset fields.
+func dmiValue(s string) string {
+	switch strings.ToLower(s) {
+	case "", "none", "not specified", "to be filled by o.e.m.", "default string", "system serial number", "0":
+		return ""
+	}
+	return s
+}
+
+// linkStates maps the operational states printed by ip(8) to IF-MIB
+// status names.
+var linkStates = map[string]string{
+	"UP":             "up",
+	"DOWN":           "down",
+	"DORMANT":        "dormant",
+	"NOTPRESENT":     "notPresent",
+	"LOWERLAYERDOWN": "lowerLayerDown",
+}
+
+// parseIPLinks builds the interface list from the one-line output of
+// "ip link show" and "ip addr show". Link-local addresses are skipped, as
+// over SNMP.
+func parseIPLinks(links, addrs string) []inventory.NetworkInterface {
+	var ifaces []inventory.NetworkInterface
+	byName := map[string]int{}
+	for _, line := range strings.Split(links, "\n") {
+		// 2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 ... state UP ...\    link/ether 52:54:00:12:34:56 brd ...
+		fields := strings.Fields(strings.ReplaceAll(line, `\`, " "))
+		if len(fields) < 3 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":"))
+		if err != nil {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimSuffix(fields[1], ":"), "@")
+		iface := inventory.NetworkInterface{Index: index, Name: name, Type: 1, AdminStatus: "down", OperStatus: "unknown"}
+		for _, flag := range strings.Split(strings.Trim(fields[2], "<>"), ",") {
+			if flag == "UP" {
+				iface.AdminStatus = "up"
+			}
+		}
+		for i := 3; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "mtu":
+				iface.MTU, _ = strconv.Atoi(fields[i+1])
+			case "state":
+				if state, ok := linkStates[fields[i+1]]; ok {
+					iface.OperStatus = state
+				}
+			case "link/ether":
+				iface.Type = 6
+				iface.MAC = strings.ToLower(fields[i+1])
+			case "link/loopback":
+				iface.Type = 24
+			}
+		}
+		byName[name] = len(ifaces)
+		ifaces = append(ifaces, iface)
+	}
+	for _, line := range strings.Split(addrs, "\n") {
+		// 2: eth0    inet 10.0.0.5/24 brd 10.0.0.255 scope global eth0\       valid_lft forever ...
+		fields := strings.Fields(line)
+		if len(fields) < 4 || (fields[2] != "inet" && fields[2] != "inet6") {
+			continue
+		}
+		i, ok := byName[fields[1]]
+		if !ok {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(fields[3])
+		if err != nil || prefix.Addr().IsLinkLocalUnicast() {
+			continue
+		}
+		ifaces[i].Addresses = append(ifaces[i].Addresses, prefix)
+	}
+	return ifaces
+}
+
+// parsePackages parses "name<TAB>version" lines, sorted by name.
+func parsePackages(out string) []inventory.Software {
+	var software []inventory.Software
+	for _, line := range strings.Split(out, "\n") {
+		name, version, _ := strings.Cut(strings.TrimSpace(line), "\t")
+		if name != "" {
+			software = append(software, inventory.Software{Name: name, Version: version})
+		}
+	}
+	sort.Slice(software, func(i, j int) bool { return software[i].Name < software[j].Name })
+	return software
+}
@@ -0,0 +1,297 @@
+package fingerprint
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nmasdoufi/goscanner/pkg/config"
+	"github.com/nmasdoufi/goscanner/pkg/discovery"
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const fakeSSHBanner = "SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6"
+
+// fakeSSHServer is an in-process SSH server that lets the user "inventory"
+// in with password or authorized, and answers exec requests from commands.
+type fakeSSHServer struct {
+	port    int
+	hostKey ssh.PublicKey
+}
+
+func newFakeSSHServer(t *testing.T, password string, authorized ssh.PublicKey, commands map[string]string) *fakeSSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		ServerVersion: fakeSSHBanner,
+		PasswordCallback: func(c ssh.ConnMetadata, pw []byte) (*ssh.Permissions, error) {
+			if password != "" && c.User() == "inventory" && string(pw) == password {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized != nil && c.User() == "inventory" && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("denied")
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSH(conn, cfg, commands)
+		}
+	}()
+	return &fakeSSHServer{port: ln.Addr().(*net.TCPAddr).Port, hostKey: signer.PublicKey()}
+}
+
+func serveSSH(conn net.Conn, cfg *ssh.ServerConfig, commands map[string]string) {
+	defer conn.Close()
+	sc, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer ch.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				var exec struct{ Command string }
+				ssh.Unmarshal(req.Payload, &exec)
+				req.Reply(true, nil)
+				status := struct{ Status uint32 }{127}
+				if out, ok := commands[exec.Command]; ok {
+					ch.Write([]byte(out))
+					status.Status = 0
+				}
+				ch.SendRequest("exit-status", false, ssh.Marshal(status))
+				return
+			}
+		}()
+	}
+}
+
+// probeSSH runs the SSH prober with creds against the server and returns
+// the asset it built for ip.
+func probeSSH(t *testing.T, server *fakeSSHServer, ip string, creds ...config.Credential) (inventory.AssetModel, error) {
+	t.Helper()
+	p := &sshProber{creds: creds, port: server.port, timeout: time.Second}
+	asset := inventory.AssetModel{IP: netip.MustParseAddr(ip), Attributes: map[string]string{}}
+	host := discovery.HostResult{IP: netip.MustParseAddr("127.0.0.1")}
+	err := p.Probe(context.Background(), host, &Accumulator{state: &hostState{asset: &asset, facts: &facts{}}, prober: "ssh"})
+	return asset, err
+}
+
+func evidenceFor(asset inventory.AssetModel, field string) []string {
+	var values []string
+	for _, ev := range asset.Evidence {
+		if ev.Field == field {
+			values = append(values, ev.Value)
+		}
+	}
+	return values
+}
+
+func TestSSHBannerHint(t *testing.T) {
+	tests := []struct {
+		banner string
+		want   sshHint
+	}{
+		{"SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6", sshHint{osName: "Ubuntu", osVersion: "22.04", deviceType: "Computer"}},
+		{"SSH-2.0-OpenSSH_9.2p1 Debian-2+deb12u3", sshHint{osName: "Debian GNU/Linux", osVersion: "12", deviceType: "Computer"}},
+		{"SSH-2.0-OpenSSH_8.4p1 Debian-5", sshHint{osName: "Debian GNU/Linux", osVersion: "11", deviceType: "Computer"}},
+		{"SSH-2.0-OpenSSH_for_Windows_8.1", sshHint{osName: "Windows", deviceType: "Computer"}},
+		{"SSH-2.0-OpenSSH_9.3 FreeBSD-20230316", sshHint{osName: "FreeBSD", deviceType: "Computer"}},
+		{"SSH-2.0-Cisco-1.25", sshHint{vendor: "Cisco", deviceType: "NetworkEquipment"}},
+		{"SSH-2.0-ROSSSH", sshHint{osName: "RouterOS", vendor: "MikroTik", deviceType: "NetworkEquipment"}},
+		{"SSH-2.0-dropbear_2020.81", sshHint{osName: "Linux"}},
+		{"SSH-2.0-OpenSSH_8.7", sshHint{}},
+	}
+	for _, tt := range tests {
+		if got := sshBannerHint(tt.banner); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.banner, got, tt.want)
+		}
+	}
+}
+
+func TestSSHProberBanner(t *testing.T) {
+	server := newFakeSSHServer(t, "", nil, nil)
+	asset, err := probeSSH(t, server, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if asset.Attributes["ssh_banner"] != fakeSSHBanner {
+		t.Fatalf("banner %q", asset.Attributes["ssh_banner"])
+	}
+	if want := "ssh-ed25519 " + ssh.FingerprintSHA256(server.hostKey); asset.Attributes["ssh_host_key"] != want {
+		t.Fatalf("host key %q, want %q", asset.Attributes["ssh_host_key"], want)
+	}
+	if got := evidenceFor(asset, inventory.FieldOSName); !reflect.DeepEqual(got, []string{"Ubuntu"}) {
+		t.Fatalf("os_name evidence %v", got)
+	}
+	if got := evidenceFor(asset, inventory.FieldOSVersion); !reflect.DeepEqual(got, []string{"22.04"}) {
+		t.Fatalf("os_version evidence %v", got)
+	}
+}
+
+var sshCommands = map[string]string{
+	sshCmdHostname:     "web01\n",
+	sshCmdOSRelease:    "PRETTY_NAME=\"Ubuntu 22.04.3 LTS\"\nNAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nID=ubuntu\n",
+	sshCmdUname:        "Linux 5.15.0-91-generic x86_64\n",
+	sshCmdSerial:       "VMware-56 4d 12 34\n",
+	sshCmdManufacturer: "VMware, Inc.\n",
+	sshCmdProduct:      "VMware Virtual Platform\n",
+	sshCmdLinks: "1: lo: <LOOPBACK,UP,LOWER_UP> mtu 65536 qdisc noqueue state UNKNOWN mode DEFAULT group default qlen 1000\\    link/loopback 00:00:00:00:00:00 brd 00:00:00:00:00:00\n" +
+		"2: ens160: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc mq state UP mode DEFAULT group default qlen 1000\\    link/ether 00:50:56:AB:CD:EF brd ff:ff:ff:ff:ff:ff\n" +
+		"3: docker0: <NO-CARRIER,BROADCAST,MULTICAST> mtu 1500 qdisc noqueue state DOWN mode DEFAULT group default \\    link/ether 02:42:ac:11:00:01 brd ff:ff:ff:ff:ff:ff\n",
+	sshCmdAddresses: "1: lo    inet 127.0.0.1/8 scope host lo\\       valid_lft forever preferred_lft forever\n" +
+		"2: ens160    inet 10.0.0.5/24 brd 10.0.0.255 scope global ens160\\       valid_lft forever preferred_lft forever\n" +
+		"2: ens160    inet6 fe80::250:56ff:feab:cdef/64 scope link \\       valid_lft forever preferred_lft forever\n",
+	sshCmdPackages: "openssh-server\t1:8.9p1-3ubuntu0.6\nbash\t5.1-6ubuntu1\n",
+}
+
+func TestSSHProberInventory(t *testing.T) {
+	server := newFakeSSHServer(t, "s3cret", nil, sshCommands)
+	asset, err := probeSSH(t, server, "10.0.0.5",
+		config.Credential{Name: "wrong", Type: "ssh", Username: "inventory", Password: "nope", InsecureIgnoreHostKey: true},
+		config.Credential{Name: "linux", Type: "ssh", Username: "inventory", Password: "s3cret", InsecureIgnoreHostKey: true},
+	)
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if asset.Attributes["ssh_credential"] != "linux" || asset.Hostname != "web01" {
+		t.Fatalf("credential %q hostname %q", asset.Attributes["ssh_credential"], asset.Hostname)
+	}
+	if asset.Kernel != "Linux 5.15.0-91-generic" || asset.Arch != "x86_64" || asset.Serial != "VMware-56 4d 12 34" {
+		t.Fatalf("kernel %q arch %q serial %q", asset.Kernel, asset.Arch, asset.Serial)
+	}
+	if got := evidenceFor(asset, inventory.FieldModel); !reflect.DeepEqual(got, []string{"VMware Virtual Platform"}) {
+		t.Fatalf("model evidence %v", got)
+	}
+	wantIfaces := []inventory.NetworkInterface{
+		{Index: 1, Name: "lo", Type: 24, MTU: 65536, AdminStatus: "up", OperStatus: "unknown", Addresses: []netip.Prefix{netip.MustParsePrefix("127.0.0.1/8")}},
+		{Index: 2, Name: "ens160", Type: 6, MTU: 1500, AdminStatus: "up", OperStatus: "up", MAC: "00:50:56:ab:cd:ef", Addresses: []netip.Prefix{netip.MustParsePrefix("10.0.0.5/24")}},
+		{Index: 3, Name: "docker0", Type: 6, MTU: 1500, AdminStatus: "down", OperStatus: "down", MAC: "02:42:ac:11:00:01"},
+	}
+	if !reflect.DeepEqual(asset.Interfaces, wantIfaces) {
+		t.Fatalf("interfaces\n got %+v\nwant %+v", asset.Interfaces, wantIfaces)
+	}
+	if asset.MAC != "00:50:56:ab:cd:ef" {
+		t.Fatalf("MAC %q", asset.MAC)
+	}
+	wantSoftware := []inventory.Software{{Name: "bash", Version: "5.1-6ubuntu1"}, {Name: "openssh-server", Version: "1:8.9p1-3ubuntu0.6"}}
+	if asset.Host == nil || !reflect.DeepEqual(asset.Host.Software, wantSoftware) {
+		t.Fatalf("software %+v", asset.Host)
+	}
+}
+
+func TestSSHProberPasswordNeedsHostKey(t *testing.T) {
+	server := newFakeSSHServer(t, "s3cret", nil, sshCommands)
+	cred := config.Credential{Name: "linux", Type: "ssh", Username: "inventory", Password: "s3cret"}
+	asset, err := probeSSH(t, server, "10.0.0.5", cred)
+	if err == nil || asset.Attributes["ssh_credential"] != "" || asset.Hostname != "" {
+		t.Fatalf("password sent to unverified host: credential %q hostname %q, err %v", asset.Attributes["ssh_credential"], asset.Hostname, err)
+	}
+	// The banner is still read without logging in.
+	if asset.Attributes["ssh_banner"] != fakeSSHBanner {
+		t.Fatalf("banner %q", asset.Attributes["ssh_banner"])
+	}
+
+	// A host listed in known_hosts gets the password.
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(server.port))
+	if err := os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{addr}, server.hostKey)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cred.KnownHosts = knownHosts
+	if asset, err = probeSSH(t, server, "10.0.0.5", cred); err != nil || asset.Hostname != "web01" {
+		t.Fatalf("hostname %q, err %v", asset.Hostname, err)
+	}
+}
+
+func TestSSHProberPrivateKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeSSHServer(t, "", signer.PublicKey(), sshCommands)
+
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(server.port))
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{addr}, server.hostKey)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cred := config.Credential{Name: "key", Type: "ssh", Username: "inventory", PrivateKey: keyPath, KnownHosts: knownHosts}
+
+	asset, err := probeSSH(t, server, "10.0.0.5", cred)
+	if err != nil || asset.Hostname != "web01" {
+		t.Fatalf("hostname %q, err %v", asset.Hostname, err)
+	}
+
+	// A host whose key is not listed is not logged in to.
+	other := newFakeSSHServer(t, "", signer.PublicKey(), sshCommands)
+	asset, err = probeSSH(t, other, "10.0.0.5", cred)
+	if err == nil || asset.Hostname != "" {
+		t.Fatalf("logged in to unknown host: hostname %q, err %v", asset.Hostname, err)
+	}
+	if asset.Attributes["ssh_banner"] != fakeSSHBanner {
+		t.Fatalf("banner %q", asset.Attributes["ssh_banner"])
+	}
+}
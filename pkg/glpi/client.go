@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -28,9 +30,16 @@ type GLPIInventory struct {
 type GLPIInventoryContent struct {
 	VersionClient    string                  `json:"versionclient"`
 	Hardware         *GLPIHardware           `json:"hardware,omitempty"`
+	Bios             *GLPIBios               `json:"bios,omitempty"`
 	OperatingSystem  *GLPIOperatingSystem    `json:"operatingsystem,omitempty"`
 	Networks         []GLPINetwork           `json:"networks,omitempty"`
 	NetworkDevice    *GLPINetworkDevice      `json:"network_device,omitempty"`
+	NetworkPorts     []GLPINetworkPort       `json:"network_ports,omitempty"`
+	NetworkComponents []GLPINetworkComponent `json:"network_components,omitempty"`
+	CPUs             []GLPICPU               `json:"cpus,omitempty"`
+	Drives           []GLPIDrive             `json:"drives,omitempty"`
+	Softwares        []GLPISoftware          `json:"softwares,omitempty"`
+	Processes        []GLPIProcess           `json:"processes,omitempty"`
 	Printers         []GLPIPrinter           `json:"printers,omitempty"`
 }
 
@@ -41,6 +50,55 @@ type GLPIHardware struct {
 	ChassisType  string `json:"chassis_type,omitempty"`
 	Workgroup    string `json:"workgroup,omitempty"`
 	Description  string `json:"description,omitempty"`
+	// Memory is the physical memory in MB
+	Memory       int    `json:"memory,omitempty"`
+}
+
+// GLPIBios represents the BIOS section, which carries a computer's serial
+type GLPIBios struct {
+	SSN string `json:"ssn,omitempty"`
+}
+
+// GLPICPU represents a processor
+type GLPICPU struct {
+	Name string `json:"name,omitempty"`
+}
+
+// GLPIDrive represents a file system; sizes are in MB
+type GLPIDrive struct {
+	Volumn string `json:"volumn,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Total  uint64 `json:"total,omitempty"`
+	Free   uint64 `json:"free,omitempty"`
+}
+
+// GLPISoftware represents an installed package
+type GLPISoftware struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	InstallDate string `json:"install_date,omitempty"`
+}
+
+// GLPIProcess represents a running process
+type GLPIProcess struct {
+	Cmd string `json:"cmd"`
+	PID int    `json:"pid"`
+}
+
+// GLPINetworkComponent represents a physical component of network
+// equipment, such as a chassis, stack member or module
+type GLPINetworkComponent struct {
+	Index          int    `json:"index"`
+	ContainedIndex int    `json:"contained_index,omitempty"`
+	Type           string `json:"type,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Description    string `json:"description,omitempty"`
+	Manufacturer   string `json:"manufacturer,omitempty"`
+	Model          string `json:"model,omitempty"`
+	Serial         string `json:"serial,omitempty"`
+	Revision       string `json:"revision,omitempty"`
+	Firmware       string `json:"firmware,omitempty"`
+	Version        string `json:"version,omitempty"`
 }
 
 // GLPIOperatingSystem represents OS info
@@ -55,6 +113,7 @@ type GLPIOperatingSystem struct {
 type GLPINetwork struct {
 	Description string   `json:"description,omitempty"`
 	IPAddress   string   `json:"ipaddress,omitempty"`
+	IPMask      string   `json:"ipmask,omitempty"`
 	IPAddress6  string   `json:"ipaddress6,omitempty"`
 	MacAddr     string   `json:"macaddr,omitempty"`
 	Status      string   `json:"status,omitempty"`
@@ -62,6 +121,56 @@ type GLPINetwork struct {
 	Speed       int      `json:"speed,omitempty"`
 }
 
+// GLPINetworkPort represents a port of network equipment or a printer,
+// as read from its interface table
+type GLPINetworkPort struct {
+	IfNumber         int      `json:"ifnumber"`
+	IfName           string   `json:"ifname,omitempty"`
+	IfDescr          string   `json:"ifdescr,omitempty"`
+	IfAlias          string   `json:"ifalias,omitempty"`
+	IfType           string   `json:"iftype,omitempty"`
+	IfMTU            int      `json:"ifmtu,omitempty"`
+	IfSpeed          uint64   `json:"ifspeed,omitempty"`
+	IfStatus         string   `json:"ifstatus,omitempty"`
+	IfInternalStatus string   `json:"ifinternalstatus,omitempty"`
+	MAC              string   `json:"mac,omitempty"`
+	IPs              []string `json:"ips,omitempty"`
+	// LLDP and CDP are set when the connections were learned by that
+	// protocol
+	LLDP             bool     `json:"lldp,omitempty"`
+	CDP              bool     `json:"cdp,omitempty"`
+	Connections      []GLPIPortConnection `json:"connections,omitempty"`
+	Vlans            []GLPIVlan `json:"vlans,omitempty"`
+}
+
+// GLPIVlan is a VLAN seen on a network port
+type GLPIVlan struct {
+	Number int `json:"number"`
+}
+
+// GLPIPortConnection is a device seen on a network port by LLDP or CDP
+type GLPIPortConnection struct {
+	SysName  string `json:"sysname,omitempty"`
+	SysDescr string `json:"sysdescr,omitempty"`
+	SysMAC   string `json:"sysmac,omitempty"`
+	IfDescr  string `json:"ifdescr,omitempty"`
+	IP       string `json:"ip,omitempty"`
+	// MAC is set on connections learned from the forwarding table
+	MAC      string `json:"mac,omitempty"`
+}
+
+// ifStatusCodes maps IF-MIB status names back to their numeric values,
+// which GLPI expects in ifstatus and ifinternalstatus
+var ifStatusCodes = map[string]string{
+	"up":             "1",
+	"down":           "2",
+	"testing":        "3",
+	"unknown":        "4",
+	"dormant":        "5",
+	"notPresent":     "6",
+	"lowerLayerDown": "7",
+}
+
 // GLPINetworkDevice represents network equipment
 type GLPINetworkDevice struct {
 	Type     string `json:"type,omitempty"`
@@ -78,6 +187,18 @@ type GLPIPrinter struct {
 	Port       string `json:"port,omitempty"`
 	Serial     string `json:"serial,omitempty"`
 	Status     string `json:"status,omitempty"`
+	// Cartridges maps GLPI cartridge keys ("tonerblack", "drumcyan",
+	// "wastetoner", ...) to the remaining level in percent
+	Cartridges   map[string]int    `json:"cartridges,omitempty"`
+	PageCounters *GLPIPageCounters `json:"pagecounters,omitempty"`
+}
+
+// GLPIPageCounters represents printer page counters
+type GLPIPageCounters struct {
+	Total      int `json:"total,omitempty"`
+	Black      int `json:"black,omitempty"`
+	Color      int `json:"color,omitempty"`
+	RectoVerso int `json:"rectoverso,omitempty"`
 }
 
 // Client interacts with GLPI REST API.
@@ -330,14 +451,14 @@ func convertToGLPIInventory(asset inventory.AssetModel) *GLPIInventory {
 		},
 	}
 
-	// Set device ID - use MAC, IP, or serial as fallback
+	// Set device ID - use serial, MAC, or IP as fallback
 	if inv.DeviceID == "" {
-		if asset.MAC != "" {
+		if asset.Serial != "" {
+			inv.DeviceID = asset.Serial
+		} else if asset.MAC != "" {
 			inv.DeviceID = asset.MAC
 		} else if asset.IP.IsValid() {
 			inv.DeviceID = asset.IP.String()
-		} else if asset.Serial != "" {
-			inv.DeviceID = asset.Serial
 		} else {
 			inv.DeviceID = fmt.Sprintf("goscanner-%s", asset.IP.String())
 		}
@@ -359,36 +480,53 @@ func convertToGLPIInventory(asset inventory.AssetModel) *GLPIInventory {
 		inv.Content.Hardware = &GLPIHardware{
 			Name:        hostname,
 			UUID:        asset.Serial,
+			Workgroup:   asset.Workgroup,
 			Description: description,
 		}
 		if asset.OSName != "" {
 			inv.Content.OperatingSystem = &GLPIOperatingSystem{
 				FullName:      fmt.Sprintf("%s %s", asset.OSName, asset.OSVersion),
 				KernelVersion: asset.OSVersion,
+				Arch:          asset.Arch,
 				FQDN:          hostname,
 			}
+			if asset.Kernel != "" {
+				inv.Content.OperatingSystem.KernelVersion = asset.Kernel
+			}
 		}
 	case "NetworkEquipment", "Switch", "Router":
 		inv.ItemType = "NetworkEquipment"
 		inv.Content.NetworkDevice = &GLPINetworkDevice{
-			Type:   asset.Type,
-			Model:  asset.Model,
-			MAC:    asset.MAC,
-			Serial: asset.Serial,
+			Type:     asset.Type,
+			Model:    asset.Model,
+			Firmware: asset.Firmware,
+			MAC:      asset.MAC,
+			Serial:   asset.Serial,
 		}
+		inv.Content.NetworkComponents = convertComponents(asset.Components)
 	case "Printer", "Peripheral":
 		// Check if it's actually a printer or generic peripheral
 		if strings.Contains(strings.ToLower(asset.Model), "printer") ||
 			strings.Contains(strings.ToLower(asset.Vendor), "printer") ||
 			asset.Type == "Printer" {
 			inv.ItemType = "Printer"
-			inv.Content.Printers = []GLPIPrinter{
-				{
-					Name:   hostname,
-					Serial: asset.Serial,
-					Status: "active",
-				},
+			printer := GLPIPrinter{
+				Name:   hostname,
+				Serial: asset.Serial,
+				Status: "active",
+			}
+			if asset.Printer != nil {
+				printer.Cartridges = convertCartridges(asset.Printer.Supplies)
+				if c := asset.Printer.Counters; c != (inventory.PageCounters{}) {
+					printer.PageCounters = &GLPIPageCounters{
+						Total:      c.Total,
+						Black:      c.Mono,
+						Color:      c.Color,
+						RectoVerso: c.Duplex,
+					}
+				}
 			}
+			inv.Content.Printers = []GLPIPrinter{printer}
 		} else {
 			// For other peripherals like copiers, use Computer type with description
 			inv.ItemType = "Computer"
@@ -409,6 +547,26 @@ func convertToGLPIInventory(asset inventory.AssetModel) *GLPIInventory {
 		}
 	}
 
+	if inv.ItemType == "Computer" {
+		if asset.Serial != "" {
+			inv.Content.Bios = &GLPIBios{SSN: asset.Serial}
+		}
+		if asset.Host != nil {
+			convertHostResources(inv.Content, *asset.Host)
+		}
+	}
+
+	// Devices that reported their interfaces get one entry per interface:
+	// network ports for equipment and printers, networks for computers
+	if len(asset.Interfaces) > 0 {
+		if inv.ItemType == "NetworkEquipment" || inv.ItemType == "Printer" {
+			inv.Content.NetworkPorts = convertNetworkPorts(asset)
+		} else {
+			inv.Content.Networks = convertNetworks(asset.Interfaces)
+		}
+		return inv
+	}
+
 	// Add network information if available
 	if asset.IP.IsValid() {
 		network := GLPINetwork{
@@ -433,6 +591,238 @@ func convertToGLPIInventory(asset inventory.AssetModel) *GLPIInventory {
 	return inv
 }
 
+// convertComponents maps ENTITY-MIB components to GLPI network components
+func convertComponents(components []inventory.HardwareComponent) []GLPINetworkComponent {
+	var out []GLPINetworkComponent
+	for _, c := range components {
+		out = append(out, GLPINetworkComponent{
+			Index:          c.Index,
+			ContainedIndex: c.ContainedIn,
+			Type:           c.Class,
+			Name:           c.Name,
+			Description:    c.Description,
+			Manufacturer:   c.Manufacturer,
+			Model:          c.Model,
+			Serial:         c.Serial,
+			Revision:       c.HardwareRev,
+			Firmware:       c.FirmwareRev,
+			Version:        c.SoftwareRev,
+		})
+	}
+	return out
+}
+
+// convertHostResources fills the computer sections of content from the
+// HOST-RESOURCES-MIB inventory
+func convertHostResources(content *GLPIInventoryContent, host inventory.HostResources) {
+	if content.Hardware != nil {
+		content.Hardware.Memory = int(host.Memory >> 20)
+	}
+	for _, cpu := range host.Processors {
+		content.CPUs = append(content.CPUs, GLPICPU{Name: cpu.Description})
+	}
+	for _, s := range host.Storage {
+		switch s.Type {
+		case "fixedDisk", "removableDisk", "networkDisk", "flashMemory":
+		default:
+			continue
+		}
+		content.Drives = append(content.Drives, GLPIDrive{
+			Volumn: s.Description,
+			Type:   s.Type,
+			Total:  s.Size >> 20,
+			Free:   (s.Size - min(s.Used, s.Size)) >> 20,
+		})
+	}
+	for _, sw := range host.Software {
+		content.Softwares = append(content.Softwares, GLPISoftware{Name: sw.Name, Version: sw.Version, InstallDate: sw.Installed})
+	}
+	for _, p := range host.Processes {
+		cmd := p.Path
+		if cmd == "" {
+			cmd = p.Name
+		}
+		if p.Args != "" {
+			cmd += " " + p.Args
+		}
+		content.Processes = append(content.Processes, GLPIProcess{Cmd: cmd, PID: p.PID})
+	}
+}
+
+// cartridgeKinds maps Printer-MIB supply types to GLPI cartridge key
+// prefixes; supplies of a colour get the colour appended
+var cartridgeKinds = map[string]string{
+	"toner":          "toner",
+	"tonerCartridge": "toner",
+	"opc":            "drum",
+	"developer":      "developer",
+	"ink":            "cartridge",
+	"inkCartridge":   "cartridge",
+	"wasteToner":     "wastetoner",
+	"fuser":          "fuserkit",
+	"transferUnit":   "transferkit",
+	"cleanerUnit":    "cleaningkit",
+}
+
+// convertCartridges maps printer supplies with a known level to GLPI
+// cartridge levels
+func convertCartridges(supplies []inventory.PrinterSupply) map[string]int {
+	cartridges := map[string]int{}
+	for _, supply := range supplies {
+		kind, ok := cartridgeKinds[supply.Type]
+		if !ok {
+			continue
+		}
+		level, ok := supply.Percent()
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "toner", "drum", "developer", "cartridge":
+			color := strings.ReplaceAll(supply.Color, " ", "")
+			if color == "" {
+				color = "black"
+			}
+			kind += color
+		}
+		cartridges[kind] = level
+	}
+	if len(cartridges) == 0 {
+		return nil
+	}
+	return cartridges
+}
+
+// convertNetworkPorts maps interfaces to GLPI network ports
+func convertNetworkPorts(asset inventory.AssetModel) []GLPINetworkPort {
+	ports := make([]GLPINetworkPort, 0, len(asset.Interfaces))
+	for _, iface := range asset.Interfaces {
+		port := GLPINetworkPort{
+			IfNumber:         iface.Index,
+			IfName:           iface.Name,
+			IfDescr:          iface.Description,
+			IfAlias:          iface.Alias,
+			IfMTU:            iface.MTU,
+			IfSpeed:          iface.Speed,
+			IfStatus:         ifStatusCodes[iface.OperStatus],
+			IfInternalStatus: ifStatusCodes[iface.AdminStatus],
+			MAC:              iface.MAC,
+		}
+		if iface.Type != 0 {
+			port.IfType = fmt.Sprintf("%d", iface.Type)
+		}
+		for _, prefix := range iface.Addresses {
+			port.IPs = append(port.IPs, prefix.Addr().String())
+		}
+		addPortConnections(&port, asset.Neighbors)
+		addPortForwarding(&port, asset.Forwarding)
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// addPortConnections lists the neighbors seen on the port. A device seen
+// by both LLDP and CDP is listed once, with the LLDP details
+func addPortConnections(port *GLPINetworkPort, neighbors []inventory.Neighbor) {
+	seen := map[string]bool{}
+	for _, protocol := range []string{"lldp", "cdp"} {
+		for _, n := range neighbors {
+			if n.Protocol != protocol || n.LocalIndex != port.IfNumber {
+				continue
+			}
+			conn := GLPIPortConnection{
+				SysName:  n.RemoteName,
+				SysDescr: n.RemoteDescription,
+				IfDescr:  n.RemotePort,
+			}
+			// LLDP chassis IDs are not always MAC addresses
+			if _, err := net.ParseMAC(n.RemoteChassisID); err == nil {
+				conn.SysMAC = n.RemoteChassisID
+			}
+			if n.RemoteIP.IsValid() {
+				conn.IP = n.RemoteIP.String()
+			}
+			key := conn.IP
+			if key == "" {
+				key = strings.ToLower(conn.SysName)
+			}
+			if key != "" && seen[key] {
+				continue
+			}
+			seen[key] = true
+			if protocol == "lldp" {
+				port.LLDP = true
+			} else {
+				port.CDP = true
+			}
+			port.Connections = append(port.Connections, conn)
+		}
+	}
+}
+
+// addPortForwarding lists the VLANs seen on the port and, when LLDP and
+// CDP found no neighbor there, the MAC addresses learned on it
+func addPortForwarding(port *GLPINetworkPort, entries []inventory.ForwardingEntry) {
+	neighbors := len(port.Connections) > 0
+	vlans := map[int]bool{}
+	macs := map[string]bool{}
+	for _, e := range entries {
+		if e.IfIndex != port.IfNumber {
+			continue
+		}
+		if e.VLAN != 0 && !vlans[e.VLAN] {
+			vlans[e.VLAN] = true
+			port.Vlans = append(port.Vlans, GLPIVlan{Number: e.VLAN})
+		}
+		if neighbors || macs[e.MAC] {
+			continue
+		}
+		macs[e.MAC] = true
+		port.Connections = append(port.Connections, GLPIPortConnection{MAC: e.MAC})
+	}
+	sort.Slice(port.Vlans, func(i, j int) bool { return port.Vlans[i].Number < port.Vlans[j].Number })
+}
+
+// convertNetworks maps interfaces to GLPI computer networks, one per
+// address; interfaces without an address are listed once
+func convertNetworks(ifaces []inventory.NetworkInterface) []GLPINetwork {
+	var networks []GLPINetwork
+	for _, iface := range ifaces {
+		base := GLPINetwork{
+			Description: iface.Name,
+			MacAddr:     iface.MAC,
+			Status:      iface.OperStatus,
+			Speed:       int(iface.Speed / 1_000_000),
+		}
+		if base.Description == "" {
+			base.Description = iface.Description
+		}
+		switch iface.Type {
+		case 6:
+			base.Type = "ethernet"
+		case 71:
+			base.Type = "wifi"
+		case 24:
+			base.Type = "loopback"
+		}
+		if len(iface.Addresses) == 0 {
+			networks = append(networks, base)
+			continue
+		}
+		for _, prefix := range iface.Addresses {
+			network := base
+			if prefix.Addr().Is4() {
+				network.IPAddress = prefix.Addr().String()
+				network.IPMask = net.IP(net.CIDRMask(prefix.Bits(), 32)).String()
+			} else {
+				network.IPAddress6 = prefix.Addr().String()
+			}
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
 // getInventoryURL extracts the base GLPI URL and constructs inventory endpoint
 func getInventoryURL(apiBaseURL string) string {
 	// Remove API paths to get base GLPI URL
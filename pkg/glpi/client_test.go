@@ -1,6 +1,12 @@
 package glpi
 
-import "testing"
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
 
 func TestSanitizeBaseURL(t *testing.T) {
 	cases := map[string]string{
@@ -34,3 +40,207 @@ func TestOAuthTokenURL(t *testing.T) {
 		t.Fatalf("expected error for legacy endpoint")
 	}
 }
+
+func TestConvertInterfaces(t *testing.T) {
+	ifaces := []inventory.NetworkInterface{
+		{
+			Index: 1, Name: "Gi0/1", Description: "GigabitEthernet0/1", Alias: "uplink",
+			Type: 6, MTU: 1500, Speed: 1_000_000_000, AdminStatus: "up", OperStatus: "up",
+			MAC:       "00:1b:54:aa:bb:01",
+			Addresses: []netip.Prefix{netip.MustParsePrefix("10.0.0.2/24"), netip.MustParsePrefix("2001:db8::2/64")},
+		},
+		{Index: 2, Name: "Gi0/2", Type: 6, AdminStatus: "up", OperStatus: "down"},
+	}
+	asset := inventory.AssetModel{Type: "Switch", IP: netip.MustParseAddr("10.0.0.2"), Interfaces: ifaces}
+	inv := convertToGLPIInventory(asset)
+	if len(inv.Content.Networks) != 0 {
+		t.Fatalf("network equipment got computer networks %+v", inv.Content.Networks)
+	}
+	wantPorts := []GLPINetworkPort{
+		{
+			IfNumber: 1, IfName: "Gi0/1", IfDescr: "GigabitEthernet0/1", IfAlias: "uplink", IfType: "6",
+			IfMTU: 1500, IfSpeed: 1_000_000_000, IfStatus: "1", IfInternalStatus: "1",
+			MAC: "00:1b:54:aa:bb:01", IPs: []string{"10.0.0.2", "2001:db8::2"},
+		},
+		{IfNumber: 2, IfName: "Gi0/2", IfType: "6", IfStatus: "2", IfInternalStatus: "1"},
+	}
+	if !reflect.DeepEqual(inv.Content.NetworkPorts, wantPorts) {
+		t.Fatalf("network ports\n got %+v\nwant %+v", inv.Content.NetworkPorts, wantPorts)
+	}
+
+	asset.Type = "Computer"
+	inv = convertToGLPIInventory(asset)
+	wantNetworks := []GLPINetwork{
+		{Description: "Gi0/1", IPAddress: "10.0.0.2", IPMask: "255.255.255.0", MacAddr: "00:1b:54:aa:bb:01", Status: "up", Type: "ethernet", Speed: 1000},
+		{Description: "Gi0/1", IPAddress6: "2001:db8::2", MacAddr: "00:1b:54:aa:bb:01", Status: "up", Type: "ethernet", Speed: 1000},
+		{Description: "Gi0/2", Status: "down", Type: "ethernet"},
+	}
+	if len(inv.Content.NetworkPorts) != 0 || !reflect.DeepEqual(inv.Content.Networks, wantNetworks) {
+		t.Fatalf("networks\n got %+v\nwant %+v", inv.Content.Networks, wantNetworks)
+	}
+
+	asset.Interfaces = nil
+	inv = convertToGLPIInventory(asset)
+	if len(inv.Content.Networks) != 1 || inv.Content.Networks[0].IPAddress != "10.0.0.2" {
+		t.Fatalf("fallback network %+v", inv.Content.Networks)
+	}
+}
+
+func TestConvertPortConnections(t *testing.T) {
+	asset := inventory.AssetModel{
+		Type: "Switch",
+		IP:   netip.MustParseAddr("10.0.0.2"),
+		Interfaces: []inventory.NetworkInterface{
+			{Index: 1, Name: "Gi0/1"},
+			{Index: 2, Name: "Gi0/2"},
+			{Index: 3, Name: "Gi0/3"},
+		},
+		Neighbors: []inventory.Neighbor{
+			{Protocol: "cdp", LocalIndex: 1, RemoteName: "core-sw1", RemotePort: "GigabitEthernet1/0/24", RemoteIP: netip.MustParseAddr("10.0.0.1"), RemoteDescription: "cisco C9300-48P"},
+			{Protocol: "lldp", LocalIndex: 1, RemoteName: "core-sw1.example.com", RemotePort: "Gi1/0/24", RemoteChassisID: "00:1b:54:10:20:30", RemoteIP: netip.MustParseAddr("10.0.0.1")},
+			{Protocol: "lldp", LocalIndex: 2, RemotePort: "eth0", RemoteChassisID: "ap-01"},
+		},
+	}
+	ports := convertToGLPIInventory(asset).Content.NetworkPorts
+	want := []GLPINetworkPort{
+		{
+			IfNumber: 1, IfName: "Gi0/1", LLDP: true,
+			Connections: []GLPIPortConnection{{SysName: "core-sw1.example.com", SysMAC: "00:1b:54:10:20:30", IfDescr: "Gi1/0/24", IP: "10.0.0.1"}},
+		},
+		{IfNumber: 2, IfName: "Gi0/2", LLDP: true, Connections: []GLPIPortConnection{{IfDescr: "eth0"}}},
+		{IfNumber: 3, IfName: "Gi0/3"},
+	}
+	if !reflect.DeepEqual(ports, want) {
+		t.Fatalf("network ports\n got %+v\nwant %+v", ports, want)
+	}
+}
+
+func TestConvertPortForwarding(t *testing.T) {
+	asset := inventory.AssetModel{
+		Type:       "Switch",
+		IP:         netip.MustParseAddr("10.0.0.2"),
+		Interfaces: []inventory.NetworkInterface{{Index: 1, Name: "Gi0/1"}, {Index: 5, Name: "Gi0/5"}},
+		Neighbors:  []inventory.Neighbor{{Protocol: "lldp", LocalIndex: 1, RemoteName: "core-sw1"}},
+		Forwarding: []inventory.ForwardingEntry{
+			{MAC: "00:50:56:01:02:03", IfIndex: 1, VLAN: 20},
+			{MAC: "00:50:56:01:02:04", IfIndex: 5, VLAN: 20},
+			{MAC: "00:50:56:01:02:04", IfIndex: 5, VLAN: 10},
+		},
+	}
+	ports := convertToGLPIInventory(asset).Content.NetworkPorts
+	want := []GLPINetworkPort{
+		// The uplink lists its LLDP neighbor, not the addresses behind it.
+		{
+			IfNumber: 1, IfName: "Gi0/1", LLDP: true,
+			Connections: []GLPIPortConnection{{SysName: "core-sw1"}},
+			Vlans:       []GLPIVlan{{Number: 20}},
+		},
+		{
+			IfNumber: 5, IfName: "Gi0/5",
+			Connections: []GLPIPortConnection{{MAC: "00:50:56:01:02:04"}},
+			Vlans:       []GLPIVlan{{Number: 10}, {Number: 20}},
+		},
+	}
+	if !reflect.DeepEqual(ports, want) {
+		t.Fatalf("network ports\n got %+v\nwant %+v", ports, want)
+	}
+}
+
+func TestConvertPrinter(t *testing.T) {
+	asset := inventory.AssetModel{
+		Type:   "Printer",
+		IP:     netip.MustParseAddr("192.0.2.30"),
+		Serial: "CNB1234567",
+		Printer: &inventory.PrinterInfo{
+			Supplies: []inventory.PrinterSupply{
+				{Type: "toner", Color: "black", Level: 750, MaxCapacity: 3000},
+				{Type: "toner", Color: "cyan", Level: 10, MaxCapacity: 10},
+				{Type: "opc", Level: 80, MaxCapacity: 100},
+				{Type: "wasteToner", Level: -3, MaxCapacity: 100},
+				{Type: "staples", Level: 5, MaxCapacity: 10},
+			},
+			Counters: inventory.PageCounters{Total: 15230, Mono: 15000, Color: 230, Duplex: 4100},
+		},
+	}
+	inv := convertToGLPIInventory(asset)
+	printer := inv.Content.Printers[0]
+	wantCartridges := map[string]int{"tonerblack": 25, "tonercyan": 100, "drumblack": 80}
+	if !reflect.DeepEqual(printer.Cartridges, wantCartridges) {
+		t.Fatalf("cartridges %v", printer.Cartridges)
+	}
+	if *printer.PageCounters != (GLPIPageCounters{Total: 15230, Black: 15000, Color: 230, RectoVerso: 4100}) {
+		t.Fatalf("page counters %+v", printer.PageCounters)
+	}
+
+	asset.Printer = nil
+	if printer := convertToGLPIInventory(asset).Content.Printers[0]; printer.Cartridges != nil || printer.PageCounters != nil {
+		t.Fatalf("printer without SNMP data %+v", printer)
+	}
+}
+
+func TestConvertHardware(t *testing.T) {
+	asset := inventory.AssetModel{
+		Type:     "Switch",
+		IP:       netip.MustParseAddr("192.0.2.40"),
+		MAC:      "00:1b:54:aa:bb:01",
+		Serial:   "FOC1234X0AB",
+		Firmware: "15.2(7)E3",
+		Components: []inventory.HardwareComponent{
+			{Index: 1, Class: "stack", Name: "c29xxStack"},
+			{Index: 1001, ContainedIn: 1, Class: "chassis", Model: "WS-C2960X-48FPD-L", Serial: "FOC1234X0AB", HardwareRev: "V06", SoftwareRev: "15.2(7)E3"},
+		},
+	}
+	inv := convertToGLPIInventory(asset)
+	if inv.DeviceID != "FOC1234X0AB" {
+		t.Fatalf("device id %q, want the serial", inv.DeviceID)
+	}
+	if inv.Content.NetworkDevice.Firmware != "15.2(7)E3" || inv.Content.NetworkDevice.Serial != "FOC1234X0AB" {
+		t.Fatalf("network device %+v", inv.Content.NetworkDevice)
+	}
+	wantComponents := []GLPINetworkComponent{
+		{Index: 1, Type: "stack", Name: "c29xxStack"},
+		{Index: 1001, ContainedIndex: 1, Type: "chassis", Model: "WS-C2960X-48FPD-L", Serial: "FOC1234X0AB", Revision: "V06", Version: "15.2(7)E3"},
+	}
+	if !reflect.DeepEqual(inv.Content.NetworkComponents, wantComponents) {
+		t.Fatalf("components %+v", inv.Content.NetworkComponents)
+	}
+
+	computer := inventory.AssetModel{
+		Type:   "Computer",
+		IP:     netip.MustParseAddr("192.0.2.41"),
+		Serial: "VMware-42 1a",
+		OSName: "Ubuntu", OSVersion: "22.04", Kernel: "Linux 5.15.0-91-generic", Arch: "x86_64",
+		Workgroup: "corp.example.com",
+		Host: &inventory.HostResources{
+			Memory:     8 << 30,
+			Processors: []inventory.Processor{{Description: "Intel(R) Xeon(R) CPU E5-2680 v4"}},
+			Storage: []inventory.Storage{
+				{Description: "Physical memory", Type: "ram", Size: 8 << 30},
+				{Description: "/", Type: "fixedDisk", Size: 100 << 30, Used: 40 << 30},
+			},
+			Software:  []inventory.Software{{Name: "nginx", Version: "1.18.0-6ubuntu14", Installed: "2023-10-03"}},
+			Processes: []inventory.Process{{PID: 812, Name: "nginx", Path: "/usr/sbin/nginx", Args: "-g daemon off;"}, {PID: 1, Name: "systemd"}},
+		},
+	}
+	inv = convertToGLPIInventory(computer)
+	c := inv.Content
+	if c.Bios == nil || c.Bios.SSN != "VMware-42 1a" || c.Hardware.Memory != 8192 || c.Hardware.Workgroup != "corp.example.com" {
+		t.Fatalf("bios %+v hardware %+v", c.Bios, c.Hardware)
+	}
+	if !reflect.DeepEqual(c.CPUs, []GLPICPU{{Name: "Intel(R) Xeon(R) CPU E5-2680 v4"}}) {
+		t.Fatalf("cpus %+v", c.CPUs)
+	}
+	if !reflect.DeepEqual(c.Drives, []GLPIDrive{{Volumn: "/", Type: "fixedDisk", Total: 102400, Free: 61440}}) {
+		t.Fatalf("drives %+v", c.Drives)
+	}
+	if osInfo := c.OperatingSystem; osInfo == nil || osInfo.KernelVersion != "Linux 5.15.0-91-generic" || osInfo.Arch != "x86_64" {
+		t.Fatalf("operating system %+v", osInfo)
+	}
+	if !reflect.DeepEqual(c.Softwares, []GLPISoftware{{Name: "nginx", Version: "1.18.0-6ubuntu14", InstallDate: "2023-10-03"}}) {
+		t.Fatalf("softwares %+v", c.Softwares)
+	}
+	wantProcesses := []GLPIProcess{{Cmd: "/usr/sbin/nginx -g daemon off;", PID: 812}, {Cmd: "systemd", PID: 1}}
+	if !reflect.DeepEqual(c.Processes, wantProcesses) {
+		t.Fatalf("processes %+v", c.Processes)
+	}
+}
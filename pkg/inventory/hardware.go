@@ -0,0 +1,66 @@
+package inventory
+
+// HardwareComponent is one entry of the ENTITY-MIB entPhysicalTable.
+type HardwareComponent struct {
+	// Index is the entPhysicalIndex; ContainedIn is the index of the
+	// component holding this one, 0 for the outermost component.
+	Index       int
+	ContainedIn int
+	// Class is the PhysicalClass name, such as "chassis", "module",
+	// "stack", "powerSupply" or "port".
+	Class        string
+	Name         string
+	Description  string
+	Manufacturer string
+	Model        string
+	Serial       string
+	HardwareRev  string
+	FirmwareRev  string
+	SoftwareRev  string
+}
+
+// HostResources describes a host as reported by the HOST-RESOURCES-MIB.
+type HostResources struct {
+	Processors []Processor
+	// Memory is the physical memory in bytes.
+	Memory    uint64
+	Storage   []Storage
+	Software  []Software
+	Processes []Process
+}
+
+// Processor is one CPU of a host.
+type Processor struct {
+	Description string
+	// Load is the average load over the last minute, in percent.
+	Load int
+}
+
+// Storage is one storage area of a host: a file system, RAM or swap.
+type Storage struct {
+	Description string
+	// Type is "fixedDisk", "removableDisk", "networkDisk", "compactDisc",
+	// "flashMemory", "ram", "virtualMemory" or "other".
+	Type string
+	// Size and Used are in bytes.
+	Size uint64
+	Used uint64
+}
+
+// Software is one installed package.
+type Software struct {
+	Name    string
+	Version string
+	// Installed is the installation date as YYYY-MM-DD, when known.
+	Installed string
+}
+
+// Process is one running process.
+type Process struct {
+	PID  int
+	Name string
+	Path string
+	Args string
+	// Memory is the resident memory in bytes.
+	Memory uint64
+}
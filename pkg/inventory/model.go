@@ -1,6 +1,9 @@
 package inventory
 
-import "net/netip"
+import (
+	"net/netip"
+	"time"
+)
 
 // AssetModel describes normalized device info.
 type AssetModel struct {
@@ -14,5 +17,145 @@ type AssetModel struct {
 	OSName     string
 	OSVersion  string
 	Serial     string
+	// Kernel is the operating system kernel and its release, such as
+	// "Linux 5.15.0-91-generic"; Arch is the machine architecture.
+	Kernel string
+	Arch   string
+	// Workgroup is the Windows workgroup or domain the host belongs to.
+	Workgroup string
+	// Firmware is the software version running on network equipment.
+	Firmware   string
 	Attributes map[string]string
+	// Interfaces lists the device's network interfaces, as reported over
+	// SNMP.
+	Interfaces []NetworkInterface
+	// Neighbors lists the devices seen on the device's ports by LLDP or
+	// CDP.
+	Neighbors []Neighbor
+	// Forwarding lists the MAC addresses a switch learned on its ports
+	// (BRIDGE-MIB, Q-BRIDGE-MIB); ARP lists the address-to-MAC mappings a
+	// router holds (IP-MIB ipNetToMediaTable).
+	Forwarding []ForwardingEntry
+	ARP        []ARPEntry
+	// SwitchPort is where the device is plugged in, when a scanned switch
+	// learned its MAC address.
+	SwitchPort *SwitchPort
+	// Printer holds supplies and page counters of printers.
+	Printer *PrinterInfo
+	// Components lists the physical parts of the device (ENTITY-MIB):
+	// chassis, stack members, modules, power supplies and so on.
+	Components []HardwareComponent
+	// Host holds the processors, memory, storage, software and processes
+	// of hosts reporting the HOST-RESOURCES-MIB.
+	Host *HostResources
+	// Confidence maps each classified field ("type", "vendor", "model",
+	// "os_name", "os_version") to the confidence in its value, 0 to 100.
+	Confidence map[string]int
+	// Evidence lists every observation weighed while classifying, including
+	// those in favour of values that were not chosen.
+	Evidence []Evidence
+	// Probes reports each fingerprint prober run against the asset.
+	Probes []ProbeResult
+}
+
+// NetworkInterface is one network interface of a device (IF-MIB ifEntry).
+type NetworkInterface struct {
+	// Index is the interface's ifIndex, unique within the device.
+	Index int
+	Name  string
+	// Description is the product description of the interface (ifDescr);
+	// Alias is the description given by an administrator (ifAlias).
+	Description string
+	Alias       string
+	// Type is the IANAifType number, such as 6 for Ethernet.
+	Type int
+	MTU  int
+	// Speed is in bits per second.
+	Speed uint64
+	// AdminStatus and OperStatus are IF-MIB status names: "up", "down",
+	// "testing", "unknown", "dormant", "notPresent" or "lowerLayerDown".
+	AdminStatus string
+	OperStatus  string
+	MAC         string
+	Addresses   []netip.Prefix
+}
+
+// Neighbor is a device seen on one of the device's ports by LLDP or CDP.
+type Neighbor struct {
+	// Protocol is "lldp" or "cdp".
+	Protocol string
+	// LocalIndex is the ifIndex of the port the neighbor was seen on, 0
+	// when unknown; LocalPort is the port's name.
+	LocalIndex int
+	LocalPort  string
+	// RemoteName is the neighbor's LLDP system name or CDP device ID.
+	RemoteName string
+	RemotePort string
+	// RemoteChassisID identifies the neighbor's chassis, usually by MAC
+	// address.
+	RemoteChassisID string
+	// RemoteIP is the neighbor's management address, when advertised.
+	RemoteIP netip.Addr
+	// RemoteDescription is the neighbor's LLDP system description or CDP
+	// platform.
+	RemoteDescription string
+}
+
+// ForwardingEntry is a MAC address a switch learned on one of its ports.
+type ForwardingEntry struct {
+	MAC string
+	// IfIndex and Port identify the port; IfIndex is 0 when the bridge
+	// port has no interface.
+	IfIndex int
+	Port    string
+	// VLAN is the VLAN the address was learned in, 0 when the switch does
+	// not report it.
+	VLAN int
+}
+
+// ARPEntry maps an address to the MAC address a router resolved it to.
+type ARPEntry struct {
+	IP      netip.Addr
+	MAC     string
+	IfIndex int
+}
+
+// SwitchPort locates a device on the switch port its MAC address was
+// learned on.
+type SwitchPort struct {
+	Switch   string
+	SwitchIP netip.Addr
+	IfIndex  int
+	Port     string
+	VLAN     int
+}
+
+// ProbeResult reports how one fingerprint prober run went.
+type ProbeResult struct {
+	Prober   string
+	Duration time.Duration
+	// Error is empty when the prober succeeded.
+	Error string
+}
+
+// Classified fields, as named in AssetModel.Confidence and Evidence.
+const (
+	FieldType      = "type"
+	FieldVendor    = "vendor"
+	FieldModel     = "model"
+	FieldOSName    = "os_name"
+	FieldOSVersion = "os_version"
+)
+
+// Evidence is one observation in favour of a value for a classified field.
+type Evidence struct {
+	Field  string
+	Value  string
+	Weight int
+	// Source names the kind of fact observed, such as "snmp", "http",
+	// "service" or "ports".
+	Source string
+	// Rule names the fingerprint rule that turned the fact into evidence.
+	Rule   string
+	Detail string
 }
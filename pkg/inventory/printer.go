@@ -0,0 +1,56 @@
+package inventory
+
+// PrinterInfo describes the supplies, counters and state of a printer, as
+// reported by the Printer-MIB (RFC 3805) and vendor MIBs.
+type PrinterInfo struct {
+	Supplies []PrinterSupply
+	Counters PageCounters
+	Trays    []PaperTray
+	// Status is the HOST-RESOURCES-MIB hrPrinterStatus name: "other",
+	// "unknown", "idle", "printing" or "warmup".
+	Status string
+	// Errors lists the conditions set in hrPrinterDetectedErrorState, such
+	// as "lowToner" or "jammed".
+	Errors []string
+}
+
+// PrinterSupply is one marker supply, such as a toner cartridge or a drum.
+type PrinterSupply struct {
+	Description string
+	// Type is the Printer-MIB PrtMarkerSuppliesTypeTC name, such as
+	// "toner", "opc" (a drum) or "wasteToner".
+	Type string
+	// Color is the colorant the supply holds, such as "black" or "cyan",
+	// when the printer reports one.
+	Color string
+	// Level and MaxCapacity are in the supply's own unit. A negative Level
+	// means the printer cannot tell: -2 unknown, -3 some remaining.
+	Level       int
+	MaxCapacity int
+}
+
+// Percent returns the remaining level as a percentage of the capacity.
+func (s PrinterSupply) Percent() (int, bool) {
+	if s.Level < 0 || s.MaxCapacity <= 0 {
+		return 0, false
+	}
+	return min(100, s.Level*100/s.MaxCapacity), true
+}
+
+// PageCounters holds lifetime page counts. Zero means the printer did not
+// report the counter.
+type PageCounters struct {
+	Total  int
+	Color  int
+	Mono   int
+	Duplex int
+}
+
+// PaperTray is one paper input of a printer. Capacity and Level are in
+// sheets; a negative Level means the printer cannot tell.
+type PaperTray struct {
+	Name      string
+	MediaName string
+	Capacity  int
+	Level     int
+}
@@ -0,0 +1,153 @@
+// Package service identifies the service listening on an open TCP port
+// from its banner or its answer to a small protocol probe, and holds the
+// requests that make UDP services answer.
+package service
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxResponse caps how much of a response is read and matched.
+const maxResponse = 4096
+
+// Info describes the service found on a port. Name is empty when nothing
+// matched; Banner then holds whatever the service sent, if anything.
+type Info struct {
+	Name    string `json:"name,omitempty"`
+	Product string `json:"product,omitempty"`
+	Version string `json:"version,omitempty"`
+	Banner  string `json:"banner,omitempty"`
+}
+
+// String formats the info as "name product version".
+func (i Info) String() string {
+	return strings.Join(strings.Fields(i.Name+" "+i.Product+" "+i.Version), " ")
+}
+
+// DialFunc opens a new connection to the port being identified.
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+// Detect identifies the service behind conn, an established connection to
+// port. It first waits for a banner; services that stay silent are sent
+// the probes registered for port, each over a fresh connection from dial.
+// Detect closes conn.
+func Detect(ctx context.Context, conn net.Conn, port int, dial DialFunc, timeout time.Duration) Info {
+	// Ports with a registered probe usually host client-first protocols,
+	// so their banner wait is shortened.
+	wait := timeout
+	if hasProbe(port) {
+		wait = timeout / 4
+	}
+	banner := read(ctx, conn, wait)
+	if len(banner) > 0 {
+		conn.Close()
+		if info, ok := match(nullProbe, banner); ok {
+			return info
+		}
+		return Info{Banner: printable(banner)}
+	}
+
+	for _, p := range probesFor(port) {
+		if conn == nil {
+			var err error
+			if conn, err = dial(ctx); err != nil {
+				break
+			}
+		}
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		_, err := conn.Write(p.payload)
+		var resp []byte
+		if err == nil {
+			resp = read(ctx, conn, timeout)
+		}
+		conn.Close()
+		conn = nil
+		if info, ok := match(p.name, resp); ok {
+			return info
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if conn != nil {
+		conn.Close()
+	}
+	return Info{}
+}
+
+// read collects what the peer sends within timeout, returning early once
+// the peer pauses after its first bytes.
+func read(ctx context.Context, conn net.Conn, timeout time.Duration) []byte {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	buf := make([]byte, maxResponse)
+	n := 0
+	for n < len(buf) {
+		conn.SetReadDeadline(deadline)
+		m, err := conn.Read(buf[n:])
+		n += m
+		if err != nil {
+			break
+		}
+		// Most services send their greeting in one segment; allow a
+		// short grace period for the rest.
+		deadline = time.Now().Add(100 * time.Millisecond)
+	}
+	return buf[:n]
+}
+
+// match runs the signatures of probe against resp.
+func match(probe string, resp []byte) (Info, bool) {
+	if len(resp) == 0 {
+		return Info{}, false
+	}
+	text := latin1(resp)
+	for _, sig := range signatures {
+		if sig.probe != probe {
+			continue
+		}
+		m := sig.pattern.FindStringSubmatchIndex(text)
+		if m == nil {
+			continue
+		}
+		info := Info{
+			Name:    sig.service,
+			Product: string(sig.pattern.ExpandString(nil, sig.product, text, m)),
+			Version: string(sig.pattern.ExpandString(nil, sig.version, text, m)),
+		}
+		if probe == nullProbe {
+			info.Banner = printable(resp)
+		}
+		return info, true
+	}
+	return Info{}, false
+}
+
+// latin1 maps every byte to the rune of the same value so that signatures
+// can match binary protocols with \xNN escapes.
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+var unprintable = regexp.MustCompile(`[^\x20-\x7e]+`)
+
+// printable returns the first line of a banner with control and binary
+// bytes collapsed.
+func printable(b []byte) string {
+	line, _, _ := strings.Cut(string(b), "\n")
+	line = strings.TrimSpace(unprintable.ReplaceAllString(line, " "))
+	if len(line) > 128 {
+		line = line[:128]
+	}
+	return line
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// serve accepts connections on a loopback listener and hands each one to
+// handle.
+func serve(t *testing.T, handle func(net.Conn)) (string, int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String(), ln.Addr().(*net.TCPAddr).Port
+}
+
+func detect(t *testing.T, addr string, port int) Info {
+	t.Helper()
+	dial := func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	conn, err := dial(context.Background())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	return Detect(context.Background(), conn, port, dial, 300*time.Millisecond)
+}
+
+func TestDetectBanner(t *testing.T) {
+	cases := []struct {
+		banner string
+		want   Info
+	}{
+		{"SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.6\r\n", Info{Name: "ssh", Product: "OpenSSH", Version: "8.9p1"}},
+		{"220 mail.example.com ESMTP Postfix (Ubuntu)\r\n", Info{Name: "smtp", Product: "Postfix smtpd"}},
+		{"220 (vsFTPd 3.0.5)\r\n", Info{Name: "ftp", Product: "vsftpd", Version: "3.0.5"}},
+		{"RFB 003.008\n", Info{Name: "vnc", Version: "RFB 003.008"}},
+		{"\xff\xfd\x18\xff\xfd\x20", Info{Name: "telnet"}},
+		{"\x4a\x00\x00\x00\x0a8.0.36\x00\x08\x00\x00\x00", Info{Name: "mysql", Product: "MySQL", Version: "8.0.36"}},
+	}
+	for _, tc := range cases {
+		addr, port := serve(t, func(conn net.Conn) { conn.Write([]byte(tc.banner)) })
+		got := detect(t, addr, port)
+		got.Banner = ""
+		if got != tc.want {
+			t.Fatalf("banner %q: got %+v want %+v", tc.banner, got, tc.want)
+		}
+	}
+}
+
+func TestDetectUnknownBanner(t *testing.T) {
+	addr, port := serve(t, func(conn net.Conn) { conn.Write([]byte("* welcome to widgetd\r\nmore\r\n")) })
+	if got := detect(t, addr, port); got.Name != "" || got.Banner != "* welcome to widgetd" {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestDetectProbe(t *testing.T) {
+	addr, _ := serve(t, func(conn net.Conn) {
+		buf := make([]byte, 64)
+		n, _ := conn.Read(buf)
+		if bytes.HasPrefix(buf[:n], []byte("INFO")) {
+			conn.Write([]byte("$40\r\n# Server\r\nredis_version:7.2.4\r\n"))
+		}
+	})
+	// Pretend the listener is on the Redis port.
+	if got := detect(t, addr, 6379); got != (Info{Name: "redis", Product: "Redis", Version: "7.2.4"}) {
+		t.Fatalf("got %+v", got)
+	}
+
+	addr, port := serve(t, func(conn net.Conn) {
+		buf := make([]byte, 64)
+		conn.Read(buf)
+		conn.Write([]byte("HTTP/1.0 200 OK\r\nContent-Type: text/html\r\nserver: lighttpd/1.4.59\r\n\r\n"))
+	})
+	if got := detect(t, addr, port); got != (Info{Name: "http", Product: "lighttpd/1.4.59"}) {
+		t.Fatalf("fallback probe got %+v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import "regexp"
+
+// nullProbe names the banner read done before anything is sent.
+const nullProbe = "null"
+
+// probe is a request sent to services that wait for the client to speak
+// first. Ports lists where the probe is worth sending.
+type probe struct {
+	name    string
+	payload []byte
+	ports   []int
+}
+
+var probes = []probe{
+	{
+		name:    "redis",
+		payload: []byte("INFO server\r\n"),
+		ports:   []int{6379, 6380},
+	},
+	{
+		// SSLRequest: answered with a single 'S' or 'N'.
+		name:    "postgresql",
+		payload: []byte{0x00, 0x00, 0x00, 0x08, 0x04, 0xd2, 0x16, 0x2f},
+		ports:   []int{5432, 5433},
+	},
+	{
+		// X.224 Connection Request carrying an RDP negotiation request.
+		name: "rdp",
+		payload: []byte{
+			0x03, 0x00, 0x00, 0x13, 0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00,
+			0x01, 0x00, 0x08, 0x00, 0x03, 0x00, 0x00, 0x00,
+		},
+		ports: []int{3389},
+	},
+	{
+		name:    "http",
+		payload: []byte("GET / HTTP/1.0\r\n\r\n"),
+		ports:   []int{80, 81, 8000, 8008, 8080, 8081, 8888},
+	},
+}
+
+func (p probe) covers(port int) bool {
+	for _, candidate := range p.ports {
+		if candidate == port {
+			return true
+		}
+	}
+	return false
+}
+
+// hasProbe reports whether a probe is registered for port.
+func hasProbe(port int) bool {
+	for _, p := range probes {
+		if p.covers(port) {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackProbe is sent to silent ports no probe is registered for.
+const fallbackProbe = "http"
+
+// probesFor returns the probes to send to a silent port.
+func probesFor(port int) []probe {
+	var out []probe
+	for _, p := range probes {
+		if p.covers(port) {
+			out = append(out, p)
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	for _, p := range probes {
+		if p.name == fallbackProbe {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// signature recognises a service from the response to probe. Product and
+// version are regexp templates expanded with the match, e.g. "$1".
+type signature struct {
+	probe   string
+	service string
+	product string
+	version string
+	pattern *regexp.Regexp
+}
+
+func sig(probe, service, product, version, pattern string) signature {
+	return signature{probe, service, product, version, regexp.MustCompile(pattern)}
+}
+
+// signatures is the service database, tried in order. Responses are
+// matched as Latin-1 text, so \xNN in a pattern matches the byte NN.
+var signatures = []signature{
+	// SSH
+	sig(nullProbe, "ssh", "OpenSSH", "$1", `^SSH-[\d.]+-OpenSSH[_-]([\w.]+)`),
+	sig(nullProbe, "ssh", "Dropbear sshd", "$1", `^SSH-[\d.]+-dropbear_?([\w.]*)`),
+	sig(nullProbe, "ssh", "Cisco SSH", "$1", `^SSH-[\d.]+-Cisco-([\d.]+)`),
+	sig(nullProbe, "ssh", "$1", "", `^SSH-[\d.]+-([^\s\r\n]+)`),
+
+	// SMTP, matched before FTP since both greet with 220.
+	sig(nullProbe, "smtp", "Postfix smtpd", "", `^220[ -][^\r\n]*ESMTP Postfix`),
+	sig(nullProbe, "smtp", "Exim smtpd", "$1", `^220[ -][^\r\n]*ESMTP Exim ([\w.]+)`),
+	sig(nullProbe, "smtp", "Sendmail", "$1", `^220[ -][^\r\n]*Sendmail ([\w.]+)`),
+	sig(nullProbe, "smtp", "Microsoft Exchange smtpd", "", `^220[ -][^\r\n]*Microsoft ESMTP MAIL Service`),
+	sig(nullProbe, "smtp", "", "", `^220[ -][^\r\n]*E?SMTP`),
+
+	// FTP
+	sig(nullProbe, "ftp", "vsftpd", "$1", `^220[ -][^\r\n]*\(vsFTPd ([\w.]+)\)`),
+	sig(nullProbe, "ftp", "ProFTPD", "$1", `^220[ -][^\r\n]*ProFTPD ([\w.]+)`),
+	sig(nullProbe, "ftp", "FileZilla ftpd", "$1", `^220[ -][^\r\n]*FileZilla Server(?: version)? ([\w.]+)`),
+	sig(nullProbe, "ftp", "Pure-FTPd", "", `^220[ -][^\r\n]*Pure-FTPd`),
+	sig(nullProbe, "ftp", "Microsoft ftpd", "", `^220[ -][^\r\n]*Microsoft FTP Service`),
+	sig(nullProbe, "ftp", "", "", `^220[ -][^\r\n]*FTP`),
+
+	// Telnet: option negotiation (IAC WILL/WONT/DO/DONT) or a login prompt.
+	sig(nullProbe, "telnet", "", "", `^\xff[\xfb-\xfe]`),
+	sig(nullProbe, "telnet", "", "", `(?i)^[\r\n]*(?:login|username|user name|password):`),
+
+	// MySQL handshake v10: 3-byte length, sequence 0, protocol 10, version.
+	sig(nullProbe, "mysql", "MariaDB", "$1", `(?s)^.{3}\x00\x0a(?:5\.5\.5-)?([\d.]+)-MariaDB`),
+	sig(nullProbe, "mysql", "MySQL", "$1", `(?s)^.{3}\x00\x0a([\d.]+[\w.-]*)\x00`),
+	sig(nullProbe, "mysql", "MySQL", "", `(?s)^.{3}\x00\xff.{2}Host .* is not allowed to connect`),
+
+	// VNC: RFB protocol version.
+	sig(nullProbe, "vnc", "", "RFB $1.$2", `^RFB (\d{3})\.(\d{3})\n`),
+
+	// Redis
+	sig("redis", "redis", "Redis", "$1", `redis_version:([\w.]+)`),
+	sig("redis", "redis", "Redis", "", `^-(?:NOAUTH|ERR|DENIED)`),
+
+	// PostgreSQL
+	sig("postgresql", "postgresql", "PostgreSQL", "", `^[SN]$`),
+
+	// RDP: X.224 Connection Confirm.
+	sig("rdp", "rdp", "Microsoft Terminal Services", "", `(?s)^\x03\x00.{2}.\xd0`),
+
+	// HTTP
+	sig("http", "http", "$1", "", `(?is)^HTTP/1\.[01] \d{3}.*?\r\nServer: *([^\r\n]+)`),
+	sig("http", "http", "", "", `^HTTP/1\.[01] \d{3}`),
+}
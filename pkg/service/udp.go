@@ -0,0 +1,72 @@
+package service
+
+import "sort"
+
+// UDP services only answer well-formed requests, so every probed port needs
+// a protocol-specific payload.
+var udpPayloads = map[int][]byte{
+	// DNS: recursive query for the root NS records.
+	53: {
+		0x67, 0x73, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x02, 0x00, 0x01,
+	},
+	// NTP: version 4 client request.
+	123: append([]byte{0xe3}, make([]byte, 47)...),
+	// NetBIOS-NS: node status request for the wildcard name "*".
+	137: append(append([]byte{
+		0x80, 0xf0, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x20, 'C', 'K',
+	}, []byte("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")...), 0x00, 0x00, 0x21, 0x00, 0x01),
+	// SNMP: SNMPv3 engine ID discovery (RFC 3414 section 4), a
+	// noAuthNoPriv, reportable GetRequest from the empty user. Every v3
+	// agent answers it with a Report, whatever communities and users it
+	// accepts, and v1/v2c-only agents are rare.
+	161: {
+		0x30, 0x3e, 0x02, 0x01, 0x03, // SNMPv3 message
+		// msgGlobalData: msgID, msgMaxSize 65507, reportable, USM.
+		0x30, 0x11, 0x02, 0x04, 0x67, 0x73, 0x63, 0x6e, 0x02, 0x03, 0x00, 0xff,
+		0xe3, 0x04, 0x01, 0x04, 0x02, 0x01, 0x03,
+		// msgSecurityParameters: empty engine ID, boots, time and user.
+		0x04, 0x10, 0x30, 0x0e, 0x04, 0x00, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00,
+		0x04, 0x00, 0x04, 0x00, 0x04, 0x00,
+		// ScopedPDU: empty context, GetRequest without variables.
+		0x30, 0x14, 0x04, 0x00, 0x04, 0x00, 0xa0, 0x0e, 0x02, 0x04, 0x67, 0x73,
+		0x63, 0x6e, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00, 0x30, 0x00,
+	},
+	// IPMI: RMCP/ASF presence ping.
+	623: {0x06, 0x00, 0xff, 0x06, 0x00, 0x00, 0x11, 0xbe, 0x80, 0x00, 0x00, 0x00},
+	// SSDP: unicast M-SEARCH for all devices.
+	1900: []byte("M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n"),
+	// mDNS: unicast PTR query for _services._dns-sd._udp.local.
+	5353: append(append([]byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}, dnsName("_services", "_dns-sd", "_udp", "local")...), 0x00, 0x0c, 0x00, 0x01),
+}
+
+func dnsName(labels ...string) []byte {
+	var out []byte
+	for _, l := range labels {
+		out = append(out, byte(len(l)))
+		out = append(out, l...)
+	}
+	return append(out, 0)
+}
+
+// UDPPayload returns the request that makes the service usually found on
+// UDP port answer, if there is one.
+func UDPPayload(port int) ([]byte, bool) {
+	payload, ok := udpPayloads[port]
+	return payload, ok
+}
+
+// UDPPorts returns the UDP ports UDPPayload has a request for, in
+// ascending order.
+func UDPPorts() []int {
+	ports := make([]int, 0, len(udpPayloads))
+	for port := range udpPayloads {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
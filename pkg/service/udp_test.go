@@ -0,0 +1,34 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUDPPorts(t *testing.T) {
+	want := []int{53, 123, 137, 161, 623, 1900, 5353}
+	if got := UDPPorts(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("UDPPorts() = %v, want %v", got, want)
+	}
+	if _, ok := UDPPayload(69); ok {
+		t.Fatal("payload for tftp")
+	}
+}
+
+func TestSNMPPayloadLengths(t *testing.T) {
+	p, _ := UDPPayload(161)
+	if int(p[1]) != len(p)-2 {
+		t.Fatalf("snmp message length %d, payload has %d bytes", p[1], len(p)-2)
+	}
+	if p[4] != 3 {
+		t.Fatalf("snmp version %d want 3", p[4])
+	}
+	// msgFlags: noAuthNoPriv with the reportable bit, so the agent answers
+	// with a Report.
+	if p[20] != 0x04 {
+		t.Fatalf("snmp msgFlags %#x want 0x04", p[20])
+	}
+	if p, _ := UDPPayload(137); len(p) != 50 {
+		t.Fatalf("netbios node status request is %d bytes want 50", len(p))
+	}
+}
@@ -0,0 +1,85 @@
+package topology
+
+import (
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// Locator maps addresses to MAC addresses, from the ARP tables of scanned
+// routers, and MAC addresses to the switch port they were learned on, from
+// the forwarding tables of scanned switches. It lets devices on routed
+// segments, whose MAC the scanner cannot see, be identified and located.
+type Locator struct {
+	mu  sync.Mutex
+	arp map[netip.Addr]string
+	// fdb lists, by lower-case MAC address, every switch port the address
+	// was learned on; macs counts the addresses learned on each port.
+	fdb  map[string][]inventory.SwitchPort
+	macs map[portKey]int
+}
+
+type portKey struct {
+	sw      netip.Addr
+	ifIndex int
+	port    string
+}
+
+// NewLocator returns an empty Locator. It is safe for concurrent use.
+func NewLocator() *Locator {
+	return &Locator{
+		arp:  map[netip.Addr]string{},
+		fdb:  map[string][]inventory.SwitchPort{},
+		macs: map[portKey]int{},
+	}
+}
+
+// Add records the forwarding and ARP tables of a scanned device.
+func (l *Locator) Add(asset inventory.AssetModel) {
+	if len(asset.Forwarding) == 0 && len(asset.ARP) == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range asset.ARP {
+		l.arp[e.IP] = e.MAC
+	}
+	for _, e := range asset.Forwarding {
+		sp := inventory.SwitchPort{Switch: asset.Hostname, SwitchIP: asset.IP, IfIndex: e.IfIndex, Port: e.Port, VLAN: e.VLAN}
+		mac := strings.ToLower(e.MAC)
+		l.fdb[mac] = append(l.fdb[mac], sp)
+		l.macs[portKey{asset.IP, e.IfIndex, e.Port}]++
+	}
+}
+
+// Locate fills in the asset's MAC address from the ARP tables when it has
+// none, and its switch port from the forwarding tables. Of the ports that
+// learned the address, the one with the fewest addresses is taken: the
+// others are uplinks that see the device through another switch.
+func (l *Locator) Locate(asset *inventory.AssetModel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if asset.MAC == "" {
+		asset.MAC = l.arp[asset.IP]
+	}
+	if asset.MAC == "" {
+		return
+	}
+	var best *inventory.SwitchPort
+	bestCount := 0
+	for _, sp := range l.fdb[strings.ToLower(asset.MAC)] {
+		if sp.SwitchIP == asset.IP {
+			continue
+		}
+		n := l.macs[portKey{sp.SwitchIP, sp.IfIndex, sp.Port}]
+		if best == nil || n < bestCount || (n == bestCount && sp.SwitchIP.Less(best.SwitchIP)) {
+			sp := sp
+			best, bestCount = &sp, n
+		}
+	}
+	if best != nil {
+		asset.SwitchPort = best
+	}
+}
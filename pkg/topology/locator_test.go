@@ -0,0 +1,61 @@
+package topology
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+func TestLocator(t *testing.T) {
+	l := NewLocator()
+	// The router resolves the host on a remote segment.
+	l.Add(inventory.AssetModel{
+		IP:  netip.MustParseAddr("10.0.0.254"),
+		ARP: []inventory.ARPEntry{{IP: netip.MustParseAddr("10.20.0.5"), MAC: "00:50:56:01:02:03", IfIndex: 3}},
+	})
+	// The core switch sees both hosts through its uplink to the access
+	// switch, and lists an address of its own.
+	l.Add(inventory.AssetModel{
+		IP: netip.MustParseAddr("10.0.0.1"), Hostname: "core-sw1",
+		Forwarding: []inventory.ForwardingEntry{
+			{MAC: "00:50:56:01:02:03", IfIndex: 24, Port: "Gi1/0/24", VLAN: 20},
+			{MAC: "00:50:56:01:02:04", IfIndex: 24, Port: "Gi1/0/24", VLAN: 20},
+			{MAC: "00:1b:54:aa:bb:01", IfIndex: 24, Port: "Gi1/0/24", VLAN: 1},
+		},
+	})
+	l.Add(inventory.AssetModel{
+		IP: netip.MustParseAddr("10.0.0.2"), Hostname: "access-sw2",
+		Forwarding: []inventory.ForwardingEntry{
+			{MAC: "00:50:56:01:02:03", IfIndex: 5, Port: "Gi0/5", VLAN: 20},
+			{MAC: "00:50:56:01:02:04", IfIndex: 6, Port: "Gi0/6", VLAN: 20},
+		},
+	})
+
+	asset := inventory.AssetModel{IP: netip.MustParseAddr("10.20.0.5")}
+	l.Locate(&asset)
+	want := inventory.SwitchPort{Switch: "access-sw2", SwitchIP: netip.MustParseAddr("10.0.0.2"), IfIndex: 5, Port: "Gi0/5", VLAN: 20}
+	if asset.MAC != "00:50:56:01:02:03" || asset.SwitchPort == nil || *asset.SwitchPort != want {
+		t.Fatalf("MAC %q switch port %+v", asset.MAC, asset.SwitchPort)
+	}
+
+	// A MAC found by discovery is kept, whatever its case.
+	asset = inventory.AssetModel{IP: netip.MustParseAddr("10.20.0.6"), MAC: "00:50:56:01:02:04"}
+	l.Locate(&asset)
+	if asset.SwitchPort == nil || asset.SwitchPort.Port != "Gi0/6" {
+		t.Fatalf("switch port %+v", asset.SwitchPort)
+	}
+
+	// A switch is not located on its own ports.
+	sw := inventory.AssetModel{IP: netip.MustParseAddr("10.0.0.1"), MAC: "00:1B:54:AA:BB:01"}
+	l.Locate(&sw)
+	if sw.SwitchPort != nil {
+		t.Fatalf("switch port %+v", sw.SwitchPort)
+	}
+
+	unknown := inventory.AssetModel{IP: netip.MustParseAddr("10.30.0.1")}
+	l.Locate(&unknown)
+	if unknown.MAC != "" || unknown.SwitchPort != nil {
+		t.Fatalf("unknown host located: %+v", unknown)
+	}
+}
@@ -0,0 +1,306 @@
+// Package topology builds the layer-2 topology of a network from the
+// LLDP and CDP neighbor tables collected from scanned devices, and writes
+// it as a Graphviz DOT graph or as JSON.
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// Graph is the set of devices and the links between them.
+type Graph struct {
+	Devices []Device `json:"devices"`
+	Links   []Link   `json:"links"`
+}
+
+// Device is a node of the graph: either a scanned asset or a neighbor that
+// was only seen through LLDP or CDP.
+type Device struct {
+	// ID is the device's IP address when it was scanned, and otherwise its
+	// advertised name, chassis ID or management address.
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	IP          string `json:"ip,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Scanned is false for devices only known from their neighbors.
+	Scanned bool `json:"scanned"`
+	// Ports lists the device's ports that have a link, in name order.
+	Ports []string `json:"ports,omitempty"`
+}
+
+// Endpoint is one end of a link.
+type Endpoint struct {
+	Device string `json:"device"`
+	Port   string `json:"port,omitempty"`
+}
+
+// Link connects two device ports. A link reported by both ends, or by
+// both protocols, appears once.
+type Link struct {
+	A         Endpoint `json:"a"`
+	B         Endpoint `json:"b"`
+	Protocols []string `json:"protocols"`
+}
+
+// Build returns the graph of the assets, their neighbors and the switch
+// ports they are plugged into. Neighbors are
+// matched to scanned assets by management address, then by name, then by
+// chassis MAC address; remote port names are normalized to the matched
+// asset's interface names so that both ends report the same link.
+func Build(assets []inventory.AssetModel) *Graph {
+	b := builder{
+		byIP:    map[netip.Addr]int{},
+		byName:  map[string]int{},
+		byMAC:   map[string]int{},
+		devices: map[string]*Device{},
+		links:   map[[2]Endpoint]*Link{},
+	}
+	// Scanned addresses take precedence over addresses that are only
+	// assigned to another asset's interface.
+	for i, asset := range assets {
+		b.byIP[asset.IP] = i
+	}
+	for i, asset := range assets {
+		b.index(i, asset)
+	}
+	for _, asset := range assets {
+		b.devices[asset.IP.String()] = &Device{
+			ID: asset.IP.String(), Name: asset.Hostname, IP: asset.IP.String(),
+			Type: asset.Type, Description: strings.TrimSpace(asset.Vendor + " " + asset.Model), Scanned: true,
+		}
+	}
+	for _, asset := range assets {
+		local := asset.IP.String()
+		for _, n := range asset.Neighbors {
+			port := n.LocalPort
+			if iface, ok := findInterface(asset, n.LocalPort); ok {
+				port = portName(iface)
+			}
+			remote, remotePort := b.remote(assets, n)
+			if remote == "" {
+				continue
+			}
+			b.link(Endpoint{local, port}, Endpoint{remote, remotePort}, n.Protocol)
+		}
+		// Devices located from a switch's forwarding table hang off the
+		// port their MAC address was learned on.
+		if sp := asset.SwitchPort; sp != nil {
+			if _, ok := b.devices[sp.SwitchIP.String()]; ok {
+				b.link(Endpoint{sp.SwitchIP.String(), sp.Port}, Endpoint{local, ""}, "fdb")
+			}
+		}
+	}
+
+	ports := map[string]map[string]bool{}
+	for _, l := range b.links {
+		for _, e := range []Endpoint{l.A, l.B} {
+			if e.Port == "" {
+				continue
+			}
+			if ports[e.Device] == nil {
+				ports[e.Device] = map[string]bool{}
+			}
+			ports[e.Device][e.Port] = true
+		}
+	}
+	g := &Graph{Devices: []Device{}, Links: []Link{}}
+	for _, d := range b.devices {
+		for port := range ports[d.ID] {
+			d.Ports = append(d.Ports, port)
+		}
+		sort.Strings(d.Ports)
+		g.Devices = append(g.Devices, *d)
+	}
+	sort.Slice(g.Devices, func(i, j int) bool { return g.Devices[i].ID < g.Devices[j].ID })
+	for _, l := range b.links {
+		sort.Strings(l.Protocols)
+		g.Links = append(g.Links, *l)
+	}
+	sort.Slice(g.Links, func(i, j int) bool {
+		x, y := g.Links[i], g.Links[j]
+		if x.A != y.A {
+			return endpointLess(x.A, y.A)
+		}
+		return endpointLess(x.B, y.B)
+	})
+	return g
+}
+
+type builder struct {
+	byIP    map[netip.Addr]int
+	byName  map[string]int
+	byMAC   map[string]int
+	devices map[string]*Device
+	links   map[[2]Endpoint]*Link
+}
+
+// index records how asset i can be recognized in a neighbor table.
+func (b *builder) index(i int, asset inventory.AssetModel) {
+	if asset.Hostname != "" {
+		b.byName[strings.ToLower(asset.Hostname)] = i
+		b.byName[shortName(asset.Hostname)] = i
+	}
+	if asset.MAC != "" {
+		b.byMAC[strings.ToLower(asset.MAC)] = i
+	}
+	for _, iface := range asset.Interfaces {
+		if iface.MAC != "" {
+			b.byMAC[strings.ToLower(iface.MAC)] = i
+		}
+		for _, p := range iface.Addresses {
+			if _, ok := b.byIP[p.Addr()]; !ok {
+				b.byIP[p.Addr()] = i
+			}
+		}
+	}
+}
+
+// remote returns the device ID and port of a neighbor, adding the device
+// to the graph when it was not scanned.
+func (b *builder) remote(assets []inventory.AssetModel, n inventory.Neighbor) (string, string) {
+	i, ok := b.byIP[n.RemoteIP]
+	if !ok && n.RemoteName != "" {
+		if i, ok = b.byName[strings.ToLower(n.RemoteName)]; !ok {
+			i, ok = b.byName[shortName(n.RemoteName)]
+		}
+	}
+	if !ok && n.RemoteChassisID != "" {
+		i, ok = b.byMAC[strings.ToLower(n.RemoteChassisID)]
+	}
+	if ok {
+		asset := assets[i]
+		port := n.RemotePort
+		if iface, found := findInterface(asset, n.RemotePort); found {
+			port = portName(iface)
+		}
+		return asset.IP.String(), port
+	}
+
+	var id string
+	switch {
+	case n.RemoteName != "":
+		id = n.RemoteName
+	case n.RemoteChassisID != "":
+		id = n.RemoteChassisID
+	case n.RemoteIP.IsValid():
+		id = n.RemoteIP.String()
+	default:
+		return "", ""
+	}
+	d := b.devices[id]
+	if d == nil {
+		d = &Device{ID: id, Name: n.RemoteName}
+		b.devices[id] = d
+	}
+	if !d.Scanned {
+		if d.IP == "" && n.RemoteIP.IsValid() {
+			d.IP = n.RemoteIP.String()
+		}
+		if d.Description == "" {
+			d.Description = n.RemoteDescription
+		}
+	}
+	return id, n.RemotePort
+}
+
+// link adds the link between a and b, or the protocol to the existing one.
+func (b *builder) link(a, z Endpoint, protocol string) {
+	if endpointLess(z, a) {
+		a, z = z, a
+	}
+	key := [2]Endpoint{a, z}
+	l := b.links[key]
+	if l == nil {
+		l = &Link{A: a, B: z}
+		b.links[key] = l
+	}
+	for _, p := range l.Protocols {
+		if p == protocol {
+			return
+		}
+	}
+	l.Protocols = append(l.Protocols, protocol)
+}
+
+// findInterface returns the asset's interface known as port, by name,
+// description, alias or MAC address.
+func findInterface(asset inventory.AssetModel, port string) (inventory.NetworkInterface, bool) {
+	if port == "" {
+		return inventory.NetworkInterface{}, false
+	}
+	for _, iface := range asset.Interfaces {
+		if strings.EqualFold(iface.Name, port) || strings.EqualFold(iface.Description, port) ||
+			strings.EqualFold(iface.Alias, port) || (iface.MAC != "" && strings.EqualFold(iface.MAC, port)) {
+			return iface, true
+		}
+	}
+	return inventory.NetworkInterface{}, false
+}
+
+func portName(iface inventory.NetworkInterface) string {
+	if iface.Name != "" {
+		return iface.Name
+	}
+	return iface.Description
+}
+
+// shortName returns the first label of a host name, lower-cased.
+func shortName(name string) string {
+	name, _, _ = strings.Cut(strings.ToLower(name), ".")
+	return name
+}
+
+func endpointLess(a, b Endpoint) bool {
+	if a.Device != b.Device {
+		return a.Device < b.Device
+	}
+	return a.Port < b.Port
+}
+
+// WriteJSON writes the graph as indented JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language. Devices that
+// were not scanned are drawn dashed; each edge is labelled with the ports
+// at either end.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("graph topology {\n")
+	for _, d := range g.Devices {
+		label := d.ID
+		if d.Name != "" && d.Name != d.ID {
+			label = d.Name + "\n" + d.ID
+		}
+		attrs := "label=" + dotQuote(label)
+		if !d.Scanned {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(&sb, "  %s [%s];\n", dotQuote(d.ID), attrs)
+	}
+	for _, l := range g.Links {
+		fmt.Fprintf(&sb, "  %s -- %s [taillabel=%s, headlabel=%s, label=%s];\n",
+			dotQuote(l.A.Device), dotQuote(l.B.Device), dotQuote(l.A.Port), dotQuote(l.B.Port),
+			dotQuote(strings.Join(l.Protocols, ",")))
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// dotQuote returns s as a DOT quoted string.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
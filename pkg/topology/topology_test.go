@@ -0,0 +1,117 @@
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nmasdoufi/goscanner/pkg/inventory"
+)
+
+// switches returns a core switch and an access switch that see each other
+// over LLDP and CDP, and an access point that is not scanned.
+func switches() []inventory.AssetModel {
+	core := inventory.AssetModel{
+		IP: netip.MustParseAddr("10.0.0.1"), Hostname: "core-sw1.example.com", Type: "network",
+		Interfaces: []inventory.NetworkInterface{
+			{Index: 24, Name: "Gi1/0/24", Description: "GigabitEthernet1/0/24", MAC: "00:1b:54:10:20:18"},
+		},
+		Neighbors: []inventory.Neighbor{
+			{Protocol: "lldp", LocalIndex: 24, LocalPort: "Gi1/0/24", RemoteName: "access-sw2", RemotePort: "Gi0/1"},
+		},
+	}
+	access := inventory.AssetModel{
+		IP: netip.MustParseAddr("10.0.0.2"), Hostname: "access-sw2", Type: "network",
+		Interfaces: []inventory.NetworkInterface{
+			{Index: 1, Name: "Gi0/1", Description: "GigabitEthernet0/1"},
+			{Index: 2, Name: "Gi0/2", Description: "GigabitEthernet0/2"},
+		},
+		Neighbors: []inventory.Neighbor{
+			{Protocol: "lldp", LocalIndex: 1, LocalPort: "Gi0/1", RemoteName: "core-sw1.example.com", RemotePort: "Gi1/0/24", RemoteIP: netip.MustParseAddr("10.0.0.1")},
+			// CDP names the remote port by its description.
+			{Protocol: "cdp", LocalIndex: 1, LocalPort: "Gi0/1", RemoteName: "core-sw1", RemotePort: "GigabitEthernet1/0/24", RemoteIP: netip.MustParseAddr("10.0.0.1")},
+			{Protocol: "lldp", LocalIndex: 2, LocalPort: "Gi0/2", RemoteChassisID: "00:11:22:33:44:55", RemotePort: "eth0", RemoteDescription: "AP"},
+		},
+	}
+	return []inventory.AssetModel{core, access}
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(switches())
+
+	wantDevices := []Device{
+		{ID: "00:11:22:33:44:55", Description: "AP", Ports: []string{"eth0"}},
+		{ID: "10.0.0.1", Name: "core-sw1.example.com", IP: "10.0.0.1", Type: "network", Scanned: true, Ports: []string{"Gi1/0/24"}},
+		{ID: "10.0.0.2", Name: "access-sw2", IP: "10.0.0.2", Type: "network", Scanned: true, Ports: []string{"Gi0/1", "Gi0/2"}},
+	}
+	if !reflect.DeepEqual(g.Devices, wantDevices) {
+		t.Fatalf("devices\n got %+v\nwant %+v", g.Devices, wantDevices)
+	}
+	wantLinks := []Link{
+		{A: Endpoint{"00:11:22:33:44:55", "eth0"}, B: Endpoint{"10.0.0.2", "Gi0/2"}, Protocols: []string{"lldp"}},
+		{A: Endpoint{"10.0.0.1", "Gi1/0/24"}, B: Endpoint{"10.0.0.2", "Gi0/1"}, Protocols: []string{"cdp", "lldp"}},
+	}
+	if !reflect.DeepEqual(g.Links, wantLinks) {
+		t.Fatalf("links\n got %+v\nwant %+v", g.Links, wantLinks)
+	}
+}
+
+func TestBuildMatchesChassisMAC(t *testing.T) {
+	assets := switches()
+	// The access switch only knows the core by its chassis MAC.
+	assets[1].Neighbors = []inventory.Neighbor{
+		{Protocol: "lldp", LocalPort: "GigabitEthernet0/1", RemoteChassisID: "00:1B:54:10:20:18", RemotePort: "GigabitEthernet1/0/24"},
+	}
+	g := Build(assets)
+	want := []Link{{A: Endpoint{"10.0.0.1", "Gi1/0/24"}, B: Endpoint{"10.0.0.2", "Gi0/1"}, Protocols: []string{"lldp"}}}
+	if !reflect.DeepEqual(g.Links, want) || len(g.Devices) != 2 {
+		t.Fatalf("graph %+v", g)
+	}
+}
+
+func TestBuildSwitchPort(t *testing.T) {
+	host := inventory.AssetModel{
+		IP: netip.MustParseAddr("10.20.0.5"), Type: "Computer",
+		SwitchPort: &inventory.SwitchPort{Switch: "access-sw2", SwitchIP: netip.MustParseAddr("10.0.0.2"), IfIndex: 2, Port: "Gi0/2", VLAN: 20},
+	}
+	g := Build(append(switches(), host))
+	want := Link{A: Endpoint{"10.0.0.2", "Gi0/2"}, B: Endpoint{"10.20.0.5", ""}, Protocols: []string{"fdb"}}
+	if len(g.Links) != 3 || !reflect.DeepEqual(g.Links[2], want) {
+		t.Fatalf("links %+v", g.Links)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Build(switches()).WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"graph topology {\n",
+		`"10.0.0.1" [label="core-sw1.example.com\n10.0.0.1"];`,
+		`"00:11:22:33:44:55" [label="00:11:22:33:44:55", style=dashed];`,
+		`"10.0.0.1" -- "10.0.0.2" [taillabel="Gi1/0/24", headlabel="Gi0/1", label="cdp,lldp"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Build(switches()).WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var g Graph
+	if err := json.Unmarshal(buf.Bytes(), &g); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&g, Build(switches())) {
+		t.Fatalf("round trip %+v", g)
+	}
+}